@@ -0,0 +1,525 @@
+// Alerting checks summarized results against rules and sends notifications
+// to a webhook as alerts change state.
+package llama
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Alert states
+const (
+	AlertPending  = "pending"  // Condition met, but not for long enough
+	AlertFiring   = "firing"   // Condition met for long enough
+	AlertResolved = "resolved" // Condition no longer met after firing
+)
+
+// Webhook formats
+const (
+	WebhookAlertmanager = "alertmanager"
+	WebhookJSON         = "json"
+)
+
+// DefaultWebhookTimeout is used for webhook requests when no timeout is
+// provided in the config.
+const DefaultWebhookTimeout = 5 * time.Second
+
+var (
+	// Matches the trailing "for N intervals" portion of a rule
+	ruleForRegex = regexp.MustCompile(`(?i)\s+for\s+(\d+)(\s+intervals?)?\s*$`)
+	// Separates the terms of a rule
+	ruleAndRegex = regexp.MustCompile(`(?i)\s+and\s+`)
+	// Matches a threshold on a field, like "loss > 2%"
+	ruleCondRegex = regexp.MustCompile(`^([A-Za-z0-9_]+)\s*(>=|<=|>|<)\s*(-?[0-9]*\.?[0-9]+)\s*%?$`)
+	// Matches a tag selector, like "dst_region=west"
+	ruleSelRegex = regexp.MustCompile(`^([A-Za-z0-9_.-]+)\s*(!=|=)\s*(\S+)$`)
+)
+
+// AlertSelector matches DataPoints based on the value of a tag.
+type AlertSelector struct {
+	Tag    string
+	Value  string
+	Negate bool // Match when the value differs instead
+}
+
+// Matches determines if the selector matches the provided tags.
+func (sel *AlertSelector) Matches(t Tags) bool {
+	return (t[sel.Tag] == sel.Value) != sel.Negate
+}
+
+// AlertCondition compares the value of a DataPoint field to a threshold.
+type AlertCondition struct {
+	Field     string
+	Op        string
+	Threshold float64
+}
+
+// Check determines if the condition is met for the provided fields, and
+// provides the value that was compared.
+//
+// If the field doesn't exist, the condition isn't met.
+func (cond *AlertCondition) Check(fields map[string]IDBFloat64) (bool, float64) {
+	v, found := fields[cond.Field]
+	if !found {
+		return false, 0
+	}
+	value := float64(v)
+	switch cond.Op {
+	case ">":
+		return value > cond.Threshold, value
+	case ">=":
+		return value >= cond.Threshold, value
+	case "<":
+		return value < cond.Threshold, value
+	case "<=":
+		return value <= cond.Threshold, value
+	}
+	return false, value
+}
+
+// AlertRule describes when an alert should fire for a path.
+//
+// All selectors and conditions must match, for at least `For` consecutive
+// intervals, before the alert fires.
+type AlertRule struct {
+	Name       string
+	Expr       string
+	Labels     Tags
+	Selectors  []*AlertSelector
+	Conditions []*AlertCondition
+	For        int
+}
+
+// Matches determines if the selectors of the rule match the provided tags.
+func (r *AlertRule) Matches(t Tags) bool {
+	for _, sel := range r.Selectors {
+		if !sel.Matches(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check determines if all conditions of the rule are met for the provided
+// fields, and provides the values that were compared.
+func (r *AlertRule) Check(fields map[string]IDBFloat64) (bool, map[string]float64) {
+	values := make(map[string]float64)
+	met := true
+	for _, cond := range r.Conditions {
+		ok, value := cond.Check(fields)
+		values[cond.Field] = value
+		if !ok {
+			met = false
+		}
+	}
+	return met, values
+}
+
+// ParseAlertRule creates an AlertRule from the text of the expression.
+//
+// Expressions are terms joined by AND, and optionally followed by how many
+// consecutive intervals the terms must match for. Terms are either a tag
+// selector (`tag=value` or `tag!=value`) or a condition on a field, using one
+// of >, >=, <, or <=. A trailing % on a threshold is ignored, as loss is
+// already a percentage.
+//
+// Ex. "dst_region=west AND loss > 2% for 3 intervals"
+func ParseAlertRule(name string, expr string) (*AlertRule, error) {
+	rule := &AlertRule{Name: name, Expr: expr, For: 1}
+	text := strings.TrimSpace(expr)
+	// Extract how long the rule needs to match for
+	if match := ruleForRegex.FindStringSubmatch(text); match != nil {
+		count, err := strconv.Atoi(match[1])
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("Invalid interval count in rule %q: %s", name, match[1])
+		}
+		rule.For = count
+		text = text[:len(text)-len(match[0])]
+	}
+	for _, term := range ruleAndRegex.Split(text, -1) {
+		term = strings.TrimSpace(term)
+		if match := ruleCondRegex.FindStringSubmatch(term); match != nil {
+			threshold, err := strconv.ParseFloat(match[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid threshold in rule %q: %s", name, err)
+			}
+			rule.Conditions = append(rule.Conditions, &AlertCondition{
+				Field:     match[1],
+				Op:        match[2],
+				Threshold: threshold,
+			})
+			continue
+		}
+		if match := ruleSelRegex.FindStringSubmatch(term); match != nil {
+			rule.Selectors = append(rule.Selectors, &AlertSelector{
+				Tag:    match[1],
+				Value:  match[3],
+				Negate: match[2] == "!=",
+			})
+			continue
+		}
+		return nil, fmt.Errorf("Unable to parse term in rule %q: %q", name, term)
+	}
+	if len(rule.Conditions) == 0 {
+		return nil, fmt.Errorf("Rule %q has no conditions on fields", name)
+	}
+	return rule, nil
+}
+
+// NewAlertRules parses all of the rules in the provided config.
+func NewAlertRules(cfg []AlertRuleConfig) ([]*AlertRule, error) {
+	var rules []*AlertRule
+	for _, rc := range cfg {
+		rule, err := ParseAlertRule(rc.Name, rc.Expr)
+		if err != nil {
+			return nil, err
+		}
+		rule.Labels = rc.Labels
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Alert represents the state of a single rule for a single path.
+type Alert struct {
+	Rule       string             `json:"rule"`
+	State      string             `json:"state"`
+	Tags       Tags               `json:"tags"`
+	Values     map[string]float64 `json:"values"`
+	Count      int                `json:"count"` // Consecutive intervals matched
+	StartsAt   time.Time          `json:"starts_at"`
+	EndsAt     time.Time          `json:"ends_at"`
+	IntervalID int64              `json:"interval_id"`
+	labels     Tags               // From the rule, for notifications
+}
+
+// alertKey uniquely identifies the alert for a rule and path.
+func alertKey(rule *AlertRule, dp *DataPoint) string {
+	return fmt.Sprintf("%s:src_%v->dst_%v", rule.Name, dp.Tags["src_ip"], dp.Tags["dst_ip"])
+}
+
+// Alerter receives summarized intervals, checks them against rules, and
+// sends notifications when alerts start firing or are resolved.
+type Alerter struct {
+	in      chan *Interval
+	stop    chan bool
+	tags    *SharedTagSet
+	webhook *Webhook
+	mutex   sync.RWMutex
+	rules   []*AlertRule
+	alerts  map[string]*Alert
+	// Channels which are provided alerts when they start firing or resolve
+	subscribers []chan []Alert
+}
+
+// Run will start the Alerter in a new goroutine, and cause it to forever
+// receive intervals and check them against the rules.
+func (a *Alerter) Run() {
+	go a.run()
+}
+
+func (a *Alerter) run() {
+	for {
+		select {
+		case <-a.stop:
+			return // We're done here
+		case interval := <-a.in:
+			changed, firing := a.process(interval)
+			a.notify(changed, firing)
+			a.publish(changed)
+		}
+	}
+}
+
+// process checks the provided interval against the rules, updating the state
+// of all alerts.
+//
+// It provides the alerts that started firing or were resolved, as well as all
+// of those currently firing.
+func (a *Alerter) process(interval *Interval) ([]*Alert, []*Alert) {
+	points := a.tags.DataPoints(interval.Summaries)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	seen := make(map[string]bool)
+	var changed []*Alert
+	for _, rule := range a.rules {
+		for _, dp := range points {
+			if !rule.Matches(dp.Tags) {
+				continue
+			}
+			key := alertKey(rule, dp)
+			met, values := rule.Check(dp.Fields)
+			if !met {
+				// Leave it unseen, so it's cleared below
+				continue
+			}
+			seen[key] = true
+			alert, found := a.alerts[key]
+			if !found {
+				alert = &Alert{
+					Rule:     rule.Name,
+					State:    AlertPending,
+					StartsAt: interval.Start,
+					labels:   rule.Labels,
+				}
+				a.alerts[key] = alert
+			}
+			alert.Tags = dp.Tags
+			alert.Values = values
+			alert.IntervalID = interval.ID
+			alert.Count++
+			if alert.State == AlertPending && alert.Count >= rule.For {
+				alert.State = AlertFiring
+				changed = append(changed, alert)
+			}
+		}
+	}
+	// Anything not matched this time is no longer an issue
+	for key, alert := range a.alerts {
+		if seen[key] {
+			continue
+		}
+		delete(a.alerts, key)
+		if alert.State == AlertFiring {
+			alert.State = AlertResolved
+			alert.EndsAt = interval.End
+			alert.IntervalID = interval.ID
+			changed = append(changed, alert)
+		}
+	}
+	var firing []*Alert
+	for _, alert := range a.alerts {
+		if alert.State == AlertFiring {
+			firing = append(firing, alert)
+		}
+	}
+	return changed, firing
+}
+
+// notify sends the alerts to the webhook, if there is one.
+func (a *Alerter) notify(changed []*Alert, firing []*Alert) {
+	if a.webhook == nil {
+		return
+	}
+	for _, alert := range changed {
+		log.Println("Alert", alert.Rule, "is", alert.State, "for", alert.Tags["dst_ip"])
+	}
+	err := a.webhook.Send(changed, firing)
+	HandleMinorError(err)
+}
+
+// publish provides copies of the changed alerts to each of the subscribers,
+// dropping them for any subscriber that isn't ready to receive them.
+func (a *Alerter) publish(changed []*Alert) {
+	if len(changed) == 0 {
+		return
+	}
+	alerts := make([]Alert, 0, len(changed))
+	for _, alert := range changed {
+		alerts = append(alerts, *alert)
+	}
+	a.mutex.RLock()
+	subscribers := a.subscribers
+	a.mutex.RUnlock()
+	for _, sub := range subscribers {
+		select {
+		case sub <- alerts:
+		default:
+			log.Println("Subscriber not keeping up, dropped", len(alerts), "alerts")
+		}
+	}
+}
+
+// Subscribe provides a channel which receives the alerts that started firing
+// or were resolved, after each interval where any did.
+func (a *Alerter) Subscribe() chan []Alert {
+	sub := make(chan []Alert, DEFAULT_CHANNEL_SIZE)
+	a.mutex.Lock()
+	a.subscribers = append(a.subscribers, sub)
+	a.mutex.Unlock()
+	return sub
+}
+
+// Unsubscribe stops providing alerts to a channel from Subscribe.
+func (a *Alerter) Unsubscribe(sub chan []Alert) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	// Copied, since the existing slice may be in use for publishing
+	subscribers := make([]chan []Alert, 0, len(a.subscribers))
+	for _, existing := range a.subscribers {
+		if existing != sub {
+			subscribers = append(subscribers, existing)
+		}
+	}
+	a.subscribers = subscribers
+}
+
+// Alerts provides a copy of all alerts which are currently pending or
+// firing, sorted by rule.
+func (a *Alerter) Alerts() []Alert {
+	a.mutex.RLock()
+	//nolint:gosimple
+	alerts := make([]Alert, 0) // To avoid JSON issues with nil
+	for _, alert := range a.alerts {
+		alerts = append(alerts, *alert)
+	}
+	a.mutex.RUnlock()
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Rule < alerts[j].Rule
+	})
+	return alerts
+}
+
+// SetRules replaces the rules alerts are checked against.
+//
+// Existing alerts for rules that no longer exist are resolved on the next
+// interval, as they won't be matched.
+func (a *Alerter) SetRules(rules []*AlertRule) {
+	a.mutex.Lock()
+	a.rules = rules
+	a.mutex.Unlock()
+}
+
+// Stop will stop the Alerter.
+func (a *Alerter) Stop() {
+	log.Println("Stopping Alerter")
+	close(a.stop)
+}
+
+// NewAlerter creates a new Alerter which receives intervals from `in`,
+// applies tags from `tags`, and checks them against `rules`.
+//
+// `webhook` may be nil, in which case alert state is tracked but no
+// notifications are sent.
+func NewAlerter(in chan *Interval, tags *SharedTagSet, rules []*AlertRule,
+	webhook *Webhook) *Alerter {
+	return &Alerter{
+		in:      in,
+		stop:    make(chan bool),
+		tags:    tags,
+		webhook: webhook,
+		rules:   rules,
+		alerts:  make(map[string]*Alert),
+	}
+}
+
+// Webhook sends alert notifications via HTTP POST requests.
+type Webhook struct {
+	url    string
+	format string
+	client *http.Client
+}
+
+// Send posts the alerts to the webhook.
+//
+// For the Alertmanager format, all firing alerts are sent each time, since
+// Alertmanager expects them to be repeated and will otherwise resolve them.
+// For the JSON format, only the alerts that changed state are sent.
+func (w *Webhook) Send(changed []*Alert, firing []*Alert) error {
+	if len(changed) == 0 && (w.format != WebhookAlertmanager || len(firing) == 0) {
+		return nil // Nothing to send
+	}
+	var body interface{}
+	switch w.format {
+	case WebhookAlertmanager:
+		// Firing alerts that just changed state are already in `firing`
+		var resolved []*Alert
+		for _, alert := range changed {
+			if alert.State == AlertResolved {
+				resolved = append(resolved, alert)
+			}
+		}
+		body = NewAlertmanagerAlerts(append(firing, resolved...))
+	default:
+		body = changed
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("Failed to send alerts to webhook: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook returned status: %s", resp.Status)
+	}
+	return nil
+}
+
+// NewWebhook creates a Webhook based on the provided config, or returns nil
+// if no URL was provided.
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	format := cfg.Format
+	if format == "" {
+		format = WebhookJSON
+	}
+	if format != WebhookJSON && format != WebhookAlertmanager {
+		return nil, fmt.Errorf("Unknown webhook format: %s", format)
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	return &Webhook{
+		url:    cfg.URL,
+		format: format,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// AlertmanagerAlert is the representation of an alert expected by the
+// Alertmanager API.
+type AlertmanagerAlert struct {
+	Labels      Tags       `json:"labels"`
+	Annotations Tags       `json:"annotations"`
+	StartsAt    time.Time  `json:"startsAt"`
+	EndsAt      *time.Time `json:"endsAt,omitempty"` // Only when resolved
+}
+
+// NewAlertmanagerAlerts converts alerts to the Alertmanager representation.
+//
+// Labels are made up of the alert's tags, the rule's labels, and the name of
+// the rule as `alertname`.
+func NewAlertmanagerAlerts(alerts []*Alert) []*AlertmanagerAlert {
+	//nolint:gosimple
+	ams := make([]*AlertmanagerAlert, 0) // To avoid JSON issues with nil
+	for _, alert := range alerts {
+		labels := make(Tags)
+		for k, v := range alert.Tags {
+			labels[k] = v
+		}
+		for k, v := range alert.labels {
+			labels[k] = v
+		}
+		labels["alertname"] = alert.Rule
+		annotations := make(Tags)
+		for k, v := range alert.Values {
+			annotations[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		am := &AlertmanagerAlert{
+			Labels:      labels,
+			Annotations: annotations,
+			StartsAt:    alert.StartsAt,
+		}
+		if alert.State == AlertResolved {
+			endsAt := alert.EndsAt
+			am.EndsAt = &endsAt
+		}
+		ams = append(ams, am)
+	}
+	return ams
+}
@@ -0,0 +1,211 @@
+package llama
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	rule, err := ParseAlertRule("west", "dst_region=west AND src_region!=west and loss > 2% for 3 intervals")
+	if err != nil {
+		t.Fatal("Failed to parse rule:", err)
+	}
+	if rule.For != 3 {
+		t.Error("Expected For of 3, got", rule.For)
+	}
+	if len(rule.Selectors) != 2 {
+		t.Fatal("Expected 2 selectors, got", len(rule.Selectors))
+	}
+	if rule.Selectors[0].Tag != "dst_region" || rule.Selectors[0].Value != "west" ||
+		rule.Selectors[0].Negate {
+		t.Error("First selector parsed incorrectly:", rule.Selectors[0])
+	}
+	if !rule.Selectors[1].Negate {
+		t.Error("Second selector should be negated")
+	}
+	if len(rule.Conditions) != 1 {
+		t.Fatal("Expected 1 condition, got", len(rule.Conditions))
+	}
+	cond := rule.Conditions[0]
+	if cond.Field != "loss" || cond.Op != ">" || cond.Threshold != 2.0 {
+		t.Error("Condition parsed incorrectly:", cond)
+	}
+	// Defaults to a single interval
+	rule, err = ParseAlertRule("rtt", "rtt >= 100.5")
+	if err != nil {
+		t.Fatal("Failed to parse rule:", err)
+	}
+	if rule.For != 1 {
+		t.Error("Expected For of 1, got", rule.For)
+	}
+	// Bad rules
+	for _, expr := range []string{"", "dst_region=west", "loss ~ 2", "loss > 2 for 0"} {
+		_, err = ParseAlertRule("bad", expr)
+		if err == nil {
+			t.Error("Expected an error parsing", expr)
+		}
+	}
+}
+
+// alertInterval provides an Interval with a single summary for the path
+// from 10.0.0.1 to 10.0.0.2 with the provided loss.
+func alertInterval(id int64, loss float64) *Interval {
+	return &Interval{
+		ID:    id,
+		Start: time.Unix(id*30, 0),
+		End:   time.Unix((id+1)*30, 0),
+		Summaries: []*Summary{
+			&Summary{
+				Pd: &PathDist{
+					SrcIP: net.ParseIP("10.0.0.1"),
+					DstIP: net.ParseIP("10.0.0.2"),
+				},
+				Loss: loss,
+			},
+		},
+	}
+}
+
+func TestAlerterProcess(t *testing.T) {
+	rule, err := ParseAlertRule("loss", "dst_region=west AND loss > 2 for 2 intervals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := NewSharedTagSet(TagSet{"10.0.0.2": Tags{"dst_region": "west"}})
+	a := NewAlerter(nil, tags, []*AlertRule{rule}, nil)
+	// First interval only makes it pending
+	changed, firing := a.process(alertInterval(1, 5.0))
+	if len(changed) != 0 || len(firing) != 0 {
+		t.Error("Nothing should fire after one interval")
+	}
+	alerts := a.Alerts()
+	if len(alerts) != 1 || alerts[0].State != AlertPending {
+		t.Fatal("Expected a pending alert, got", alerts)
+	}
+	// Second interval fires
+	changed, firing = a.process(alertInterval(2, 5.0))
+	if len(changed) != 1 || changed[0].State != AlertFiring {
+		t.Fatal("Expected the alert to start firing, got", changed)
+	}
+	if len(firing) != 1 {
+		t.Error("Expected 1 firing alert, got", len(firing))
+	}
+	if changed[0].Values["loss"] != 5.0 {
+		t.Error("Expected the loss value on the alert, got", changed[0].Values)
+	}
+	// Still firing, but not a change
+	changed, firing = a.process(alertInterval(3, 5.0))
+	if len(changed) != 0 || len(firing) != 1 {
+		t.Error("Expected no changes and 1 firing alert")
+	}
+	// Recovery resolves it
+	changed, firing = a.process(alertInterval(4, 0.0))
+	if len(changed) != 1 || changed[0].State != AlertResolved {
+		t.Fatal("Expected the alert to resolve, got", changed)
+	}
+	if !changed[0].EndsAt.Equal(time.Unix(150, 0)) {
+		t.Error("Expected EndsAt at the end of the interval, got", changed[0].EndsAt)
+	}
+	if len(firing) != 0 || len(a.Alerts()) != 0 {
+		t.Error("No alerts should remain")
+	}
+	// Paths not matching the selectors are ignored
+	a = NewAlerter(nil, NewSharedTagSet(nil), []*AlertRule{rule}, nil)
+	a.process(alertInterval(1, 5.0))
+	if len(a.Alerts()) != 0 {
+		t.Error("Alert created for a path without matching tags")
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, r *http.Request) {
+			received, _ = ioutil.ReadAll(r.Body)
+		}))
+	defer server.Close()
+	firing := &Alert{
+		Rule:     "loss",
+		State:    AlertFiring,
+		Tags:     Tags{"dst_ip": "10.0.0.2"},
+		Values:   map[string]float64{"loss": 5.0},
+		StartsAt: time.Unix(30, 0),
+		labels:   Tags{"severity": "page"},
+	}
+	// Alertmanager format repeats firing alerts
+	w, err := NewWebhook(WebhookConfig{URL: server.URL, Format: WebhookAlertmanager})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Send(nil, []*Alert{firing})
+	if err != nil {
+		t.Fatal("Failed to send:", err)
+	}
+	var ams []AlertmanagerAlert
+	err = json.Unmarshal(received, &ams)
+	if err != nil {
+		t.Fatal("Failed to parse:", err)
+	}
+	if len(ams) != 1 {
+		t.Fatal("Expected 1 alert, got", len(ams))
+	}
+	if ams[0].Labels["alertname"] != "loss" || ams[0].Labels["severity"] != "page" ||
+		ams[0].Labels["dst_ip"] != "10.0.0.2" {
+		t.Error("Labels not populated:", ams[0].Labels)
+	}
+	if ams[0].EndsAt != nil {
+		t.Error("Firing alerts shouldn't have an end")
+	}
+	// JSON format only sends changes
+	received = nil
+	w, err = NewWebhook(WebhookConfig{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Send(nil, []*Alert{firing})
+	if err != nil || received != nil {
+		t.Error("Nothing should be sent without changes")
+	}
+	err = w.Send([]*Alert{firing}, []*Alert{firing})
+	if err != nil {
+		t.Fatal("Failed to send:", err)
+	}
+	var alerts []Alert
+	err = json.Unmarshal(received, &alerts)
+	if err != nil || len(alerts) != 1 || alerts[0].State != AlertFiring {
+		t.Error("Expected the firing alert to be sent, got", string(received))
+	}
+	// Bad configs
+	w, err = NewWebhook(WebhookConfig{})
+	if w != nil || err != nil {
+		t.Error("No webhook should be created without a URL")
+	}
+	_, err = NewWebhook(WebhookConfig{URL: server.URL, Format: "nope"})
+	if err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestAlerterSubscribe(t *testing.T) {
+	a := NewAlerter(nil, NewSharedTagSet(nil), nil, nil)
+	sub := a.Subscribe()
+	alert := &Alert{Rule: "loss", State: AlertFiring}
+	a.publish([]*Alert{alert})
+	alert.State = AlertResolved
+	alerts := <-sub
+	if len(alerts) != 1 || alerts[0].State != AlertFiring {
+		t.Error("Expected a copy of the firing alert, got", alerts)
+	}
+	// Nothing is provided without changes, or after unsubscribing
+	a.publish(nil)
+	a.Unsubscribe(sub)
+	a.publish([]*Alert{alert})
+	if len(sub) != 0 {
+		t.Error("Expected nothing else to be provided, got", <-sub)
+	}
+}
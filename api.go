@@ -1,48 +1,492 @@
 package llama
 
 import (
+	"bytes"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"sync"
+	"strconv"
+	"time"
 )
 
+// IntervalHeader identifies the interval the points provided by
+// InfluxHandler are from, so scrapers can tell when they've already seen it.
+const IntervalHeader = "X-Llama-Interval"
+
+// DefaultStreamKeepalive is how often a comment is sent to streaming clients
+// when there's nothing else to send, so idle connections aren't dropped.
+const DefaultStreamKeepalive = 15 * time.Second
+
 // API represnts the HTTP server answering queries for collected data.
 type API struct {
 	summarizer *Summarizer
 	server     *http.Server
-	ts         TagSet
+	ts         *SharedTagSet
 	handler    *http.ServeMux
-	mutex      sync.RWMutex
+	alerter    *Alerter // Optional, and only set if alerting is configured
+	prom       *PromExporter
+	targets    TargetManager // Optional, and only set if a token is configured
+	token      string
+	prober     *Prober // Optional, and only set if a token is configured
+	probeToken string
+	keepalive  time.Duration // For StreamHandler
+	responses  *responseCache
 }
 
-// InfluxHandler handles requests for InfluxDB formatted summaries.
+// InfluxHandler handles requests for InfluxDB formatted summaries, from the
+// latest interval.
+//
+// The response is negotiated as described by writeInterval.
 func (api *API) InfluxHandler(rw http.ResponseWriter, request *http.Request) {
-	// Lock the existing summaries cache
-	api.summarizer.CMutex.RLock()
-	summaries := api.summarizer.Cache
-	log.Println("Found", len(summaries), "data points")
-	// Convert the summaries to influx datapoints
-	api.mutex.RLock()
-	ifdp := NewDataPointsFromSummaries(summaries, api.ts)
-	api.mutex.RUnlock()
-	// And unlock the cache
-	api.summarizer.CMutex.RUnlock()
+	interval, found := api.summarizer.Latest()
+	if !found {
+		// Nothing has been summarized yet
+		interval = &Interval{}
+	}
+	api.writeInterval(rw, request, interval, responsePoints)
+}
 
-	// Convert to JSON
-	asJson, err := json.Marshal(ifdp)
+// IntervalHandler handles requests for a single retained interval, in the
+// same format as InfluxHandler but including the interval details.
+//
+// The interval is selected by the `id` query parameter, and the latest
+// interval is provided if it's omitted.
+func (api *API) IntervalHandler(rw http.ResponseWriter, request *http.Request) {
+	var interval *Interval
+	var found bool
+	idStr := request.URL.Query().Get("id")
+	if idStr == "" {
+		interval, found = api.summarizer.Latest()
+	} else {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			http.Error(rw, fmt.Sprintln("Invalid interval id:", err), 400)
+			return
+		}
+		interval, found = api.summarizer.Interval(id)
+	}
+	if !found {
+		http.Error(rw, "Interval not found", 404)
+		return
+	}
+	api.writeInterval(rw, request, interval, responseInterval)
+}
+
+// writeInterval writes the interval as the body for an interval endpoint.
+//
+// The body is protobuf if the request's `Accept` prefers ProtoContentType,
+// and otherwise JSON, and is gzipped if `Accept-Encoding` accepts gzip. The
+// `offset` and `limit` query parameters select a page of the points, with
+// TotalPointsHeader providing how many there are.
+//
+// Bodies are encoded once per interval, and responses include an ETag so
+// clients which already have the body get a 304 instead.
+func (api *API) writeInterval(rw http.ResponseWriter, request *http.Request,
+	interval *Interval, body string) {
+	f, err := parseResponseFormat(request, body)
 	if err != nil {
-		log.Println(err)
+		http.Error(rw, err.Error(), 400)
+		return
+	}
+	r := api.responses.Get(interval, api.ts)
+	header := rw.Header()
+	if interval.ID != 0 {
+		header.Set(IntervalHeader, strconv.FormatInt(interval.ID, 10))
+	}
+	if f.paged() {
+		header.Set(TotalPointsHeader, strconv.Itoa(len(interval.Summaries)))
+	}
+	etag := r.ETag(f)
+	header.Set("ETag", etag)
+	header.Set("Vary", "Accept, Accept-Encoding")
+	if matchesETag(request, etag) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+	data, err := r.Body(f)
+	if err != nil {
+		log.Println("Failed to encode interval", interval.ID, "-", err)
 		rw.WriteHeader(500)
 		return
 	}
+	if f.proto {
+		header.Set("Content-Type", ProtoContentType)
+	} else {
+		header.Set("Content-Type", JSONContentType)
+	}
+	if f.gzip {
+		header.Set("Content-Encoding", "gzip")
+	}
+	_, err = rw.Write(data)
+	HandleMinorError(err)
+}
+
+// IntervalsHandler handles requests for all retained intervals after the one
+// provided by the `since` query parameter, oldest first.
+//
+// If `since` is omitted, all retained intervals are provided. This allows
+// scrapers to catch up on intervals they missed.
+func (api *API) IntervalsHandler(rw http.ResponseWriter, request *http.Request) {
+	since := int64(-1)
+	sinceStr := request.URL.Query().Get("since")
+	if sinceStr != "" {
+		var err error
+		since, err = strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil {
+			http.Error(rw, fmt.Sprintln("Invalid interval id:", err), 400)
+			return
+		}
+	}
+	intervals := api.summarizer.IntervalsSince(since)
+	log.Println("Found", len(intervals), "intervals since", since)
+	//nolint:gosimple
+	ips := make([]*IntervalPoints, 0) // To avoid JSON issues with nil
+	for _, interval := range intervals {
+		ips = append(ips, api.responses.Get(interval, api.ts).Points())
+	}
+	writeGzippedJSON(rw, request, ips)
+}
+
+// SummariesHandler handles requests for the full summaries of an interval,
+// with their tags, filtered as described by ParseSummaryQuery.
+//
+// The latest interval is provided unless one is selected with `interval`.
+func (api *API) SummariesHandler(rw http.ResponseWriter, request *http.Request) {
+	query, err := ParseSummaryQuery(request.URL.Query())
+	if err != nil {
+		http.Error(rw, err.Error(), 400)
+		return
+	}
+	var summaries []*Summary
+	if query.Interval == 0 {
+		// The cache is replaced instead of modified, so it's safe to use
+		// after unlocking.
+		api.summarizer.CMutex.RLock()
+		summaries = api.summarizer.Cache
+		api.summarizer.CMutex.RUnlock()
+	} else {
+		interval, found := api.summarizer.Interval(query.Interval)
+		if !found {
+			http.Error(rw, "Interval not found", 404)
+			return
+		}
+		summaries = interval.Summaries
+	}
+	writeGzippedJSON(rw, request, query.Apply(api.ts.TaggedSummaries(summaries)))
+}
+
+// StreamHandler streams each newly summarized interval, and alerts as they
+// start firing or are resolved, as Server-Sent Events. This saves clients
+// from polling for updates.
+//
+// Intervals are `summaries` events containing IntervalSummaries, filtered as
+// described by ParseSummaryQuery, with the interval ID as the event ID.
+// Alerts are `alert` events. The latest interval is sent on connecting, or
+// all retained intervals after the one in `Last-Event-ID` when reconnecting.
+func (api *API) StreamHandler(rw http.ResponseWriter, request *http.Request) {
+	query, err := ParseSummaryQuery(request.URL.Query())
+	if err != nil {
+		http.Error(rw, err.Error(), 400)
+		return
+	}
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "Streaming not supported", 500)
+		return
+	}
+	// Subscribed before catching up, so nothing is missed in between
+	intervals := api.summarizer.Subscribe()
+	defer api.summarizer.Unsubscribe(intervals)
+	var alerts chan []Alert // Never receives if alerting isn't configured
+	if api.alerter != nil {
+		alerts = api.alerter.Subscribe()
+		defer api.alerter.Unsubscribe(alerts)
+	}
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(200)
 
-	// Send back the response
+	var last int64 // The last interval sent, to avoid repeating it
+	var catchUp []*Interval
+	if lastID := request.Header.Get("Last-Event-ID"); lastID != "" {
+		last, err = strconv.ParseInt(lastID, 10, 64)
+		if err != nil {
+			last = -1
+		}
+		catchUp = api.summarizer.IntervalsSince(last)
+	} else if latest, found := api.summarizer.Latest(); found {
+		catchUp = []*Interval{latest}
+	}
+	for _, interval := range catchUp {
+		err = api.writeSummariesEvent(rw, interval, query)
+		if err != nil {
+			return
+		}
+		last = interval.ID
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(api.keepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case interval := <-intervals:
+			if interval.ID <= last {
+				continue
+			}
+			err = api.writeSummariesEvent(rw, interval, query)
+			last = interval.ID
+		case changed := <-alerts:
+			for _, alert := range changed {
+				err = writeEvent(rw, "alert", "", alert)
+				if err != nil {
+					break
+				}
+			}
+		case <-keepalive.C:
+			_, err = fmt.Fprint(rw, ": keepalive\n\n")
+		}
+		if err != nil {
+			log.Println("Stream closed:", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeSummariesEvent writes the summaries of the interval matching the query
+// as a `summaries` event.
+func (api *API) writeSummariesEvent(w io.Writer, interval *Interval, query *SummaryQuery) error {
+	is := &IntervalSummaries{
+		ID:        interval.ID,
+		Start:     interval.Start,
+		End:       interval.End,
+		Summaries: query.Apply(api.ts.TaggedSummaries(interval.Summaries)),
+	}
+	return writeEvent(w, "summaries", strconv.FormatInt(interval.ID, 10), is)
+}
+
+// writeEvent converts v to JSON and writes it as a Server-Sent Event, with
+// an ID if one is provided.
+func writeEvent(w io.Writer, event string, id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		_, err = fmt.Fprintf(w, "id: %s\n", id)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// TargetsHandler handles requests to list and change the targets of the test
+// named by the `test` query parameter, while it's running:
+//
+//   - GET lists the targets
+//   - POST adds a JSON list of targets, or updates the tags of existing ones
+//   - DELETE removes a JSON list of targets, by IP and port
+//
+// Requests must provide the configured token as `Authorization: Bearer`, and
+// the targets for the test are provided after any change.
+func (api *API) TargetsHandler(rw http.ResponseWriter, request *http.Request) {
+	if !authorized(request, api.token) {
+		http.Error(rw, "Unauthorized", 401)
+		return
+	}
+	test := request.URL.Query().Get("test")
+	if test == "" {
+		http.Error(rw, "A test is required", 400)
+		return
+	}
+	var err error
+	switch request.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodDelete:
+		var targets TargetSet
+		err = json.NewDecoder(request.Body).Decode(&targets)
+		if err != nil {
+			http.Error(rw, fmt.Sprintln("Invalid targets:", err), 400)
+			return
+		}
+		for _, target := range targets {
+			err = ValidateTarget(target)
+			if err != nil {
+				http.Error(rw, err.Error(), 400)
+				return
+			}
+		}
+		if request.Method == http.MethodPost {
+			err = api.targets.AddTargets(test, targets)
+		} else {
+			err = api.targets.DelTargets(test, targets)
+		}
+	default:
+		http.Error(rw, "Method not allowed", 405)
+		return
+	}
+	if err == nil {
+		var targets TargetSet
+		targets, err = api.targets.Targets(test)
+		if err == nil {
+			writeJSON(rw, targets)
+			return
+		}
+	}
+	if err == ErrTestNotFound {
+		http.Error(rw, err.Error(), 404)
+		return
+	}
+	log.Println("Failed to change targets:", err)
+	http.Error(rw, err.Error(), 500)
+}
+
+// ProbeHandler handles requests to run one-off probes from the collector, as
+// described by a JSON ProbeRequest:
+//
+//   - POST runs the probe and provides the ProbeResult once it's done, or
+//     with `async=true`, provides the ProbeJob to poll immediately
+//   - GET provides the ProbeJob with the `id` query parameter
+//
+// Requests must provide the configured token as `Authorization: Bearer`.
+func (api *API) ProbeHandler(rw http.ResponseWriter, request *http.Request) {
+	if !authorized(request, api.probeToken) {
+		http.Error(rw, "Unauthorized", 401)
+		return
+	}
+	switch request.Method {
+	case http.MethodGet:
+		job, found := api.prober.Job(request.URL.Query().Get("id"))
+		if !found {
+			http.Error(rw, "Probe not found", 404)
+			return
+		}
+		writeJSON(rw, job)
+	case http.MethodPost:
+		req := &ProbeRequest{}
+		err := json.NewDecoder(request.Body).Decode(req)
+		if err != nil {
+			http.Error(rw, fmt.Sprintln("Invalid probe:", err), 400)
+			return
+		}
+		job, err := api.prober.Start(req)
+		if err == ErrProbesBusy {
+			http.Error(rw, err.Error(), 429)
+			return
+		} else if err != nil {
+			http.Error(rw, err.Error(), 400)
+			return
+		}
+		if request.URL.Query().Get("async") == "true" {
+			rw.WriteHeader(202)
+			writeJSON(rw, job)
+			return
+		}
+		// The probe keeps running if the client goes away, so it can
+		// still be polled.
+		err = job.Wait(request.Context())
+		if err != nil {
+			return
+		}
+		job, found := api.prober.Job(job.ID)
+		if !found {
+			http.Error(rw, "Probe not found", 404)
+			return
+		}
+		if job.Status == probeJobFailed {
+			http.Error(rw, job.Error, 500)
+			return
+		}
+		writeJSON(rw, job.Result)
+	default:
+		http.Error(rw, "Method not allowed", 405)
+	}
+}
+
+// authorized determines if the request provides the token, which must be
+// configured.
+func authorized(request *http.Request, token string) bool {
+	return validToken(request.Header.Get("Authorization"), token)
+}
+
+// validToken determines if an `Authorization` header provides the token, which
+// must be configured.
+func validToken(header string, token string) bool {
+	expected := []byte("Bearer " + token)
+	return token != "" && subtle.ConstantTimeCompare(expected, []byte(header)) == 1
+}
+
+// writeJSON converts v to JSON and writes it as the response.
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	asJson, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		rw.WriteHeader(500)
+		return
+	}
 	_, err = rw.Write(asJson)
 	HandleMinorError(err)
 }
 
+// writeGzippedJSON converts v to JSON and writes it as the response, gzipped
+// if the request accepts it.
+func writeGzippedJSON(rw http.ResponseWriter, request *http.Request, v interface{}) {
+	data, err := json.Marshal(v)
+	compress := err == nil && acceptsGzip(request)
+	if compress {
+		data, err = gzipBytes(data)
+	}
+	if err != nil {
+		log.Println(err)
+		rw.WriteHeader(500)
+		return
+	}
+	rw.Header().Set("Content-Type", JSONContentType)
+	rw.Header().Set("Vary", "Accept-Encoding")
+	if compress {
+		rw.Header().Set("Content-Encoding", "gzip")
+	}
+	_, err = rw.Write(data)
+	HandleMinorError(err)
+}
+
+// AlertsHandler handles requests for the alerts which are currently pending
+// or firing.
+func (api *API) AlertsHandler(rw http.ResponseWriter, request *http.Request) {
+	//nolint:gosimple
+	alerts := make([]Alert, 0) // To avoid JSON issues with nil
+	if api.alerter != nil {
+		alerts = api.alerter.Alerts()
+	}
+	writeJSON(rw, alerts)
+}
+
+// MetricsHandler handles requests for the latest summaries, and metrics on
+// the health of the collector itself, in the Prometheus text format.
+func (api *API) MetricsHandler(rw http.ResponseWriter, request *http.Request) {
+	// The cache is replaced instead of modified, so it's safe to use after
+	// unlocking.
+	api.summarizer.CMutex.RLock()
+	summaries := api.summarizer.Cache
+	api.summarizer.CMutex.RUnlock()
+	points := api.ts.DataPoints(summaries)
+	var buf bytes.Buffer
+	api.prom.WriteSummaries(&buf, summaries, points)
+	// Followed by the health of the collector itself
+	DefaultMetrics.Write(&buf)
+	rw.Header().Set("Content-Type", PromContentType)
+	_, err := rw.Write(buf.Bytes())
+	HandleMinorError(err)
+}
+
 // StatusHandler acts as a back healthcheck and simply returns 200 OK.
 func (api *API) StatusHandler(rw http.ResponseWriter, request *http.Request) {
 	fmt.Fprintf(rw, "ok")
@@ -66,13 +510,43 @@ func (api *API) Run() {
 
 // MergeUpdateTagSet combines a provided TagSet with the existing one
 func (api *API) MergeUpdateTagSet(t TagSet) {
-	api.mutex.Lock()
-	// Copy new entries into the existing TagSet
 	// Allowing retention of existing entries, updating where needed, and adding new
-	for k, v := range t {
-		api.ts[k] = v
-	}
-	api.mutex.Unlock()
+	api.ts.MergeUpdate(t)
+}
+
+// SetAlerter provides the Alerter used for answering queries about alerts.
+//
+// This must be done before running.
+func (api *API) SetAlerter(a *Alerter) {
+	api.alerter = a
+}
+
+// SetTargetManager provides the TargetManager used for changing targets, and
+// the token requests must provide to do so. The API for changing targets is
+// only available once this is set.
+//
+// This must be done before running.
+func (api *API) SetTargetManager(m TargetManager, token string) {
+	api.targets = m
+	api.token = token
+}
+
+// SetProber provides the Prober used for running one-off probes, and the token
+// requests must provide to do so. The API for probes is only available once
+// this is set.
+//
+// This must be done before running.
+func (api *API) SetProber(p *Prober, token string) {
+	api.prober = p
+	api.probeToken = token
+}
+
+// SetPromExporter replaces the PromExporter used for answering queries in the
+// Prometheus format.
+//
+// This must be done before running.
+func (api *API) SetPromExporter(e *PromExporter) {
+	api.prom = e
 }
 
 // RunForever sets up the handlers above and then listens for requests until
@@ -92,15 +566,40 @@ func (api *API) RunForever() {
 func (api *API) setupHandlers() {
 	api.handler.HandleFunc("/status", api.StatusHandler)
 	api.handler.HandleFunc("/influxdata", api.InfluxHandler)
+	api.handler.HandleFunc("/interval", api.IntervalHandler)
+	api.handler.HandleFunc("/intervals", api.IntervalsHandler)
+	api.handler.HandleFunc("/summaries", api.SummariesHandler)
+	api.handler.HandleFunc("/stream", api.StreamHandler)
+	api.handler.HandleFunc("/alerts", api.AlertsHandler)
+	api.handler.HandleFunc("/metrics", api.MetricsHandler)
+	if api.targets != nil {
+		api.handler.HandleFunc("/targets", api.TargetsHandler)
+	}
+	if api.prober != nil {
+		api.handler.HandleFunc("/probe", api.ProbeHandler)
+	}
 }
 
 // New returns an initialized API struct.
-func NewAPI(s *Summarizer, t TagSet, addr string) *API {
+//
+// `t` is shared with anything else applying tags to summaries, so that they
+// all reflect the same updates.
+func NewAPI(s *Summarizer, t *SharedTagSet, addr string) *API {
 	// TODO(dmar): In the future, make these options that can be provided.
 	handler := http.NewServeMux()
 	server := &http.Server{
 		Addr:    addr,
 		Handler: handler,
 	}
-	return &API{summarizer: s, ts: t, handler: handler, server: server}
+	// The default config is always valid
+	prom, _ := NewPromExporter(MetricsConfig{})
+	return &API{
+		summarizer: s,
+		ts:         t,
+		handler:    handler,
+		server:     server,
+		prom:       prom,
+		keepalive:  DefaultStreamKeepalive,
+		responses:  newResponseCache(s.history),
+	}
 }
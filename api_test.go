@@ -1,13 +1,281 @@
 package llama
 
 import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
+// newTestAPI provides an API with a Summarizer that already has a couple
+// of summarized intervals.
+func newTestAPI() *API {
+	s := NewSummarizer(make(chan *Result), time.Second, 0, 2)
+	s.summarize(time.Unix(101, 0))
+	s.summarize(time.Unix(102, 0))
+	return NewAPI(s, NewSharedTagSet(nil), "127.0.0.1:0")
+}
+
 func TestInfluxHandler(t *testing.T) {
 	// TODO(dmar): Do more intensive mocking and testing in the future.
+	api := newTestAPI()
+	rw := httptest.NewRecorder()
+	api.InfluxHandler(rw, httptest.NewRequest("GET", "/influxdata", nil))
+	if rw.Code != 200 {
+		t.Fatal("Expected 200, got", rw.Code)
+	}
+	if rw.Header().Get(IntervalHeader) != "101" {
+		t.Error("Expected the latest interval in the header, got", rw.Header().Get(IntervalHeader))
+	}
 }
 
 func TestStatusHandler(t *testing.T) {
 	// TODO(dmar): Do more intensive mocking and testing in the future.
 }
+
+func TestMetricsHandler(t *testing.T) {
+	api := newTestAPI()
+	rw := httptest.NewRecorder()
+	api.MetricsHandler(rw, httptest.NewRequest("GET", "/metrics", nil))
+	if rw.Code != 200 {
+		t.Fatal("Expected 200, got", rw.Code)
+	}
+	if rw.Header().Get("Content-Type") != PromContentType {
+		t.Error("Unexpected content type:", rw.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rw.Body.String(), "# TYPE llama_loss_percent gauge") {
+		t.Error("Expected summary metrics in output, got", rw.Body.String())
+	}
+	if !strings.Contains(rw.Body.String(), "# TYPE llama_summarizer_duration_seconds gauge") {
+		t.Error("Expected collector health metrics in output, got", rw.Body.String())
+	}
+}
+
+func TestIntervalHandler(t *testing.T) {
+	api := newTestAPI()
+	// Latest by default
+	rw := httptest.NewRecorder()
+	api.IntervalHandler(rw, httptest.NewRequest("GET", "/interval", nil))
+	if rw.Code != 200 {
+		t.Fatal("Expected 200, got", rw.Code)
+	}
+	ip := &IntervalPoints{}
+	err := json.Unmarshal(rw.Body.Bytes(), ip)
+	if err != nil {
+		t.Fatal("Failed to parse response:", err)
+	}
+	if ip.ID != 101 {
+		t.Error("Expected latest interval 101, got", ip.ID)
+	}
+	// A specific one
+	rw = httptest.NewRecorder()
+	api.IntervalHandler(rw, httptest.NewRequest("GET", "/interval?id=100", nil))
+	if rw.Code != 200 {
+		t.Error("Expected 200, got", rw.Code)
+	}
+	// One that doesn't exist
+	rw = httptest.NewRecorder()
+	api.IntervalHandler(rw, httptest.NewRequest("GET", "/interval?id=1", nil))
+	if rw.Code != 404 {
+		t.Error("Expected 404, got", rw.Code)
+	}
+	// Garbage
+	rw = httptest.NewRecorder()
+	api.IntervalHandler(rw, httptest.NewRequest("GET", "/interval?id=abc", nil))
+	if rw.Code != 400 {
+		t.Error("Expected 400, got", rw.Code)
+	}
+}
+
+func TestIntervalsHandler(t *testing.T) {
+	api := newTestAPI()
+	rw := httptest.NewRecorder()
+	api.IntervalsHandler(rw, httptest.NewRequest("GET", "/intervals?since=100", nil))
+	if rw.Code != 200 {
+		t.Fatal("Expected 200, got", rw.Code)
+	}
+	var ips []*IntervalPoints
+	err := json.Unmarshal(rw.Body.Bytes(), &ips)
+	if err != nil {
+		t.Fatal("Failed to parse response:", err)
+	}
+	if len(ips) != 1 || ips[0].ID != 101 {
+		t.Error("Expected only interval 101, got", ips)
+	}
+	// Without since, everything retained
+	rw = httptest.NewRecorder()
+	api.IntervalsHandler(rw, httptest.NewRequest("GET", "/intervals", nil))
+	ips = nil
+	err = json.Unmarshal(rw.Body.Bytes(), &ips)
+	if err != nil {
+		t.Fatal("Failed to parse response:", err)
+	}
+	if len(ips) != 2 {
+		t.Error("Expected 2 intervals, got", len(ips))
+	}
+}
+
+// mockTargetManager keeps targets for a single test named "default".
+type mockTargetManager struct {
+	targets TargetSet
+}
+
+func (m *mockTargetManager) Targets(test string) (TargetSet, error) {
+	if test != "default" {
+		return nil, ErrTestNotFound
+	}
+	return m.targets, nil
+}
+
+func (m *mockTargetManager) AddTargets(test string, targets TargetSet) error {
+	m.targets = append(m.targets, targets...)
+	return nil
+}
+
+func (m *mockTargetManager) DelTargets(test string, targets TargetSet) error {
+	m.targets = nil
+	return nil
+}
+
+func TestTargetsHandler(t *testing.T) {
+	api := newTestAPI()
+	m := &mockTargetManager{}
+	api.SetTargetManager(m, "secret")
+	request := func(method, url, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rw := httptest.NewRecorder()
+		api.TargetsHandler(rw, r)
+		return rw
+	}
+
+	if rw := request("GET", "/targets?test=default", "", ""); rw.Code != 401 {
+		t.Error("Expected 401 without a token, got", rw.Code)
+	}
+	if rw := request("GET", "/targets?test=default", "wrong", ""); rw.Code != 401 {
+		t.Error("Expected 401 with the wrong token, got", rw.Code)
+	}
+	if rw := request("GET", "/targets?test=missing", "secret", ""); rw.Code != 404 {
+		t.Error("Expected 404 for an unknown test, got", rw.Code)
+	}
+	if rw := request("POST", "/targets?test=default", "secret", `[{"ip": "localhost", "port": 8100}]`); rw.Code != 400 {
+		t.Error("Expected 400 for an invalid target, got", rw.Code)
+	}
+
+	rw := request("POST", "/targets?test=default", "secret", `[{"ip": "10.0.0.1", "port": 8100, "tags": {"dst_name": "new"}}]`)
+	if rw.Code != 200 {
+		t.Fatal("Expected 200, got", rw.Code, rw.Body.String())
+	}
+	var targets TargetSet
+	err := json.Unmarshal(rw.Body.Bytes(), &targets)
+	if err != nil {
+		t.Fatal("Failed to parse response:", err)
+	}
+	if len(targets) != 1 || targets[0].Tags["dst_name"] != "new" {
+		t.Error("Expected the added target, got", targets)
+	}
+
+	rw = request("DELETE", "/targets?test=default", "secret", `[{"ip": "10.0.0.1", "port": 8100}]`)
+	if rw.Code != 200 || len(m.targets) != 0 {
+		t.Error("Expected the target to be removed, got", rw.Code, m.targets)
+	}
+	if rw := request("PUT", "/targets?test=default", "secret", "[]"); rw.Code != 405 {
+		t.Error("Expected 405, got", rw.Code)
+	}
+
+	// Disabled without a token
+	api.SetTargetManager(m, "")
+	if rw := request("GET", "/targets?test=default", "", ""); rw.Code != 401 {
+		t.Error("Expected 401 without a configured token, got", rw.Code)
+	}
+}
+
+// readEvent reads the next Server-Sent Event, skipping comments, providing
+// its fields by name.
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	event := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal("Failed to read event:", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" && len(event) > 0 {
+			return event
+		}
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+		parts := strings.SplitN(line, ": ", 2)
+		event[parts[0]] = parts[1]
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	api := newTestAPI()
+	api.SetAlerter(NewAlerter(nil, api.ts, nil, nil))
+	api.keepalive = 10 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(api.StreamHandler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?src_ip=10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Error("Expected an event stream, got", resp.Header.Get("Content-Type"))
+	}
+	r := bufio.NewReader(resp.Body)
+	// The latest interval on connecting
+	event := readEvent(t, r)
+	if event["event"] != "summaries" || event["id"] != "101" {
+		t.Fatal("Expected the latest interval, got", event)
+	}
+	var is IntervalSummaries
+	err = json.Unmarshal([]byte(event["data"]), &is)
+	if err != nil || is.ID != 101 {
+		t.Error("Failed to parse summaries:", is, err)
+	}
+
+	// Then each new interval, and alert changes
+	api.summarizer.summarize(time.Unix(103, 0))
+	event = readEvent(t, r)
+	if event["event"] != "summaries" || event["id"] != "102" {
+		t.Error("Expected the new interval, got", event)
+	}
+	api.alerter.publish([]*Alert{{Rule: "loss", State: AlertFiring}})
+	event = readEvent(t, r)
+	var alert Alert
+	err = json.Unmarshal([]byte(event["data"]), &alert)
+	if event["event"] != "alert" || err != nil || alert.State != AlertFiring {
+		t.Error("Expected a firing alert, got", event, err)
+	}
+
+	// Reconnecting catches up from the last event
+	request, _ := http.NewRequest("GET", server.URL, nil)
+	request.Header.Set("Last-Event-ID", "100")
+	resp2, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+	r = bufio.NewReader(resp2.Body)
+	if event := readEvent(t, r); event["id"] != "101" {
+		t.Error("Expected to catch up from interval 101, got", event)
+	}
+	if event := readEvent(t, r); event["id"] != "102" {
+		t.Error("Expected to catch up to interval 102, got", event)
+	}
+
+	rw := httptest.NewRecorder()
+	api.StreamHandler(rw, httptest.NewRequest("GET", "/stream?min_loss=abc", nil))
+	if rw.Code != 400 {
+		t.Error("Expected 400 for an invalid query, got", rw.Code)
+	}
+}
@@ -0,0 +1,136 @@
+// Baselines track what is normal for each path, so summaries can be scored
+// relative to that instead of against static thresholds.
+package llama
+
+import (
+	"sync"
+)
+
+// Defaults used for any Baseline parameters not provided in the config
+const (
+	DefaultBaselineWarmup    = 5
+	DefaultBaselineThreshold = 2.0
+	DefaultBaselineLossFloor = 1.0
+)
+
+// pathBaseline holds the rolling values for a single path.
+type pathBaseline struct {
+	rtt      float64 // EWMA of RTTAvg, ignoring intervals where all were lost
+	rttCount int     // Number of intervals included in rtt
+	loss     float64 // EWMA of Loss
+	count    int     // Number of intervals included in loss
+	lastID   int64   // Last interval the path was seen in
+}
+
+// Baseline keeps a rolling baseline of RTT and loss for each path, as an
+// exponentially weighted moving average (EWMA), and scores summaries
+// against them.
+//
+// Scores are ratios, so that a single threshold (ex. 2x worse than normal)
+// is meaningful for paths with very different typical RTTs.
+type Baseline struct {
+	mutex     sync.Mutex
+	alpha     float64 // Weight given to each new interval
+	window    int64   // Intervals the EWMA approximately covers
+	warmup    int     // Intervals needed before scoring
+	threshold float64 // Ratio at which scores are considered anomalous
+	lossFloor float64 // Added to loss values, so a baseline of 0 can be compared
+	paths     map[string]*pathBaseline
+}
+
+// Apply scores the summaries against the baselines for their paths, and then
+// updates the baselines to include them.
+//
+// `id` is the ID of the interval the summaries are for, and is used to drop
+// baselines for paths that haven't been seen for a while.
+func (b *Baseline) Apply(id int64, summaries []*Summary) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, summary := range summaries {
+		key := PathKey(summary.Pd)
+		pb, found := b.paths[key]
+		if !found {
+			pb = &pathBaseline{}
+			b.paths[key] = pb
+		}
+		b.score(pb, summary)
+		b.update(pb, summary)
+		pb.lastID = id
+	}
+	// Forget about paths that have been gone for longer than the window, so
+	// removed targets don't stick around forever.
+	for key, pb := range b.paths {
+		if id-pb.lastID > 2*b.window {
+			delete(b.paths, key)
+		}
+	}
+}
+
+// score populates the baseline values and scores on the summary, based on
+// the current baseline for the path.
+func (b *Baseline) score(pb *pathBaseline, summary *Summary) {
+	// Not enough history to say what's normal yet
+	if pb.count < b.warmup {
+		return
+	}
+	summary.Scored = true
+	summary.LossBaseline = pb.loss
+	summary.LossAnomaly = (summary.Loss + b.lossFloor) / (pb.loss + b.lossFloor)
+	summary.LossAnomalous = summary.LossAnomaly >= b.threshold
+	// If everything was lost, or there's no RTT history, there's nothing
+	// to compare. Loss scores will already reflect the former.
+	summary.RTTBaseline = pb.rtt
+	if summary.RTTAvg == 0 || pb.rttCount < b.warmup || pb.rtt == 0 {
+		return
+	}
+	summary.RTTDeviation = summary.RTTAvg / pb.rtt
+	summary.RTTAnomalous = summary.RTTDeviation >= b.threshold
+}
+
+// update includes the summary in the baseline for the path.
+func (b *Baseline) update(pb *pathBaseline, summary *Summary) {
+	pb.loss = ewma(pb.loss, summary.Loss, b.alpha, pb.count)
+	pb.count++
+	// Total loss leaves RTT at zero, which isn't representative
+	if summary.Sent > summary.Lost {
+		pb.rtt = ewma(pb.rtt, summary.RTTAvg, b.alpha, pb.rttCount)
+		pb.rttCount++
+	}
+}
+
+// ewma provides the updated moving average after including value.
+//
+// The first value is used as-is, instead of weighing it against zero.
+func ewma(avg float64, value float64, alpha float64, count int) float64 {
+	if count == 0 {
+		return value
+	}
+	return alpha*value + (1-alpha)*avg
+}
+
+// NewBaseline creates a new Baseline based on the provided config, or nil if
+// no window is provided.
+func NewBaseline(cfg BaselineConfig) *Baseline {
+	if cfg.Window < 1 {
+		return nil
+	}
+	b := &Baseline{
+		// Standard approximation so the EWMA roughly covers the window
+		alpha:     2.0 / (float64(cfg.Window) + 1.0),
+		window:    cfg.Window,
+		warmup:    int(cfg.Warmup),
+		threshold: cfg.Threshold,
+		lossFloor: cfg.LossFloor,
+		paths:     make(map[string]*pathBaseline),
+	}
+	if b.warmup < 1 {
+		b.warmup = DefaultBaselineWarmup
+	}
+	if b.threshold <= 0 {
+		b.threshold = DefaultBaselineThreshold
+	}
+	if b.lossFloor <= 0 {
+		b.lossFloor = DefaultBaselineLossFloor
+	}
+	return b
+}
@@ -0,0 +1,87 @@
+package llama
+
+import (
+	"net"
+	"testing"
+)
+
+// baselineSummary provides a summary for a single, consistent path.
+func baselineSummary(rtt float64, loss float64) *Summary {
+	s := &Summary{
+		Pd: &PathDist{
+			SrcIP: net.ParseIP("10.0.0.1"),
+			DstIP: net.ParseIP("10.0.0.2"),
+		},
+		RTTAvg: rtt,
+		Sent:   100,
+		Loss:   loss,
+	}
+	s.Lost = int(loss)
+	return s
+}
+
+func TestNewBaseline(t *testing.T) {
+	if NewBaseline(BaselineConfig{}) != nil {
+		t.Error("Baseline should be disabled without a window")
+	}
+	b := NewBaseline(BaselineConfig{Window: 9})
+	if b.alpha != 0.2 {
+		t.Error("Expected alpha of 0.2, got", b.alpha)
+	}
+	if b.warmup != DefaultBaselineWarmup || b.threshold != DefaultBaselineThreshold ||
+		b.lossFloor != DefaultBaselineLossFloor {
+		t.Error("Defaults not applied:", b.warmup, b.threshold, b.lossFloor)
+	}
+}
+
+func TestBaselineApply(t *testing.T) {
+	b := NewBaseline(BaselineConfig{Window: 9, Warmup: 3})
+	// Nothing is scored during warmup
+	for i := int64(0); i < 3; i++ {
+		s := baselineSummary(10.0, 0.0)
+		b.Apply(i, []*Summary{s})
+		if s.Scored {
+			t.Fatal("Summary scored during warmup, interval", i)
+		}
+	}
+	// Normal values aren't anomalous
+	s := baselineSummary(10.0, 0.0)
+	b.Apply(3, []*Summary{s})
+	if !s.Scored {
+		t.Fatal("Summary should be scored after warmup")
+	}
+	if s.RTTBaseline != 10.0 || s.RTTDeviation != 1.0 || s.RTTAnomalous {
+		t.Error("Unexpected RTT scores:", s.RTTBaseline, s.RTTDeviation, s.RTTAnomalous)
+	}
+	if s.LossBaseline != 0.0 || s.LossAnomaly != 1.0 || s.LossAnomalous {
+		t.Error("Unexpected loss scores:", s.LossBaseline, s.LossAnomaly, s.LossAnomalous)
+	}
+	// Twice the RTT and a few percent of loss are anomalous
+	s = baselineSummary(20.0, 3.0)
+	b.Apply(4, []*Summary{s})
+	if s.RTTDeviation != 2.0 || !s.RTTAnomalous {
+		t.Error("Expected anomalous RTT, got", s.RTTDeviation, s.RTTAnomalous)
+	}
+	if s.LossAnomaly != 4.0 || !s.LossAnomalous {
+		t.Error("Expected anomalous loss, got", s.LossAnomaly, s.LossAnomalous)
+	}
+	// And are now part of the baseline
+	if b.paths[PathKey(s.Pd)].rtt != 12.0 {
+		t.Error("Expected baseline RTT of 12.0, got", b.paths[PathKey(s.Pd)].rtt)
+	}
+	// Total loss doesn't affect the RTT baseline
+	s = baselineSummary(0.0, 100.0)
+	s.Lost = s.Sent
+	b.Apply(5, []*Summary{s})
+	if s.RTTDeviation != 0.0 || s.RTTAnomalous {
+		t.Error("RTT shouldn't be scored with total loss")
+	}
+	if b.paths[PathKey(s.Pd)].rtt != 12.0 {
+		t.Error("RTT baseline changed after total loss")
+	}
+	// Paths that disappear are eventually dropped
+	b.Apply(100, nil)
+	if len(b.paths) != 0 {
+		t.Error("Stale path baseline wasn't dropped")
+	}
+}
@@ -2,10 +2,22 @@
 package llama
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
+)
+
+// Defaults for collector clients
+const (
+	DefaultClientTimeout    = 10 * time.Second
+	DefaultClientRetries    = 2
+	DefaultClientRetryDelay = time.Second
 )
 
 /*
@@ -58,16 +70,59 @@ type Client interface {
 	Port() string
 }
 
+// IntervalClient is implemented by Clients which can identify the interval
+// points are from, and fetch earlier intervals, so the scraper can skip
+// repeats and fill in gaps.
+type IntervalClient interface {
+	GetIntervalPoints() (Points, int64, error)
+	GetIntervalsSince(id int64) ([]*IntervalPoints, error)
+}
+
 type client struct {
-	hostname string
-	port     string
-	getFunc  Getter
+	hostname   string
+	port       string
+	getFunc    Getter
+	retries    int           // Additional attempts after a failure
+	retryDelay time.Duration // Doubled after each retry
 }
 
-// NewClient creates a new collector client with hostname and port
+// NewClient creates a new collector client with hostname and port, using
+// DefaultClientTimeout and DefaultClientRetries.
 // TODO(dmar): This is likely overkill and should be simplified.
 func NewClient(hostname string, port string) *client {
-	return &client{hostname: hostname, port: port, getFunc: http.Get}
+	c := &client{
+		hostname:   hostname,
+		port:       port,
+		retries:    DefaultClientRetries,
+		retryDelay: DefaultClientRetryDelay,
+	}
+	c.SetTimeout(DefaultClientTimeout, true)
+	return c
+}
+
+// SetTimeout sets the timeout for each request, including reading the
+// response, and whether to ask for the response to be gzipped.
+func (c *client) SetTimeout(timeout time.Duration, compress bool) {
+	httpClient := &http.Client{Timeout: timeout}
+	c.getFunc = func(url string) (*http.Response, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if compress {
+			// Set explicitly, so it's still sent if the transport changes,
+			// which means decoding it is up to us.
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		return httpClient.Do(req)
+	}
+}
+
+// SetRetries sets how many more times to attempt a request after a failure,
+// waiting `delay` before the first retry and doubling it each time after.
+func (c *client) SetRetries(retries int, delay time.Duration) {
+	c.retries = retries
+	c.retryDelay = delay
 }
 
 func (c *client) Hostname() string {
@@ -78,29 +133,81 @@ func (c *client) Port() string {
 	return c.port
 }
 
-// GetPoints will fetch data points from the associated collector
+// GetPoints will fetch data points from the associated collector, retrying
+// on connection errors and server errors.
 func (c *client) GetPoints() (Points, error) {
-	url := fmt.Sprintf("http://%s:%s/influxdata", c.hostname, c.port)
+	points, _, err := c.GetIntervalPoints()
+	return points, err
+}
 
+// GetIntervalPoints will fetch data points from the associated collector,
+// along with the ID of the interval they're from. The ID is 0 if the
+// collector doesn't provide one.
+func (c *client) GetIntervalPoints() (Points, int64, error) {
+	var points Points
+	header, err := c.fetch("/influxdata", &points)
+	if err != nil {
+		return points, 0, err
+	}
+	// Older collectors don't include it, and that's fine
+	id, _ := strconv.ParseInt(header.Get(IntervalHeader), 10, 64)
+	return points, id, nil
+}
+
+// GetIntervalsSince will fetch all of the intervals the collector has
+// retained after the one with `id`, oldest first.
+func (c *client) GetIntervalsSince(id int64) ([]*IntervalPoints, error) {
+	var intervals []*IntervalPoints
+	_, err := c.fetch(fmt.Sprintf("/intervals?since=%d", id), &intervals)
+	return intervals, err
+}
+
+// fetch requests `path` from the collector and decodes the JSON response
+// into v, retrying on connection errors and server errors.
+func (c *client) fetch(path string, v interface{}) (http.Header, error) {
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(c.hostname, c.port), path)
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		header, retry, err := c.get(url, v)
+		if err == nil || !retry || attempt >= c.retries {
+			return header, err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// get makes a single attempt at fetching `url` into v, and indicates whether
+// a failure is worth retrying.
+func (c *client) get(url string, v interface{}) (http.Header, bool, error) {
 	resp, err := c.getFunc(url)
 	if err != nil {
-		return Points{}, err
+		return nil, true, err
 	}
 	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, true, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	body, err := ioutil.ReadAll(reader)
 	if err != nil {
-		return Points{}, err
+		return nil, true, err
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("Status: %s (%s)", resp.Status, body)
+		// Client errors won't go away by trying again
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf("Status: %s (%s)", resp.Status, body)
 	}
 
-	var response Points
-	err = json.Unmarshal(body, &response)
-
+	err = json.Unmarshal(body, v)
 	if err != nil {
-		return Points{}, err
+		return nil, false, err
 	}
 
-	return response, nil
+	return resp.Header, false, nil
 }
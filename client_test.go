@@ -2,9 +2,13 @@
 package llama
 
 import (
+	"compress/gzip"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	gocheck "gopkg.in/check.v1"
 )
@@ -101,3 +105,98 @@ func (s *ClientSuite) TestGetPoints(c *gocheck.C) {
 	// Their tags should be identical
 	c.Assert(p1.Tags, gocheck.DeepEquals, p2.Tags)
 }
+
+func TestClientRetries(t *testing.T) {
+	attempts := 0
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte(test_payload))
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(host, port)
+	c.SetRetries(2, time.Millisecond)
+	points, err := c.GetPoints()
+	if err != nil || len(points) != 2 || attempts != 3 {
+		t.Error("Expected success after 2 retries, got", len(points), attempts, err)
+	}
+
+	// Retries run out
+	attempts = 0
+	c.SetRetries(1, time.Millisecond)
+	_, err = c.GetPoints()
+	if err == nil || attempts != 2 {
+		t.Error("Expected failure after 1 retry, got", attempts, err)
+	}
+
+	// Client errors aren't retried
+	attempts = 0
+	status = http.StatusNotFound
+	_, err = c.GetPoints()
+	if err == nil || attempts != 1 {
+		t.Error("Expected a single attempt for a client error, got", attempts, err)
+	}
+}
+
+func TestClientGzip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			_, _ = w.Write([]byte(test_payload))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		_, _ = gz.Write([]byte(test_payload))
+		_ = gz.Close()
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(host, port)
+	for _, compress := range []bool{true, false} {
+		c.SetTimeout(time.Second, compress)
+		points, err := c.GetPoints()
+		if err != nil || len(points) != 2 {
+			t.Error("Expected 2 points with gzip", compress, "got", len(points), err)
+		}
+	}
+}
+
+func TestClientIntervals(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/influxdata":
+			w.Header().Set(IntervalHeader, "42")
+			_, _ = w.Write([]byte(test_payload))
+		case "/intervals":
+			if r.URL.Query().Get("since") != "40" {
+				t.Error("Unexpected since:", r.URL.Query().Get("since"))
+			}
+			_, _ = w.Write([]byte(`[{"id": 41, "points": []}]`))
+		}
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(host, port)
+	points, id, err := c.GetIntervalPoints()
+	if err != nil || len(points) != 2 || id != 42 {
+		t.Error("Expected 2 points from interval 42, got", len(points), id, err)
+	}
+	intervals, err := c.GetIntervalsSince(40)
+	if err != nil || len(intervals) != 1 || intervals[0].ID != 41 {
+		t.Error("Expected interval 41, got", intervals, err)
+	}
+}
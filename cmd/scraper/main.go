@@ -4,6 +4,7 @@ package main
 import (
 	"flag"
 	"github.com/dropbox/llama"
+	"io/ioutil"
 	"log"
 	"strings"
 	"time"
@@ -18,25 +19,159 @@ var collectorPort = flag.String("llama.collector-port", "5000", "The port collec
 var collectorHosts = flag.String("llama.collector-hosts", "", "Comma-separated list of hostnames/IP addresses for collectors")
 var influxdbUser = flag.String("llama.influxdb-user", "", "The name of the user to use with InfluxDB")
 var influxdbPass = flag.String("llama.influxdb-pass", "", "The password to use with InfluxDB")
+var collectorFiles = flag.String("llama.collector-files", "", "Comma-separated list of JSON/YAML files listing collectors, which are re-read when modified")
+var collectorDNS = flag.String("llama.collector-dns", "", "Comma-separated list of DNS names resolving to collectors, as `srv:<name>` or `a:<name>`")
+var discoveryInterval = flag.Int64("llama.discovery-interval", 60, "How often to refresh discovered collectors, in seconds")
+var collectorTimeout = flag.Int64("llama.collector-timeout", 10, "Timeout for each request to a collector, in seconds")
+var collectorRetries = flag.Int64("llama.collector-retries", 2, "How many times to retry a failed request to a collector")
+var collectorGzip = flag.Bool("llama.collector-gzip", true, "Whether to ask collectors for gzipped responses")
+var collectorGRPC = flag.Bool("llama.collector-grpc", false, "Whether to pull from the gRPC API of collectors, which must then be on the collector port")
+var concurrency = flag.Int64("llama.concurrency", 0, "Max collectors to pull from at once, or 0 for no limit")
+var apiAddr = flag.String("llama.api-addr", llama.DefaultScraperAPIAddr, "Address to serve the scraper's /status and /metrics on, or empty to disable")
+var scraperConfig = flag.String("llama.scraper-config", "", "YAML config file for the scraper. If provided, the other flags are ignored")
+var writers writerFlags
+
+func init() {
+	flag.Var(&writers, "llama.writer", "Writer to send points to, as `type:key=value,...`. May be repeated. Defaults to InfluxDB using the influxdb flags")
+}
+
+// writerFlags collects each use of the `llama.writer` flag.
+type writerFlags []llama.WriterConfig
+
+func (w *writerFlags) String() string {
+	var types []string
+	for _, cfg := range *w {
+		types = append(types, cfg.Type)
+	}
+	return strings.Join(types, ",")
+}
+
+func (w *writerFlags) Set(value string) error {
+	cfg, err := llama.ParseWriterFlag(value)
+	if err != nil {
+		return err
+	}
+	*w = append(*w, cfg)
+	return nil
+}
+
+// loadConfig provides the scraper config from the file, if provided, or
+// otherwise from the flags.
+func loadConfig() *llama.ScraperConfig {
+	if *scraperConfig != "" {
+		data, err := ioutil.ReadFile(*scraperConfig)
+		if err != nil {
+			log.Fatal("Failed to read scraper config: ", err)
+		}
+		cfg, err := llama.NewScraperConfig(data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return cfg
+	}
+	cfg := &llama.ScraperConfig{
+		Collectors:    strings.Split(*collectorHosts, ","),
+		CollectorPort: *collectorPort,
+		Interval:      *interval,
+		Writers:       writers,
+		Discovery:     llama.DiscoveryConfig{Refresh: *discoveryInterval},
+		Client:        llama.NewDefaultClientConfig(),
+		Concurrency:   *concurrency,
+		APIAddr:       *apiAddr,
+	}
+	cfg.Client.Timeout = *collectorTimeout
+	cfg.Client.Retries = *collectorRetries
+	cfg.Client.Gzip = *collectorGzip
+	cfg.Client.GRPC = *collectorGRPC
+	for _, path := range strings.Split(*collectorFiles, ",") {
+		if path != "" {
+			cfg.Discovery.Files = append(cfg.Discovery.Files, path)
+		}
+	}
+	for _, name := range strings.Split(*collectorDNS, ",") {
+		if name == "" {
+			continue
+		}
+		dns, err := llama.ParseDNSDiscoveryFlag(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Discovery.DNS = append(cfg.Discovery.DNS, dns)
+	}
+	if len(cfg.Writers) == 0 {
+		cfg.Writers = []llama.WriterConfig{
+			{
+				Type: "influxdb",
+				Options: llama.WriterOptions{
+					"host": *influxdbHost,
+					"port": *influxdbPort,
+					"user": *influxdbUser,
+					"pass": *influxdbPass,
+					"db":   *influxdbDb,
+				},
+			},
+		}
+	}
+	return cfg
+}
 
 func main() {
 	flag.Parse()
+	cfg := loadConfig()
+
+	if cfg.Interval < 1 {
+		cfg.Interval = *interval
+	}
+	if cfg.CollectorPort == "" {
+		cfg.CollectorPort = *collectorPort
+	}
+	discoverers, err := llama.NewDiscoverers(cfg.Discovery, cfg.CollectorPort)
+	if err != nil {
+		log.Fatal(err)
+	}
+	static := llama.NewStaticDiscoverer(cfg.Collectors, cfg.CollectorPort)
+	discoverers = append([]llama.Discoverer{static}, discoverers...)
 
-	// Make sure we have some collectors
-	collectors := strings.Split(*collectorHosts, ",")
-	if len(collectors) < 1 {
-		log.Fatal("No collectors provided; aborting")
+	// Make sure we have some collectors, or somewhere to find them
+	if len(discoverers) == 1 {
+		targets, _ := static.Discover()
+		if len(targets) < 1 {
+			log.Fatal("No collectors provided; aborting")
+		}
 	}
 
-	scraper, err := llama.NewScraper(collectors, *collectorPort, *influxdbHost, *influxdbPort, *influxdbUser, *influxdbPass, *influxdbDb)
+	writer, err := llama.NewWriters(cfg.Writers)
 	if err != nil {
-		log.Fatalln("Unable to create scraper: ", err)
+		log.Fatalln("Unable to create writers: ", err)
+	}
+	err = writer.Health()
+	if err != nil {
+		// Backends may come up later, so only warn about it
+		log.Println("Writer health check failed:", err)
+	}
+	scraper := llama.NewScraper(nil, cfg.CollectorPort, writer)
+	defer scraper.Close()
+	scraper.SetClientOptions(time.Duration(cfg.Client.Timeout)*time.Second,
+		int(cfg.Client.Retries), time.Duration(cfg.Client.RetryDelay)*time.Second,
+		cfg.Client.Gzip)
+	scraper.SetGRPC(cfg.Client.GRPC)
+	scraper.SetConcurrency(int(cfg.Concurrency))
+	discovery := llama.NewDiscovery(discoverers,
+		time.Duration(cfg.Discovery.Refresh)*time.Second, scraper)
+	discovery.Run()
+	defer discovery.Stop()
+	if cfg.APIAddr != "" {
+		api := llama.NewScraperAPI(scraper, cfg.APIAddr)
+		api.Run()
+		defer api.Stop()
 	}
 
 	// Setup a timer, and perform collections each tick
-	log.Println("Starting ticker for collection every", *interval, "seconds")
-	for now := range time.Tick(time.Duration(*interval) * time.Second) {
+	log.Println("Starting ticker for collection every", cfg.Interval, "seconds")
+	for now := range time.Tick(time.Duration(cfg.Interval) * time.Second) {
 		log.Println("Starting collection at tick:", now)
-		scraper.Run()
+		// Run in the background, so a slow cycle doesn't delay the next
+		// tick. Cycles that would overlap are skipped by the scraper.
+		go scraper.Run()
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"golang.org/x/time/rate"
 	"io/ioutil"
 	"log"
+	"sync"
 	"time"
 )
 
@@ -24,18 +25,27 @@ var dstPort = flag.Int64("llama.dst-port", 8100, "Port to send probes to. Only a
 // Collector reads a YAML configuration, performs UDP probe tests against
 // targets, and provides summaries of the results via a JSON HTTP API.
 type Collector struct {
-	cfg *CollectorConfig
-	ts  TagSet
-	api *API
+	cfg  *CollectorConfig
+	ts   TagSet
+	tags *SharedTagSet // Shared by everything applying tags to summaries
+	api  *API
+	// Only set if a gRPC bind address is configured
+	grpc *GRPCAPI
 	// TODO(dmar): Might want these to be named, for clarity in logging
 	//      and doing any restarting.
-	runners []*TestRunner
+	runners []*TestRunner // In the same order as the tests in the config
+	// Held while the config or runners are changed, by reloads or the API
+	mutex sync.Mutex
 	// TODO(dmar): Keeping cbc around here feels dirty and unneeded, as it's
 	//      only temporarily needed during setup. But it does the trick for
 	//      now. Perhaps find a cleaner way in the future.
 	cbc chan *Probe
 	s   *Summarizer
 	rh  []*ResultHandler
+	// Only set if alerting rules are configured
+	alerter *Alerter
+	// Only set if outputs are configured
+	outputs *IntervalWriter
 }
 
 // LoadConfig loads the collector's configuration from CLI flag if provided,
@@ -116,6 +126,11 @@ func (c *Collector) loadConfigFromData(data []byte) error {
 	if err != nil {
 		return err
 	}
+	// Targets changed through the API replace those in the config
+	err = loadTargetsFile(cfg)
+	if err != nil {
+		return err
+	}
 	// Save it and we're done
 	c.cfg = cfg
 	return nil
@@ -128,8 +143,31 @@ func (c *Collector) SetupAPI() {
 	if c.s == nil {
 		c.SetupSummarizer()
 	}
-	c.api = NewAPI(c.s, c.ts, c.cfg.API.Bind)
-
+	c.api = NewAPI(c.s, c.tags, c.cfg.API.Bind)
+	prom, err := NewPromExporter(c.cfg.API.Metrics)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.api.SetPromExporter(prom)
+	if c.alerter != nil {
+		c.api.SetAlerter(c.alerter)
+	}
+	if c.cfg.API.Targets.Token != "" {
+		c.api.SetTargetManager(c, c.cfg.API.Targets.Token)
+	}
+	if c.cfg.API.Probes.Token != "" {
+		maxJobs := c.cfg.API.Probes.MaxJobs
+		if maxJobs == 0 {
+			maxJobs = DefaultProbeJobs
+		}
+		c.api.SetProber(NewProber(maxJobs, DefaultProbeHistory), c.cfg.API.Probes.Token)
+	}
+	if c.cfg.API.GRPCBind != "" {
+		c.grpc = NewGRPCAPI(c.s, c.tags, c.cfg.API.GRPCBind)
+		if c.cfg.API.Targets.Token != "" {
+			c.grpc.SetTargetManager(c, c.cfg.API.Targets.Token)
+		}
+	}
 }
 
 // SetupTagSet loads the tags for targets, based on the config, that will be
@@ -137,6 +175,10 @@ func (c *Collector) SetupAPI() {
 func (c *Collector) SetupTagSet() {
 	log.Println("Setting up tag set")
 	c.ts = c.cfg.Targets.TagSet()
+	// Only create the shared version once, as it's updated on reload
+	if c.tags == nil {
+		c.tags = NewSharedTagSet(c.ts)
+	}
 }
 
 // SetupTestRunner takes parameters from the loaded config, and creates the
@@ -144,6 +186,7 @@ func (c *Collector) SetupTagSet() {
 func (c *Collector) SetupTestRunner(test TestConfig) {
 	rl := c.createRateLimiter(test.RateLimit)
 	runner := NewTestRunner(c.cbc, rl)
+	runner.SetName(test.ID())
 	// TODO(dmar): This could hit a runtime error if the TargetSet name
 	// doesn't exist. So might want to break this into two parts.
 	targets, err := c.cfg.Targets[test.Targets].ListResolvedTargets()
@@ -161,6 +204,7 @@ func (c *Collector) SetupTestRunners() {
 	// Don't recreate the channel on reload, only create once
 	if c.cbc == nil {
 		c.cbc = make(chan *Probe, DEFAULT_CHANNEL_SIZE)
+		registerChannelDepth("probes", func() int { return len(c.cbc) }, cap(c.cbc))
 	}
 	// If there are already test runners, they should be removed
 	if len(c.runners) > 0 {
@@ -214,13 +258,44 @@ func (c *Collector) SetupSummarizer() {
 	log.Println("Setting up summarizer")
 	// Setup the summarizer and result handlers
 	resultChan := make(chan *Result, DEFAULT_CHANNEL_SIZE)
+	registerChannelDepth("results", func() int { return len(resultChan) }, cap(resultChan))
 	c.s = NewSummarizer(
 		resultChan,
 		time.Duration(c.cfg.Summarization.Interval)*time.Second,
+		c.summarizerDelay(),
+		int(c.cfg.Summarization.History),
 	)
+	c.s.SetBaseline(NewBaseline(c.cfg.Summarization.Baseline))
 	c.setupResultHandlers(resultChan)
 }
 
+// summarizerDelay determines how long the Summarizer should hold intervals
+// open for, so that all probes sent within them have completed or expired.
+//
+// Ports use their timeout for both the cache expiration and cleanup rate, so
+// expiring a probe can take up to twice the timeout.
+func (c *Collector) summarizerDelay() time.Duration {
+	var timeout int64
+	for _, p := range c.cfg.Ports {
+		if p.Timeout > timeout {
+			timeout = p.Timeout
+		}
+	}
+	return 2*time.Duration(timeout)*time.Millisecond + DefaultSummarizerMargin
+}
+
+// registerChannelDepth registers metrics for how full the named channel is.
+// If these stay near capacity, the collector isn't keeping up, and probes
+// will expire before they can be handled.
+func registerChannelDepth(name string, depth func() int, capacity int) {
+	labels := Tags{"channel": name}
+	DefaultMetrics.GaugeFunc("llama_collector_channel_depth",
+		"Items waiting in the collector's internal channels.", labels,
+		func() float64 { return float64(depth()) })
+	DefaultMetrics.Gauge("llama_collector_channel_capacity",
+		"Capacity of the collector's internal channels.", labels).Set(float64(capacity))
+}
+
 // setupResultHandlers creates number of ResultHandlers defined by the config.
 func (c *Collector) setupResultHandlers(resultChan chan *Result) {
 	log.Println("Setting up", c.cfg.Summarization.Handlers, "result handlers")
@@ -230,6 +305,53 @@ func (c *Collector) setupResultHandlers(resultChan chan *Result) {
 	}
 }
 
+// SetupAlerter creates the Alerter, which checks summaries against rules and
+// sends notifications, if any rules are defined in the config.
+func (c *Collector) SetupAlerter() {
+	if len(c.cfg.Alerting.Rules) == 0 {
+		return
+	}
+	log.Println("Setting up alerter")
+	rules, err := NewAlertRules(c.cfg.Alerting.Rules)
+	if err != nil {
+		log.Fatal(err)
+	}
+	webhook, err := NewWebhook(c.cfg.Alerting.Webhook)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.alerter = NewAlerter(c.s.Subscribe(), c.tags, rules, webhook)
+}
+
+// SetupOutputs creates the IntervalWriter, which writes each summarized
+// interval to the outputs in the config, if any are defined.
+func (c *Collector) SetupOutputs() {
+	if len(c.cfg.Outputs) == 0 {
+		return
+	}
+	log.Println("Setting up outputs")
+	w, err := NewWriters(c.cfg.Outputs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.outputs = NewIntervalWriter(c.s.Subscribe(), c.tags, w)
+}
+
+// reloadAlertRules updates the rules on an existing Alerter.
+func (c *Collector) reloadAlertRules() {
+	if c.alerter == nil {
+		if len(c.cfg.Alerting.Rules) > 0 {
+			log.Println("Alerting rules added, but a restart is needed to enable alerting")
+		}
+		return
+	}
+	rules, err := NewAlertRules(c.cfg.Alerting.Rules)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.alerter.SetRules(rules)
+}
+
 // Setup is a generally wrapper around all of the other Setup* functions.
 func (c *Collector) Setup() {
 	// Ordering is important here, as some of these depend on elements
@@ -239,6 +361,8 @@ func (c *Collector) Setup() {
 	c.SetupTagSet()
 	c.SetupTestRunners()
 	c.SetupSummarizer()
+	c.SetupAlerter()
+	c.SetupOutputs()
 	c.SetupAPI()
 	log.Println("Collector setup complete")
 }
@@ -246,6 +370,9 @@ func (c *Collector) Setup() {
 // Reload causes the config to be reread, and test runners recreated
 func (c *Collector) Reload() {
 	log.Println("Reloading collector")
+	// Don't let the API change targets partway through
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	// This should be an atomic operation, so no prep needed
 	c.LoadConfig()
 	// Same here
@@ -268,8 +395,13 @@ func (c *Collector) Reload() {
 	//   the latest information each time, but keeping old data around.
 	//   This definitely isn't ideal, but sorting out what to keep or not is
 	//   non-trivial. So keep this as an improvement for the refactor.
-	log.Println("Updating TagSet on API")
-	c.api.MergeUpdateTagSet(c.ts)
+	log.Println("Updating shared TagSet")
+	c.tags.MergeUpdate(c.ts)
+	log.Println("Updating alerting rules")
+	c.reloadAlertRules()
+	if c.outputs == nil && len(c.cfg.Outputs) > 0 {
+		log.Println("Outputs added, but a restart is needed to enable them")
+	}
 	log.Println("Collector reload complete")
 }
 
@@ -278,8 +410,19 @@ func (c *Collector) Run() {
 	log.Println("Starting Collector")
 	// Start the API
 	c.api.Run()
+	if c.grpc != nil {
+		c.grpc.Run()
+	}
 	// Start the Summarizer
 	c.s.Run()
+	// Start the Alerter
+	if c.alerter != nil {
+		c.alerter.Run()
+	}
+	// Start writing to outputs
+	if c.outputs != nil {
+		c.outputs.Run()
+	}
 	// Start the ResultHandlers
 	for _, rh := range c.rh {
 		rh.Run()
@@ -302,9 +445,20 @@ func (c *Collector) Stop() {
 	for _, rh := range c.rh {
 		rh.Stop()
 	}
+	// Stop the Alerter
+	if c.alerter != nil {
+		c.alerter.Stop()
+	}
+	// Stop writing to outputs
+	if c.outputs != nil {
+		c.outputs.Stop()
+	}
 	// Stop the Summarizer
 	c.s.Stop()
 	// Stop the API
 	c.api.Stop()
+	if c.grpc != nil {
+		c.grpc.Stop()
+	}
 	log.Println("All Collector components signaled to stop")
 }
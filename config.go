@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"net"
+	"time"
 )
 
 // A sensible default configuration for the collector in YAML
@@ -11,6 +12,7 @@ var defaultCollectorConfigYAML = `
 summarization:
     interval:   30
     handlers:   2
+    history:    10
 
 api:
     bind:   0.0.0.0:5000
@@ -73,11 +75,20 @@ type RateLimitsConfig map[string]RateLimitConfig
 // Ex. A `targets` value of "default" in the config would correspond to a
 // TargetsConfig key of "default" which contains the definitions of targets.
 type TestConfig struct {
+	Name      string `yaml:"name"`       // Optional, defaults to the value of Targets
 	Targets   string `yaml:"targets"`    // Should correspond with a TargetsConfig key
 	PortGroup string `yaml:"port_group"` // Should correspond with a PortGroupsConfig key
 	RateLimit string `yaml:"rate_limit"` // Should correspond with a RateLimitsConfig key
 }
 
+// ID provides the name used to identify the test, such as in metrics.
+func (test TestConfig) ID() string {
+	if test.Name != "" {
+		return test.Name
+	}
+	return test.Targets
+}
+
 // TestsConfig is a slice of TestConfig structs.
 type TestsConfig []TestConfig
 
@@ -88,9 +99,9 @@ type TestsConfig []TestConfig
 //      data being included in this config. Most of this can come from a base,
 //      and then be populated by MDB queries.
 type TargetConfig struct {
-	IP   string `yaml:"ip"`
-	Port int64  `yaml:"port"`
-	Tags Tags   `yaml:"tags"`
+	IP   string `yaml:"ip" json:"ip"`
+	Port int64  `yaml:"port" json:"port"`
+	Tags Tags   `yaml:"tags" json:"tags"`
 }
 
 // AddrString converts the tc into a string formated "IP:port" combo.
@@ -170,16 +181,75 @@ func (tc TargetsConfig) IntoTagSet(ts TagSet) {
 	}
 }
 
+// BaselineConfig describes the parameters for per-path baselines, which
+// summaries are scored against.
+type BaselineConfig struct {
+	Window    int64   `yaml:"window"`     // Intervals covered, or 0 to disable
+	Warmup    int64   `yaml:"warmup"`     // Intervals needed before scoring
+	Threshold float64 `yaml:"threshold"`  // Ratio considered anomalous
+	LossFloor float64 `yaml:"loss_floor"` // Added to loss percentages before comparing
+}
+
 // SummarizationConfig describes the parameters for setting up a Summarizer
 // and related ResultHandlers.
 type SummarizationConfig struct {
-	Interval int64 `yaml:"interval"`
-	Handlers int64 `yaml:"handlers"`
+	Interval int64          `yaml:"interval"`
+	Handlers int64          `yaml:"handlers"`
+	History  int64          `yaml:"history"` // Number of summarized intervals to retain
+	Baseline BaselineConfig `yaml:"baseline"`
+}
+
+// MetricsConfig describes how summaries are exposed as Prometheus metrics.
+type MetricsConfig struct {
+	Prefix        string   `yaml:"prefix"`         // Prepended to metric names
+	Labels        []string `yaml:"labels"`         // Tags to use as labels, or all if empty
+	InvalidLabels string   `yaml:"invalid_labels"` // Either "replace" or "drop"
+}
+
+// TargetsAPIConfig describes the API for changing the targets of tests while
+// the collector is running.
+type TargetsAPIConfig struct {
+	Token string `yaml:"token"` // Required by requests, and disabled if empty
+	File  string `yaml:"file"`  // Changes are saved to, and loaded from, here if set
+}
+
+// ProbesAPIConfig describes the API for running one-off probes from the
+// collector.
+type ProbesAPIConfig struct {
+	Token   string `yaml:"token"`    // Required by requests, and disabled if empty
+	MaxJobs int    `yaml:"max_jobs"` // Running at once, or DefaultProbeJobs if 0
 }
 
 // APIConfig describes the parameters for the JSON HTTP API.
 type APIConfig struct {
-	Bind string `yaml:"bind"`
+	Bind     string           `yaml:"bind"`
+	GRPCBind string           `yaml:"grpc_bind"` // For the gRPC API, if set
+	Metrics  MetricsConfig    `yaml:"metrics"`
+	Targets  TargetsAPIConfig `yaml:"targets"`
+	Probes   ProbesAPIConfig  `yaml:"probes"`
+}
+
+// WebhookConfig describes where and how alert notifications are sent.
+type WebhookConfig struct {
+	URL     string `yaml:"url"`
+	Format  string `yaml:"format"`  // Either "alertmanager" or "json"
+	Timeout int64  `yaml:"timeout"` // In seconds
+}
+
+// AlertRuleConfig describes a single alerting rule.
+//
+// Ex. An `expr` of "dst_region=west AND loss > 2% for 3 intervals"
+type AlertRuleConfig struct {
+	Name   string `yaml:"name"`
+	Expr   string `yaml:"expr"`
+	Labels Tags   `yaml:"labels"` // Added to notifications for the rule
+}
+
+// AlertingConfig describes the rules summaries are checked against, and how
+// notifications are sent when they match.
+type AlertingConfig struct {
+	Webhook WebhookConfig     `yaml:"webhook"`
+	Rules   []AlertRuleConfig `yaml:"rules"`
 }
 
 // CollectorConfig wraps all of the above structs/maps/slices and defines the
@@ -192,6 +262,53 @@ type CollectorConfig struct {
 	RateLimits    RateLimitsConfig    `yaml:"rate_limits"`
 	Tests         TestsConfig         `yaml:"tests"`
 	Targets       TargetsConfig       `yaml:"targets"`
+	Alerting      AlertingConfig      `yaml:"alerting"`
+	Outputs       []WriterConfig      `yaml:"outputs"` // Written to each interval
+}
+
+// WriterConfig describes a Writer for the scraper, by its registered type and
+// the options for that type.
+type WriterConfig struct {
+	Type    string        `yaml:"type"`
+	Options WriterOptions `yaml:"options"`
+}
+
+// ScraperConfig defines the overall configuration for a scraper, as an
+// alternative to CLI flags.
+type ScraperConfig struct {
+	Collectors    []string        `yaml:"collectors"`
+	CollectorPort string          `yaml:"collector_port"`
+	Interval      int64           `yaml:"interval"` // In seconds
+	Writers       []WriterConfig  `yaml:"writers"`
+	Discovery     DiscoveryConfig `yaml:"discovery"`
+	Client        ClientConfig    `yaml:"client"`
+	Concurrency   int64           `yaml:"concurrency"` // Max collectors pulled from at once, if > 0
+	APIAddr       string          `yaml:"api_addr"`    // For /status and /metrics, if set
+}
+
+// ClientConfig defines how the scraper makes requests to collectors.
+type ClientConfig struct {
+	Timeout    int64 `yaml:"timeout"` // In seconds, per attempt
+	Retries    int64 `yaml:"retries"`
+	RetryDelay int64 `yaml:"retry_delay"` // In seconds, doubled after each retry
+	Gzip       bool  `yaml:"gzip"`
+	GRPC       bool  `yaml:"grpc"` // Use the gRPC API of collectors, on the collector port
+}
+
+// DiscoveryConfig defines where the scraper finds collectors, in addition to
+// those listed in the ScraperConfig.
+type DiscoveryConfig struct {
+	Files   []string             `yaml:"files"` // JSON or YAML lists of collectors
+	DNS     []DNSDiscoveryConfig `yaml:"dns"`
+	Refresh int64                `yaml:"refresh"` // In seconds
+}
+
+// DNSDiscoveryConfig defines a DNS name resolving to collectors.
+type DNSDiscoveryConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"` // "srv" or "a"
+	Port string `yaml:"port"` // For "a" records, defaults to the collector_port
+	Tags Tags   `yaml:"tags"`
 }
 
 //
@@ -216,6 +333,28 @@ func NewCollectorConfig(data []byte) (*CollectorConfig, error) {
 	return cc, nil
 }
 
+// NewDefaultClientConfig provides the defaults for collector clients.
+func NewDefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Timeout:    int64(DefaultClientTimeout / time.Second),
+		Retries:    DefaultClientRetries,
+		RetryDelay: int64(DefaultClientRetryDelay / time.Second),
+		Gzip:       true,
+	}
+}
+
+// NewScraperConfig provides a parsed ScraperConfig based on the provided data.
+//
+// `data` is expected to be a byte slice version of a YAML ScraperConfig.
+func NewScraperConfig(data []byte) (*ScraperConfig, error) {
+	sc := &ScraperConfig{Client: NewDefaultClientConfig(), APIAddr: DefaultScraperAPIAddr}
+	err := yaml.Unmarshal(data, sc)
+	if err != nil {
+		return sc, fmt.Errorf("Failed to parse scraper config: %s", err)
+	}
+	return sc, nil
+}
+
 // LegacyCollectorConfig is for backward compatibility with the existing LLAMA
 // config and represents only a map of targets to tags.
 type LegacyCollectorConfig map[string]map[string]string
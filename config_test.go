@@ -1,6 +1,7 @@
 package llama
 
 import (
+	"io/ioutil"
 	"testing"
 )
 
@@ -153,3 +154,50 @@ func TestLegacyCollectorConfigToDefaultCollectorConfig(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestNewScraperConfig(t *testing.T) {
+	data, err := ioutil.ReadFile("configs/scraper_example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := NewScraperConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.Collectors) != 2 || sc.CollectorPort != "5000" || sc.Interval != 30 {
+		t.Error("Scraper config parsed incorrectly:", sc)
+	}
+	if len(sc.Writers) != 1 || sc.Writers[0].Type != "influxdb" {
+		t.Fatal("Writers parsed incorrectly:", sc.Writers)
+	}
+	// Numbers should still be usable as string options
+	if sc.Writers[0].Options["port"] != "5086" {
+		t.Error("Expected port option of 5086, got", sc.Writers[0].Options["port"])
+	}
+	if sc.Client != NewDefaultClientConfig() {
+		t.Error("Client config parsed incorrectly:", sc.Client)
+	}
+	// Defaults are used for anything not set
+	sc, err = NewScraperConfig([]byte("client:\n    retries: 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Client.Retries != 0 || !sc.Client.Gzip || sc.Client.Timeout != 10 {
+		t.Error("Expected defaults for the unset client config, got", sc.Client)
+	}
+}
+
+func TestCollectorConfigOutputs(t *testing.T) {
+	data, err := ioutil.ReadFile("configs/complex_example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc, err := NewCollectorConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cc.Outputs) != 1 || cc.Outputs[0].Type != "file" ||
+		cc.Outputs[0].Options["gzip"] != "true" {
+		t.Error("Outputs parsed incorrectly:", cc.Outputs)
+	}
+}
@@ -0,0 +1,330 @@
+// Discovery of the collectors the scraper pulls from, so they can come and
+// go without restarting it.
+package llama
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DNS record types used for discovery
+const (
+	DiscoverySRV = "srv"
+	DiscoveryA   = "a"
+)
+
+// DefaultDiscoveryRefresh is how often discovery is refreshed, if not set
+const DefaultDiscoveryRefresh = 60 * time.Second
+
+// CollectorTarget is a collector for the scraper to pull from, along with
+// tags to add to the points pulled from it.
+type CollectorTarget struct {
+	Host string `yaml:"host" json:"host"`
+	Port string `yaml:"port" json:"port"`
+	Tags Tags   `yaml:"tags" json:"tags"`
+}
+
+// Addr provides the target as host:port, which identifies it.
+func (t CollectorTarget) Addr() string {
+	return net.JoinHostPort(t.Host, t.Port)
+}
+
+// Discoverer is implemented by sources of collectors.
+type Discoverer interface {
+	// Discover provides the current set of collectors.
+	Discover() ([]CollectorTarget, error)
+	// String describes the source, for logging.
+	String() string
+}
+
+// StaticDiscoverer always provides the same collectors, such as those
+// provided as flags.
+type StaticDiscoverer struct {
+	targets []CollectorTarget
+}
+
+// Discover provides the static collectors.
+func (d *StaticDiscoverer) Discover() ([]CollectorTarget, error) {
+	return d.targets, nil
+}
+
+func (d *StaticDiscoverer) String() string {
+	return "static"
+}
+
+// NewStaticDiscoverer creates a StaticDiscoverer for the hosts, all on
+// `port`. Empty hosts are ignored.
+func NewStaticDiscoverer(hosts []string, port string) *StaticDiscoverer {
+	d := &StaticDiscoverer{}
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host != "" {
+			d.targets = append(d.targets, CollectorTarget{Host: host, Port: port})
+		}
+	}
+	return d
+}
+
+// FileDiscoverer reads collectors from a JSON or YAML file, which is a list
+// of CollectorTargets. The file is only parsed again once it's modified.
+//
+// Ex. `[{"host": "10.0.0.1", "port": "5000", "tags": {"dc": "east"}}]`
+type FileDiscoverer struct {
+	path        string
+	defaultPort string
+	modified    time.Time
+	targets     []CollectorTarget
+}
+
+// Discover provides the collectors from the file.
+func (d *FileDiscoverer) Discover() ([]CollectorTarget, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return nil, err
+	}
+	if d.targets != nil && info.ModTime().Equal(d.modified) {
+		return d.targets, nil
+	}
+	data, err := ioutil.ReadFile(d.path)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := parseCollectorTargets(d.path, data, d.defaultPort)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Loaded", len(targets), "collectors from", d.path)
+	d.modified = info.ModTime()
+	d.targets = targets
+	return targets, nil
+}
+
+func (d *FileDiscoverer) String() string {
+	return "file " + d.path
+}
+
+// parseCollectorTargets parses a list of targets as JSON, if the path ends in
+// `.json`, or otherwise as YAML. Targets without a port use `defaultPort`.
+func parseCollectorTargets(path string, data []byte, defaultPort string) ([]CollectorTarget, error) {
+	targets := []CollectorTarget{}
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, &targets)
+	} else {
+		err = yaml.Unmarshal(data, &targets)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %v", path, err)
+	}
+	for i := range targets {
+		if targets[i].Host == "" {
+			return nil, fmt.Errorf("Collector without a host in %s", path)
+		}
+		if targets[i].Port == "" {
+			targets[i].Port = defaultPort
+		}
+	}
+	return targets, nil
+}
+
+// NewFileDiscoverer creates a FileDiscoverer for the file at `path`, using
+// `defaultPort` for any collectors without one.
+func NewFileDiscoverer(path string, defaultPort string) *FileDiscoverer {
+	return &FileDiscoverer{path: path, defaultPort: defaultPort}
+}
+
+// DNSDiscoverer resolves collectors from DNS, either from SRV records which
+// include the port, or A/AAAA records using a fixed port.
+type DNSDiscoverer struct {
+	name       string
+	recordType string
+	port       string
+	tags       Tags
+	lookupSRV  func(service, proto, name string) (string, []*net.SRV, error)
+	lookupHost func(host string) ([]string, error)
+}
+
+// Discover resolves the name to collectors.
+func (d *DNSDiscoverer) Discover() ([]CollectorTarget, error) {
+	var targets []CollectorTarget
+	if d.recordType == DiscoverySRV {
+		_, records, err := d.lookupSRV("", "", d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, srv := range records {
+			targets = append(targets, CollectorTarget{
+				Host: strings.TrimSuffix(srv.Target, "."),
+				Port: strconv.Itoa(int(srv.Port)),
+				Tags: d.tags,
+			})
+		}
+	} else {
+		addrs, err := d.lookupHost(d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			targets = append(targets, CollectorTarget{Host: addr, Port: d.port, Tags: d.tags})
+		}
+	}
+	return targets, nil
+}
+
+func (d *DNSDiscoverer) String() string {
+	return fmt.Sprintf("dns %s %s", d.recordType, d.name)
+}
+
+// NewDNSDiscoverer creates a DNSDiscoverer for `name`, with `recordType`
+// being DiscoverySRV or DiscoveryA. `port` is required for A records, and
+// `tags` are added to all of the collectors.
+func NewDNSDiscoverer(name string, recordType string, port string, tags Tags) (*DNSDiscoverer, error) {
+	if name == "" {
+		return nil, errors.New("A DNS name is required for discovery")
+	}
+	recordType = strings.ToLower(recordType)
+	if recordType == "" {
+		recordType = DiscoverySRV
+	}
+	if recordType != DiscoverySRV && recordType != DiscoveryA {
+		return nil, fmt.Errorf("Unknown DNS record type for discovery: %s", recordType)
+	}
+	if recordType == DiscoveryA && port == "" {
+		return nil, fmt.Errorf("A port is required for discovery via A records: %s", name)
+	}
+	return &DNSDiscoverer{
+		name:       name,
+		recordType: recordType,
+		port:       port,
+		tags:       tags,
+		lookupSRV:  net.LookupSRV,
+		lookupHost: net.LookupHost,
+	}, nil
+}
+
+// ParseDNSDiscoveryFlag parses DNS discovery provided on the command line, as
+// `<type>:<name>`, such as `srv:_llama._tcp.example.com` or
+// `a:collectors.example.com`.
+func ParseDNSDiscoveryFlag(value string) (DNSDiscoveryConfig, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return DNSDiscoveryConfig{}, fmt.Errorf("Expected <type>:<name> for DNS discovery, got: %s", value)
+	}
+	return DNSDiscoveryConfig{Type: parts[0], Name: parts[1]}, nil
+}
+
+// NewDiscoverers creates the Discoverers for the config. DNS entries without
+// a port use `defaultPort`, as do entries in files.
+func NewDiscoverers(cfg DiscoveryConfig, defaultPort string) ([]Discoverer, error) {
+	var discoverers []Discoverer
+	for _, path := range cfg.Files {
+		discoverers = append(discoverers, NewFileDiscoverer(path, defaultPort))
+	}
+	for _, dns := range cfg.DNS {
+		port := dns.Port
+		if port == "" {
+			port = defaultPort
+		}
+		d, err := NewDNSDiscoverer(dns.Name, dns.Type, port, dns.Tags)
+		if err != nil {
+			return nil, err
+		}
+		discoverers = append(discoverers, d)
+	}
+	return discoverers, nil
+}
+
+// Discovery periodically refreshes the collectors used by a Scraper from
+// several Discoverers.
+//
+// If a Discoverer fails, the collectors it last provided are kept, so a
+// DNS or file hiccup doesn't drop them.
+type Discovery struct {
+	discoverers []Discoverer
+	scraper     *Scraper
+	refresh     time.Duration
+	mutex       sync.Mutex
+	last        map[Discoverer][]CollectorTarget
+	stop        chan bool
+}
+
+// Refresh queries all of the Discoverers, and updates the Scraper with the
+// combined collectors. Where several provide the same host:port, the tags
+// from the earlier ones take precedence.
+func (d *Discovery) Refresh() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	seen := make(map[string]bool)
+	var targets []CollectorTarget
+	for _, discoverer := range d.discoverers {
+		found, err := discoverer.Discover()
+		if err != nil {
+			log.Println("Discovery failed for", discoverer, "- keeping previous collectors:", err)
+			found = d.last[discoverer]
+		} else {
+			d.last[discoverer] = found
+		}
+		for _, target := range found {
+			if seen[target.Addr()] {
+				continue
+			}
+			seen[target.Addr()] = true
+			targets = append(targets, target)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Addr() < targets[j].Addr() })
+	d.scraper.SetCollectors(targets)
+}
+
+// Run starts refreshing periodically, after an initial refresh.
+func (d *Discovery) Run() {
+	d.Refresh()
+	go d.run()
+}
+
+func (d *Discovery) run() {
+	ticker := time.NewTicker(d.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			log.Println("Stopping discovery")
+			return
+		case <-ticker.C:
+			d.Refresh()
+		}
+	}
+}
+
+// Stop will stop refreshing.
+func (d *Discovery) Stop() {
+	close(d.stop)
+}
+
+// NewDiscovery creates a Discovery updating `scraper` from the
+// Discoverers every `refresh` (DefaultDiscoveryRefresh if < 1).
+func NewDiscovery(discoverers []Discoverer, refresh time.Duration, scraper *Scraper) *Discovery {
+	if refresh < 1 {
+		refresh = DefaultDiscoveryRefresh
+	}
+	return &Discovery{
+		discoverers: discoverers,
+		scraper:     scraper,
+		refresh:     refresh,
+		last:        make(map[Discoverer][]CollectorTarget),
+		stop:        make(chan bool),
+	}
+}
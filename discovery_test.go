@@ -0,0 +1,182 @@
+package llama
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// mockDiscoverer provides `targets`, or fails if `err` is set.
+type mockDiscoverer struct {
+	targets []CollectorTarget
+	err     error
+}
+
+func (m *mockDiscoverer) Discover() ([]CollectorTarget, error) {
+	return m.targets, m.err
+}
+
+func (m *mockDiscoverer) String() string {
+	return "mock"
+}
+
+func TestFileDiscoverer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llama-discovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The example should always parse
+	d := NewFileDiscoverer("configs/collectors_example.yaml", "5000")
+	targets, err := d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[1].Port != "5000" || targets[0].Tags["dc"] != "east" {
+		t.Error("Example parsed incorrectly:", targets)
+	}
+
+	path := filepath.Join(dir, "collectors.json")
+	err = ioutil.WriteFile(path, []byte(`[{"host": "10.0.0.1", "port": "6000", "tags": {"dc": "east"}}]`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = NewFileDiscoverer(path, "5000")
+	targets, err = d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].Addr() != "10.0.0.1:6000" {
+		t.Error("JSON parsed incorrectly:", targets)
+	}
+	// Changes are picked up once the file is modified
+	err = ioutil.WriteFile(path, []byte(`[{"host": "10.0.0.1"}, {"host": "10.0.0.2"}]`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	HandleMinorError(os.Chtimes(path, later, later))
+	targets, err = d.Discover()
+	if err != nil || len(targets) != 2 || targets[0].Port != "5000" {
+		t.Error("Expected the modified file to be re-read, got", targets, err)
+	}
+
+	err = ioutil.WriteFile(path, []byte(`[{"port": "5000"}]`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	HandleMinorError(os.Chtimes(path, later.Add(time.Minute), later.Add(time.Minute)))
+	_, err = d.Discover()
+	if err == nil {
+		t.Error("Expected an error for a collector without a host")
+	}
+}
+
+func TestDNSDiscoverer(t *testing.T) {
+	d, err := NewDNSDiscoverer("_llama._tcp.example.com", "SRV", "", Tags{"source": "dns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		if name != "_llama._tcp.example.com" {
+			t.Error("Looked up the wrong name:", name)
+		}
+		return "", []*net.SRV{{Target: "c1.example.com.", Port: 5001}}, nil
+	}
+	targets, err := d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].Addr() != "c1.example.com:5001" || targets[0].Tags["source"] != "dns" {
+		t.Error("SRV resolved incorrectly:", targets)
+	}
+
+	d, err = NewDNSDiscoverer("collectors.example.com", DiscoveryA, "5000", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.lookupHost = func(host string) ([]string, error) {
+		return []string{"10.0.0.1", "fe80::1"}, nil
+	}
+	targets, err = d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[1].Addr() != "[fe80::1]:5000" {
+		t.Error("A resolved incorrectly:", targets)
+	}
+
+	_, err = NewDNSDiscoverer("collectors.example.com", DiscoveryA, "", nil)
+	if err == nil {
+		t.Error("Expected an error for A records without a port")
+	}
+	_, err = NewDNSDiscoverer("collectors.example.com", "mx", "", nil)
+	if err == nil {
+		t.Error("Expected an error for an unknown record type")
+	}
+}
+
+func TestParseDNSDiscoveryFlag(t *testing.T) {
+	cfg, err := ParseDNSDiscoveryFlag("srv:_llama._tcp.example.com")
+	if err != nil || cfg.Type != "srv" || cfg.Name != "_llama._tcp.example.com" {
+		t.Error("Parsed incorrectly:", cfg, err)
+	}
+	_, err = ParseDNSDiscoveryFlag("example.com")
+	if err == nil {
+		t.Error("Expected an error without a type")
+	}
+}
+
+func TestDiscoveryRefresh(t *testing.T) {
+	scraper := NewScraper([]string{"10.0.0.1"}, "5000", &MockWriter{})
+	original := scraper.Collectors()[0]
+	static := NewStaticDiscoverer([]string{"10.0.0.1"}, "5000")
+	dynamic := &mockDiscoverer{targets: []CollectorTarget{
+		{Host: "10.0.0.1", Port: "5000", Tags: Tags{"ignored": "true"}},
+		{Host: "10.0.0.2", Port: "5000", Tags: Tags{"dc": "west"}},
+	}}
+	d := NewDiscovery([]Discoverer{static, dynamic}, 0, scraper)
+	d.Refresh()
+	collectors := scraper.Collectors()
+	if len(collectors) != 2 {
+		t.Fatal("Expected 2 collectors, got", len(collectors))
+	}
+	if collectors[0] != original {
+		t.Error("Expected the existing collector to be kept")
+	}
+	if len(scraper.tags["10.0.0.1:5000"]) != 0 || scraper.tags["10.0.0.2:5000"]["dc"] != "west" {
+		t.Error("Expected tags from the first discoverer to win, got", scraper.tags)
+	}
+
+	// Failures keep what was last discovered
+	dynamic.err = errors.New("no DNS")
+	dynamic.targets = nil
+	d.Refresh()
+	if len(scraper.Collectors()) != 2 {
+		t.Error("Expected collectors to be kept after a failure, got", scraper.Collectors())
+	}
+	// But they're removed once they're really gone
+	dynamic.err = nil
+	d.Refresh()
+	if len(scraper.Collectors()) != 1 {
+		t.Error("Expected the collector to be removed, got", scraper.Collectors())
+	}
+}
+
+func TestScraperAddTags(t *testing.T) {
+	scraper := NewScraper(nil, "5000", &MockWriter{})
+	scraper.SetCollectors([]CollectorTarget{
+		{Host: "10.0.0.1", Port: "5000", Tags: Tags{"dc": "east", "src_ip": "other"}},
+	})
+	collector := scraper.Collectors()[0]
+	points := Points{DataPoint{Tags: Tags{"src_ip": "10.0.0.1"}}}
+	scraper.addTags(collector, points)
+	if points[0].Tags["dc"] != "east" || points[0].Tags["src_ip"] != "10.0.0.1" {
+		t.Error("Tags added incorrectly:", points[0].Tags)
+	}
+}
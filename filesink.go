@@ -0,0 +1,394 @@
+// FileSink writes points to local files, as an audit trail that doesn't
+// depend on any database being available.
+package llama
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Formats for FileSink
+const (
+	FileSinkJSONL = "jsonl"
+	FileSinkCSV   = "csv"
+)
+
+// Defaults for FileSink
+const (
+	DefaultFileSinkPrefix  = "llama"
+	DefaultFileSinkMaxSize = 100 * 1024 * 1024 // Bytes
+)
+
+// fileSinkTimeFormat is used in file names, and sorts chronologically
+const fileSinkTimeFormat = "20060102T150405.000Z"
+
+// fileSinkTimePattern matches times formatted with fileSinkTimeFormat
+const fileSinkTimePattern = `\d{8}T\d{6}\.\d{3}Z`
+
+// fileSinkCSVHeader is the header for CSV files, which have a row per field
+// of each point, with tags as a JSON object.
+var fileSinkCSVHeader = []string{
+	"time", "interval_id", "interval_start", "interval_end", "measurement",
+	"field", "value", "tags",
+}
+
+// FileSink appends points to files as JSON Lines or CSV, rotating them by
+// size and age, optionally compressing them after rotation, and removing old
+// ones.
+type FileSink struct {
+	mutex     sync.Mutex
+	dir       string
+	prefix    string
+	format    string
+	maxSize   int64          // Rotate once the file is at least this large
+	maxAge    time.Duration  // Rotate once the file is this old, if > 0
+	compress  bool           // Gzip files after they're rotated
+	retention time.Duration  // Remove rotated files older than this, if > 0
+	maxFiles  int            // Keep at most this many rotated files, if > 0
+	names     *regexp.Regexp // Matches the names of this sink's files
+	file      *os.File
+	size      int64
+	opened    time.Time
+}
+
+// BatchWrite appends the points to the current file, rotating it first if
+// it's too old, and afterwards if it's too big.
+func (fs *FileSink) BatchWrite(points Points) error {
+	data, err := fs.encode(points)
+	if err != nil {
+		return fmt.Errorf("Failed to encode points: %v", err)
+	}
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if fs.file != nil && fs.maxAge > 0 && time.Since(fs.opened) >= fs.maxAge {
+		err = fs.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	if fs.file == nil {
+		err = fs.open()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := fs.file.Write(data)
+	fs.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("Failed to write to %s: %v", fs.file.Name(), err)
+	}
+	err = fs.file.Sync()
+	if err != nil {
+		return err
+	}
+	if fs.size >= fs.maxSize {
+		return fs.rotate()
+	}
+	return nil
+}
+
+// encode converts the points to the sink's format.
+func (fs *FileSink) encode(points Points) ([]byte, error) {
+	var buf bytes.Buffer
+	if fs.format == FileSinkJSONL {
+		encoder := json.NewEncoder(&buf)
+		for _, dp := range points {
+			err := encoder.Encode(dp)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	}
+	w := csv.NewWriter(&buf)
+	for _, dp := range points {
+		tags, err := json.Marshal(dp.Tags)
+		if err != nil {
+			return nil, err
+		}
+		fields := make([]string, 0, len(dp.Fields))
+		for field := range dp.Fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			err = w.Write([]string{
+				formatFileSinkTime(dp.Time),
+				strconv.FormatInt(dp.IntervalID, 10),
+				formatFileSinkTime(dp.IntervalStart),
+				formatFileSinkTime(dp.IntervalEnd),
+				dp.Measurement,
+				field,
+				strconv.FormatFloat(float64(dp.Fields[field]), 'f', -1, 64),
+				string(tags),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// formatFileSinkTime formats times for CSV, leaving unset times empty.
+func formatFileSinkTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// open creates a new file to write to.
+func (fs *FileSink) open() error {
+	fs.opened = time.Now()
+	// Rotating quickly can reuse a timestamp, and compressing would then
+	// clobber the earlier file, so number them to keep names unique.
+	var path string
+	for seq := 0; ; seq++ {
+		name := fmt.Sprintf("%s-%s-%03d.%s", fs.prefix,
+			fs.opened.UTC().Format(fileSinkTimeFormat), seq, fs.format)
+		path = filepath.Join(fs.dir, name)
+		if !fileExists(path) && !fileExists(path+".gz") {
+			break
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to open %s: %v", path, err)
+	}
+	fs.file = file
+	fs.size = 0
+	if fs.format == FileSinkCSV {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		HandleMinorError(w.Write(fileSinkCSVHeader))
+		w.Flush()
+		n, err := fs.file.Write(buf.Bytes())
+		fs.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileExists determines if anything exists at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// rotate closes the current file, compresses it if configured to, and then
+// applies retention to the rotated files. The next write opens a new file.
+func (fs *FileSink) rotate() error {
+	if fs.file == nil {
+		return nil
+	}
+	path := fs.file.Name()
+	err := fs.file.Close()
+	fs.file = nil
+	fs.size = 0
+	if err != nil {
+		return fmt.Errorf("Failed to close %s: %v", path, err)
+	}
+	if fs.compress {
+		err = gzipFile(path)
+		if err != nil {
+			// Leave it uncompressed, rather than losing anything
+			log.Println("Failed to compress", path, "-", err)
+		}
+	}
+	fs.applyRetention()
+	return nil
+}
+
+// gzipFile compresses the file at path to `path.gz`, and then removes the
+// original.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+// rotatedFiles provides the paths of files that have been rotated, oldest
+// first.
+func (fs *FileSink) rotatedFiles() ([]string, error) {
+	infos, err := ioutil.ReadDir(fs.dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, info := range infos {
+		name := info.Name()
+		// Other sinks may share the directory, with a prefix starting
+		// with this one's, so only names it would have used are matched
+		if info.IsDir() || !fs.names.MatchString(name) {
+			continue
+		}
+		path := filepath.Join(fs.dir, name)
+		if fs.file != nil && path == fs.file.Name() {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	// The timestamp in the name sorts chronologically
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// applyRetention removes rotated files that are too old, or beyond the
+// maximum number to keep.
+func (fs *FileSink) applyRetention() {
+	if fs.retention <= 0 && fs.maxFiles <= 0 {
+		return
+	}
+	paths, err := fs.rotatedFiles()
+	if err != nil {
+		log.Println("Failed to list files for retention:", err)
+		return
+	}
+	for i, path := range paths {
+		remove := fs.maxFiles > 0 && len(paths)-i > fs.maxFiles
+		if !remove && fs.retention > 0 {
+			info, err := os.Stat(path)
+			remove = err == nil && time.Since(info.ModTime()) > fs.retention
+		}
+		if remove {
+			err = os.Remove(path)
+			HandleMinorError(err)
+		}
+	}
+}
+
+// Close closes and rotates the current file.
+func (fs *FileSink) Close() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.rotate()
+}
+
+// Health checks that the directory exists.
+func (fs *FileSink) Health() error {
+	info, err := os.Stat(fs.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("Not a directory: %s", fs.dir)
+	}
+	return nil
+}
+
+// NewFileSink creates a FileSink writing to files in `dir`, which is created
+// if needed.
+//
+// `format` is FileSinkJSONL (the default) or FileSinkCSV. Files are rotated
+// when they reach `maxSize` bytes, or are `maxAge` old if that's > 0. Rotated
+// files are gzipped if `compress` is true, and removed once older than
+// `retention` or more than `maxFiles` exist, if those are > 0.
+func NewFileSink(dir string, prefix string, format string, maxSize int64,
+	maxAge time.Duration, compress bool, retention time.Duration,
+	maxFiles int) (*FileSink, error) {
+	if dir == "" {
+		return nil, errors.New("A directory is required")
+	}
+	if prefix == "" {
+		prefix = DefaultFileSinkPrefix
+	}
+	if format == "" {
+		format = FileSinkJSONL
+	}
+	if format != FileSinkJSONL && format != FileSinkCSV {
+		return nil, fmt.Errorf("Unknown format: %s", format)
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultFileSinkMaxSize
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Creating file sink in", dir)
+	return &FileSink{
+		dir:       dir,
+		prefix:    prefix,
+		format:    format,
+		maxSize:   maxSize,
+		maxAge:    maxAge,
+		compress:  compress,
+		retention: retention,
+		maxFiles:  maxFiles,
+		names: regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + "-" +
+			fileSinkTimePattern + `-\d{3,}\.` + regexp.QuoteMeta(format) +
+			`(\.gz)?$`),
+	}, nil
+}
+
+// newFileSinkFromOptions creates a FileSink from WriterOptions.
+func newFileSinkFromOptions(opts WriterOptions) (Writer, error) {
+	maxSize, err := opts.Int("max_size", DefaultFileSinkMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	maxAge, err := opts.Duration("max_age", 0)
+	if err != nil {
+		return nil, err
+	}
+	compress, err := opts.Bool("gzip", false)
+	if err != nil {
+		return nil, err
+	}
+	retention, err := opts.Duration("retention", 0)
+	if err != nil {
+		return nil, err
+	}
+	maxFiles, err := opts.Int("max_files", 0)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileSink(opts.String("path", ""), opts.String("prefix", ""),
+		opts.String("format", ""), maxSize, maxAge, compress, retention,
+		int(maxFiles))
+}
+
+func init() {
+	RegisterWriter("file", newFileSinkFromOptions)
+}
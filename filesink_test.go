@@ -0,0 +1,196 @@
+package llama
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFileSink provides a FileSink in a new temporary directory, which
+// should be removed when done.
+func newTestFileSink(t *testing.T, format string, maxSize int64, compress bool,
+	maxFiles int) *FileSink {
+	dir, err := ioutil.TempDir("", "llama-filesink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := NewFileSink(dir, "", format, maxSize, 0, compress, 0, maxFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func TestFileSinkJSONL(t *testing.T) {
+	fs := newTestFileSink(t, "", 0, false, 0)
+	defer os.RemoveAll(fs.dir)
+	err := fs.BatchWrite(examplePoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := fs.file.Name()
+	err = fs.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var lines int
+	for scanner.Scan() {
+		var dp DataPoint
+		err = json.Unmarshal(scanner.Bytes(), &dp)
+		if err != nil {
+			t.Fatal("Failed to parse line:", err)
+		}
+		if !dp.Time.Equal(examplePoints[lines].Time) || dp.Fields["rtt"] != examplePoints[lines].Fields["rtt"] {
+			t.Error("Unexpected point:", dp)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Error("Expected 2 lines, got", lines)
+	}
+}
+
+func TestFileSinkCSV(t *testing.T) {
+	fs := newTestFileSink(t, FileSinkCSV, 0, false, 0)
+	defer os.RemoveAll(fs.dir)
+	err := fs.BatchWrite(examplePoints[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(fs.file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Header and a row per field
+	if len(rows) != 5 || rows[0][0] != "time" {
+		t.Fatal("Unexpected rows:", rows)
+	}
+	if rows[1][0] != "2018-01-02T19:50:24Z" || rows[1][5] != "loss" || rows[1][6] != "0" ||
+		rows[1][7] != `{"dst_metro":"xyz","src_metro":"abc"}` {
+		t.Error("Unexpected row:", rows[1])
+	}
+	HandleMinorError(fs.Close())
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	// Every write exceeds the size, so each is rotated
+	fs := newTestFileSink(t, "", 1, true, 2)
+	defer os.RemoveAll(fs.dir)
+	for i := 0; i < 4; i++ {
+		err := fs.BatchWrite(examplePoints)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths, err := fs.rotatedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Fatal("Expected 2 files to be retained, got", paths)
+	}
+	for _, path := range paths {
+		if !strings.HasSuffix(path, ".jsonl.gz") {
+			t.Error("Expected a compressed file, got", path)
+		}
+	}
+	file, err := os.Open(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil || strings.Count(string(data), "\n") != 2 {
+		t.Error("Unexpected contents:", string(data), err)
+	}
+}
+
+func TestFileSinkSharedDir(t *testing.T) {
+	fs := newTestFileSink(t, "", 1, false, 1)
+	defer os.RemoveAll(fs.dir)
+	// Another sink's files, whose prefix starts with this one's
+	other, err := NewFileSink(fs.dir, "llama-dc1", "", 1, 0, false, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		HandleMinorError(other.BatchWrite(examplePoints))
+		HandleMinorError(fs.BatchWrite(examplePoints))
+	}
+	paths, err := fs.rotatedFiles()
+	if err != nil || len(paths) != 1 || strings.Contains(paths[0], "dc1") {
+		t.Error("Expected only this sink's file to be retained, got", paths, err)
+	}
+	paths, err = other.rotatedFiles()
+	if err != nil || len(paths) != 2 {
+		t.Error("Expected the other sink's files to be kept, got", paths, err)
+	}
+}
+
+func TestFileSinkMaxAge(t *testing.T) {
+	fs := newTestFileSink(t, "", 0, false, 0)
+	defer os.RemoveAll(fs.dir)
+	fs.maxAge = time.Hour
+	HandleMinorError(fs.BatchWrite(examplePoints))
+	first := fs.file.Name()
+	fs.opened = fs.opened.Add(-2 * time.Hour)
+	HandleMinorError(fs.BatchWrite(examplePoints))
+	if fs.file.Name() == first {
+		t.Error("Expected the old file to be rotated")
+	}
+	HandleMinorError(fs.Close())
+}
+
+// chanWriter passes batches written to it along to a channel.
+type chanWriter struct {
+	MockWriter
+	batches chan Points
+}
+
+func (c *chanWriter) BatchWrite(points Points) error {
+	c.batches <- points
+	return nil
+}
+
+func TestIntervalWriter(t *testing.T) {
+	in := make(chan *Interval)
+	w := &chanWriter{batches: make(chan Points, 1)}
+	tags := NewSharedTagSet(TagSet{"10.0.0.2": Tags{"dst_region": "west"}})
+	iw := NewIntervalWriter(in, tags, w)
+	written := iw.written.Value()
+	iw.Run()
+	in <- alertInterval(1, 5.0)
+	points := <-w.batches
+	if len(points) != 1 || points[0].Fields["loss"] != 5.0 ||
+		points[0].Tags["dst_region"] != "west" {
+		t.Error("Expected the interval's point with tags, got", points)
+	}
+	// Counted once the write returns
+	for i := 0; i < 100 && iw.written.Value() == written; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if iw.written.Value() != written+1 {
+		t.Error("Expected the write to be counted, got", iw.written.Value()-written)
+	}
+	iw.Stop()
+}
@@ -0,0 +1,274 @@
+// Writers for Graphite, using either the plaintext or pickle protocol.
+package llama
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Graphite protocols
+const (
+	GraphitePlaintext = "plaintext"
+	GraphitePickle    = "pickle"
+)
+
+// DefaultMetricTemplate is used to name metrics when no template is provided
+const DefaultMetricTemplate = "llama.{src_ip}.{dst_ip}.{field}"
+
+// MetricTemplateUnknown replaces tags that are missing from a DataPoint
+const MetricTemplateUnknown = "unknown"
+
+var templateVarRe = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// MetricTemplate turns a DataPoint field into a dotted metric path, like
+// `llama.{src_region}.{dst_region}.{field}`.
+//
+// `{measurement}` and `{field}` are replaced with the DataPoint measurement
+// and field name, and anything else with the value of that tag.
+type MetricTemplate struct {
+	parts []string // Alternating literal text and variable names
+}
+
+// sanitizeMetricPart keeps a value from adding levels to a dotted path, or
+// otherwise breaking the protocols.
+func sanitizeMetricPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ' ', '\t', '\n', '/', ':', '|', '@':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Render provides the metric path for a field of the DataPoint. Tags that
+// are missing are replaced with MetricTemplateUnknown.
+func (t *MetricTemplate) Render(dp *DataPoint, field string) string {
+	var b strings.Builder
+	for i, part := range t.parts {
+		if i%2 == 0 {
+			b.WriteString(part)
+			continue
+		}
+		var value string
+		switch part {
+		case "measurement":
+			value = dp.Measurement
+		case "field":
+			value = field
+		default:
+			value = dp.Tags[part]
+		}
+		if value == "" {
+			value = MetricTemplateUnknown
+		}
+		b.WriteString(sanitizeMetricPart(value))
+	}
+	return b.String()
+}
+
+// NewMetricTemplate parses a template, using DefaultMetricTemplate if empty.
+func NewMetricTemplate(template string) (*MetricTemplate, error) {
+	if template == "" {
+		template = DefaultMetricTemplate
+	}
+	t := &MetricTemplate{}
+	last := 0
+	for _, loc := range templateVarRe.FindAllStringSubmatchIndex(template, -1) {
+		name := strings.TrimSpace(template[loc[2]:loc[3]])
+		if name == "" {
+			return nil, fmt.Errorf("Empty variable in template: %s", template)
+		}
+		t.parts = append(t.parts, template[last:loc[0]], name)
+		last = loc[1]
+	}
+	t.parts = append(t.parts, template[last:])
+	for i := 0; i < len(t.parts); i += 2 {
+		if strings.ContainsAny(t.parts[i], "{} ") {
+			return nil, fmt.Errorf("Invalid template: %s", template)
+		}
+	}
+	return t, nil
+}
+
+// metricValue is a single value for a metric path, ready to be written
+type metricValue struct {
+	path  string
+	value float64
+	time  time.Time
+}
+
+// metricValues flattens the points into a value per field, in a consistent
+// order. Points without a time are stamped with `now`. NaN and infinite
+// values are skipped, since they can't be represented.
+func metricValues(t *MetricTemplate, points Points, now time.Time) []metricValue {
+	var values []metricValue
+	for i := range points {
+		dp := &points[i]
+		ts := dp.Time
+		if ts.IsZero() {
+			ts = now
+		}
+		fields := make([]string, 0, len(dp.Fields))
+		for field := range dp.Fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			value := float64(dp.Fields[field])
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			values = append(values, metricValue{t.Render(dp, field), value, ts})
+		}
+	}
+	return values
+}
+
+// graphitePlaintextData encodes the values in the Graphite plaintext format.
+func graphitePlaintextData(values []metricValue) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		fmt.Fprintf(&buf, "%s %s %d\n", v.path,
+			strconv.FormatFloat(v.value, 'f', -1, 64), v.time.Unix())
+	}
+	return buf.Bytes()
+}
+
+// graphitePickleData encodes the values in the Graphite pickle format, which
+// is a length header followed by a pickled list of
+// `(path, (timestamp, value))` tuples.
+//
+// Only the handful of pickle (protocol 2) opcodes needed for that are
+// implemented.
+func graphitePickleData(values []metricValue) []byte {
+	var p bytes.Buffer
+	p.Write([]byte{0x80, 2}) // PROTO 2
+	p.WriteByte(']')         // EMPTY_LIST
+	p.WriteByte('(')         // MARK
+	for _, v := range values {
+		// BINUNICODE: length as 4 byte little endian, then UTF-8
+		p.WriteByte('X')
+		binary.Write(&p, binary.LittleEndian, uint32(len(v.path)))
+		p.WriteString(v.path)
+		// LONG1: length as a byte, then little endian two's complement.
+		// Used instead of BININT, which is 32-bit signed.
+		p.Write([]byte{0x8a, 8})
+		binary.Write(&p, binary.LittleEndian, v.time.Unix())
+		// BINFLOAT: 8 byte big endian double
+		p.WriteByte('G')
+		binary.Write(&p, binary.BigEndian, math.Float64bits(v.value))
+		p.WriteByte(0x86) // TUPLE2 for (timestamp, value)
+		p.WriteByte(0x86) // TUPLE2 for (path, (timestamp, value))
+	}
+	p.WriteByte('e') // APPENDS
+	p.WriteByte('.') // STOP
+	data := make([]byte, 4, 4+p.Len())
+	binary.BigEndian.PutUint32(data, uint32(p.Len()))
+	return append(data, p.Bytes()...)
+}
+
+// GraphiteWriter is used for writing datapoints to Graphite (Carbon) over TCP
+type GraphiteWriter struct {
+	addr     string
+	protocol string
+	template *MetricTemplate
+	timeout  time.Duration
+}
+
+// BatchWrite will write the points to Graphite over a new connection
+func (w *GraphiteWriter) BatchWrite(points Points) error {
+	values := metricValues(w.template, points, time.Now())
+	var data []byte
+	if w.protocol == GraphitePickle {
+		data = graphitePickleData(values)
+	} else {
+		data = graphitePlaintextData(values)
+	}
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", w.addr, w.timeout)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to Graphite: %v", err)
+	}
+	defer conn.Close()
+	err = conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	elapsed := time.Since(start).Seconds()
+	if err != nil {
+		log.Println("Graphite write failed after:", elapsed, "seconds")
+		return fmt.Errorf("Failed to write batch: %v", err)
+	}
+	log.Println("Graphite write completed in:", elapsed, "seconds")
+	return nil
+}
+
+// Close does nothing, since connections are only held during writes
+func (w *GraphiteWriter) Close() error {
+	return nil
+}
+
+// Health checks that Graphite is accepting connections
+func (w *GraphiteWriter) Health() error {
+	conn, err := net.DialTimeout("tcp", w.addr, w.timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// NewGraphiteWriter provides a client for writing LLAMA datapoints to
+// Graphite at `addr` (host:port), using the plaintext or pickle protocol.
+func NewGraphiteWriter(addr string, protocol string, template string,
+	timeout time.Duration) (*GraphiteWriter, error) {
+	if addr == "" {
+		return nil, errors.New("A Graphite address is required")
+	}
+	if protocol == "" {
+		protocol = GraphitePlaintext
+	}
+	if protocol != GraphitePlaintext && protocol != GraphitePickle {
+		return nil, fmt.Errorf("Unknown Graphite protocol: %s", protocol)
+	}
+	t, err := NewMetricTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	log.Println("Creating Graphite", protocol, "writer for", addr)
+	return &GraphiteWriter{addr: addr, protocol: protocol, template: t, timeout: timeout}, nil
+}
+
+// newGraphiteWriterFromOptions creates a GraphiteWriter from WriterOptions.
+// The default address depends on the protocol, matching Carbon's defaults.
+func newGraphiteWriterFromOptions(opts WriterOptions) (Writer, error) {
+	timeout, err := opts.Duration("timeout", DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	protocol := opts.String("protocol", GraphitePlaintext)
+	addr := "127.0.0.1:2003"
+	if protocol == GraphitePickle {
+		addr = "127.0.0.1:2004"
+	}
+	return NewGraphiteWriter(opts.String("address", addr), protocol,
+		opts.String("template", ""), timeout)
+}
+
+func init() {
+	RegisterWriter("graphite", newGraphiteWriterFromOptions)
+}
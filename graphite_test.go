@@ -0,0 +1,155 @@
+package llama
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricTemplate(t *testing.T) {
+	tmpl, err := NewMetricTemplate("llama.{src_metro}.{dst_metro}.{ measurement }.{field}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dp := &DataPoint{
+		Measurement: "raw_stats",
+		Tags:        Tags{"src_metro": "abc", "dst_metro": "x.y z"},
+	}
+	path := tmpl.Render(dp, "loss")
+	if path != "llama.abc.x_y_z.raw_stats.loss" {
+		t.Error("Unexpected path:", path)
+	}
+	// Missing tags are replaced
+	dp.Tags = nil
+	path = tmpl.Render(dp, "loss")
+	if path != "llama.unknown.unknown.raw_stats.loss" {
+		t.Error("Unexpected path:", path)
+	}
+	tmpl, err = NewMetricTemplate("")
+	if err != nil || len(tmpl.parts) != 7 {
+		t.Error("Expected the default template, got", tmpl, err)
+	}
+	for _, bad := range []string{"llama.{}", "llama.{src", "llama.src}", "llama {field}"} {
+		_, err = NewMetricTemplate(bad)
+		if err == nil {
+			t.Error("Expected an error for template", bad)
+		}
+	}
+}
+
+func TestGraphiteWriter(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := ioutil.ReadAll(conn)
+			conn.Close()
+			if len(data) > 0 {
+				received <- data
+			}
+		}
+	}()
+	w, err := NewGraphiteWriter(listener.Addr().String(), "",
+		"llama.{src_metro}.{dst_metro}.{field}", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Health() != nil {
+		t.Error("Expected Graphite to be healthy")
+	}
+	err = w.BatchWrite(examplePoints[:1])
+	if err != nil {
+		t.Fatal("Failed to write:", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(<-received)), "\n")
+	if len(lines) != 4 || lines[0] != "llama.abc.xyz.loss 0 1514922624" ||
+		lines[2] != "llama.abc.xyz.rtt 2.45 1514922624" {
+		t.Error("Unexpected plaintext data:", lines)
+	}
+	// Pickle is a length header followed by the pickle
+	w.protocol = GraphitePickle
+	err = w.BatchWrite(examplePoints[:1])
+	if err != nil {
+		t.Fatal("Failed to write:", err)
+	}
+	data := <-received
+	if int(binary.BigEndian.Uint32(data)) != len(data)-4 {
+		t.Error("Pickle length header doesn't match")
+	}
+	if data[4] != 0x80 || data[len(data)-1] != '.' ||
+		!strings.Contains(string(data), "llama.abc.xyz.sent") {
+		t.Errorf("Unexpected pickle data: %x", data)
+	}
+	_, err = NewGraphiteWriter(listener.Addr().String(), "nope", "", 0)
+	if err == nil {
+		t.Error("Expected an error for an unknown protocol")
+	}
+}
+
+func TestStatsdGauges(t *testing.T) {
+	values := []metricValue{
+		{"a.b", 1.5, time.Time{}},
+		{"a.c", -2, time.Time{}},
+		{"a.d", 3, time.Time{}},
+	}
+	packets := statsdGauges(values, 20)
+	if len(packets) != 3 {
+		t.Fatal("Expected 3 packets, got", len(packets))
+	}
+	if string(packets[0]) != "a.b:1.5|g\n" {
+		t.Error("Unexpected packet:", string(packets[0]))
+	}
+	// Negative values are reset first, or they'd be relative
+	if string(packets[1]) != "a.c:0|g\na.c:-2|g\n" {
+		t.Error("Unexpected packet:", string(packets[1]))
+	}
+	packets = statsdGauges(values, DefaultStatsdPacketSize)
+	if len(packets) != 1 {
+		t.Error("Expected a single packet, got", len(packets))
+	}
+}
+
+func TestStatsdWriter(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w, err := NewStatsdWriter(conn.LocalAddr().String(), "llama.{dst_metro}.{field}", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.BatchWrite(examplePoints)
+	if err != nil {
+		t.Fatal("Failed to write:", err)
+	}
+	buf := make([]byte, DefaultStatsdPacketSize)
+	err = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal("Failed to receive:", err)
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(buf[:n])))
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) != 8 || lines[0] != "llama.xyz.loss:0|g" || lines[7] != "llama.def.sent:480|g" {
+		t.Error("Unexpected gauges:", lines)
+	}
+}
@@ -0,0 +1,311 @@
+// The collector's gRPC API, which provides the same summaries as the JSON
+// HTTP API without losing their types along the way.
+package llama
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/dropbox/llama/proto"
+)
+
+// SummaryToProto converts s, and the tags for its destination, to a protobuf.
+func SummaryToProto(s *Summary, t Tags) *pb.Summary {
+	ps := &pb.Summary{
+		RttAvg:        s.RTTAvg,
+		RttMin:        s.RTTMin,
+		RttMax:        s.RTTMax,
+		Sent:          int64(s.Sent),
+		Lost:          int64(s.Lost),
+		Loss:          s.Loss,
+		LossEpisodes:  int64(s.LossEpisodes),
+		LossBurstMax:  int64(s.LossBurstMax),
+		LossBurstAvg:  s.LossBurstAvg,
+		Scored:        s.Scored,
+		RttBaseline:   s.RTTBaseline,
+		RttDeviation:  s.RTTDeviation,
+		RttAnomalous:  s.RTTAnomalous,
+		LossBaseline:  s.LossBaseline,
+		LossAnomaly:   s.LossAnomaly,
+		LossAnomalous: s.LossAnomalous,
+		Tags:          t,
+	}
+	if s.Pd != nil {
+		ps.SrcIp = s.Pd.SrcIP.String()
+		ps.SrcPort = int64(s.Pd.SrcPort)
+		ps.DstIp = s.Pd.DstIP.String()
+		ps.DstPort = int64(s.Pd.DstPort)
+		ps.Proto = s.Pd.Proto
+	}
+	return ps
+}
+
+// SummaryFromProto converts ps back to a Summary for the interval it's from,
+// along with its tags.
+func SummaryFromProto(ps *pb.Summary, i *Interval) (*Summary, Tags) {
+	s := &Summary{
+		Pd: &PathDist{
+			SrcIP:   net.ParseIP(ps.SrcIp),
+			SrcPort: int(ps.SrcPort),
+			DstIP:   net.ParseIP(ps.DstIp),
+			DstPort: int(ps.DstPort),
+			Proto:   ps.Proto,
+		},
+		RTTAvg:        ps.RttAvg,
+		RTTMin:        ps.RttMin,
+		RTTMax:        ps.RttMax,
+		Sent:          int(ps.Sent),
+		Lost:          int(ps.Lost),
+		Loss:          ps.Loss,
+		LossEpisodes:  int(ps.LossEpisodes),
+		LossBurstMax:  int(ps.LossBurstMax),
+		LossBurstAvg:  ps.LossBurstAvg,
+		TS:            i.Start,
+		End:           i.End,
+		IntervalID:    i.ID,
+		Scored:        ps.Scored,
+		RTTBaseline:   ps.RttBaseline,
+		RTTDeviation:  ps.RttDeviation,
+		RTTAnomalous:  ps.RttAnomalous,
+		LossBaseline:  ps.LossBaseline,
+		LossAnomaly:   ps.LossAnomaly,
+		LossAnomalous: ps.LossAnomalous,
+	}
+	return s, Tags(ps.Tags)
+}
+
+// IntervalFromProto converts ps back to an Interval, along with the tags for
+// each destination.
+func IntervalFromProto(ps *pb.Summaries) (*Interval, TagSet) {
+	i := &Interval{
+		ID:    ps.IntervalId,
+		Start: time.Unix(0, ps.Start).UTC(),
+		End:   time.Unix(0, ps.End).UTC(),
+	}
+	t := make(TagSet)
+	for _, summary := range ps.Summaries {
+		s, tags := SummaryFromProto(summary, i)
+		i.Summaries = append(i.Summaries, s)
+		t[s.Pd.DstIP.String()] = tags
+	}
+	return i, t
+}
+
+// ProtoSummaries converts the interval to a protobuf with the current tags.
+func (s *SharedTagSet) ProtoSummaries(i *Interval) *pb.Summaries {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return NewProtoSummaries(i, s.ts)
+}
+
+// NewProtoSummaries converts the interval to a protobuf with tags from t.
+func NewProtoSummaries(i *Interval, t TagSet) *pb.Summaries {
+	ps := &pb.Summaries{
+		IntervalId: i.ID,
+		Start:      i.Start.UnixNano(),
+		End:        i.End.UnixNano(),
+		Summaries:  make([]*pb.Summary, 0, len(i.Summaries)),
+	}
+	for _, summary := range i.Summaries {
+		var tags Tags
+		if summary.Pd != nil {
+			tags = t[summary.Pd.DstIP.String()]
+		}
+		ps.Summaries = append(ps.Summaries, SummaryToProto(summary, tags))
+	}
+	return ps
+}
+
+// targetToProto converts target to a protobuf.
+func targetToProto(target TargetConfig) *pb.Target {
+	return &pb.Target{Ip: target.IP, Port: target.Port, Tags: target.Tags}
+}
+
+// targetsFromProto converts targets back to a TargetSet.
+func targetsFromProto(targets []*pb.Target) TargetSet {
+	var ts TargetSet
+	for _, target := range targets {
+		ts = append(ts, TargetConfig{IP: target.Ip, Port: target.Port, Tags: Tags(target.Tags)})
+	}
+	return ts
+}
+
+// GRPCAPI represents the gRPC server answering queries for collected data,
+// as an alternative to the JSON HTTP API.
+type GRPCAPI struct {
+	summarizer *Summarizer
+	ts         *SharedTagSet
+	targets    TargetManager // Optional, and only set if a token is configured
+	token      string
+	server     *grpc.Server
+	addr       string
+}
+
+// GetSummaries provides the summaries for the requested interval, or the
+// latest if one isn't requested.
+func (g *GRPCAPI) GetSummaries(ctx context.Context, req *pb.SummariesRequest) (*pb.Summaries, error) {
+	var interval *Interval
+	var found bool
+	if req.IntervalId == 0 {
+		interval, found = g.summarizer.Latest()
+	} else {
+		interval, found = g.summarizer.Interval(req.IntervalId)
+	}
+	if !found {
+		return nil, status.Error(codes.NotFound, "Interval not found")
+	}
+	return g.ts.ProtoSummaries(interval), nil
+}
+
+// StreamSummaries provides the summaries for each interval once it's
+// summarized, until the client goes away.
+func (g *GRPCAPI) StreamSummaries(req *pb.StreamSummariesRequest, stream pb.Collector_StreamSummariesServer) error {
+	sub := g.summarizer.Subscribe()
+	defer g.summarizer.Unsubscribe(sub)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case interval := <-sub:
+			err := stream.Send(g.ts.ProtoSummaries(interval))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// GetStatus provides the health of the collector, and which intervals are
+// available.
+func (g *GRPCAPI) GetStatus(ctx context.Context, req *pb.StatusRequest) (*pb.Status, error) {
+	g.summarizer.CMutex.RLock()
+	defer g.summarizer.CMutex.RUnlock()
+	s := &pb.Status{Status: "ok", Intervals: int64(len(g.summarizer.History))}
+	if len(g.summarizer.History) > 0 {
+		latest := g.summarizer.History[len(g.summarizer.History)-1]
+		s.LatestIntervalId = latest.ID
+		s.Paths = int64(len(latest.Summaries))
+	}
+	return s, nil
+}
+
+// ListTargets provides the targets for the requested test.
+func (g *GRPCAPI) ListTargets(ctx context.Context, req *pb.TargetsRequest) (*pb.Targets, error) {
+	return g.changeTargets(ctx, req, nil)
+}
+
+// AddTargets adds the targets to the requested test, or updates the tags for
+// any that already exist.
+func (g *GRPCAPI) AddTargets(ctx context.Context, req *pb.TargetsRequest) (*pb.Targets, error) {
+	return g.changeTargets(ctx, req, TargetManager.AddTargets)
+}
+
+// DelTargets removes the targets, by IP and port, from the requested test.
+func (g *GRPCAPI) DelTargets(ctx context.Context, req *pb.TargetsRequest) (*pb.Targets, error) {
+	return g.changeTargets(ctx, req, TargetManager.DelTargets)
+}
+
+// changeTargets authorizes the request, and then applies change to the
+// targets in it, if provided. The targets for the test are provided after.
+func (g *GRPCAPI) changeTargets(ctx context.Context, req *pb.TargetsRequest,
+	change func(m TargetManager, test string, targets TargetSet) error) (*pb.Targets, error) {
+	if g.targets == nil {
+		return nil, status.Error(codes.Unimplemented, "Managing targets isn't enabled")
+	}
+	if !g.authorized(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	if req.Test == "" {
+		return nil, status.Error(codes.InvalidArgument, "A test is required")
+	}
+	var err error
+	if change != nil {
+		targets := targetsFromProto(req.Targets)
+		for _, target := range targets {
+			err = ValidateTarget(target)
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+		err = change(g.targets, req.Test, targets)
+	}
+	var targets TargetSet
+	if err == nil {
+		targets, err = g.targets.Targets(req.Test)
+	}
+	if err == ErrTestNotFound {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if err != nil {
+		log.Println("Failed to change targets:", err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &pb.Targets{Targets: make([]*pb.Target, 0, len(targets))}
+	for _, target := range targets {
+		resp.Targets = append(resp.Targets, targetToProto(target))
+	}
+	return resp, nil
+}
+
+// authorized determines if the request provides the token for changing
+// targets, in the same form as the HTTP API.
+func (g *GRPCAPI) authorized(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if validToken(value, g.token) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTargetManager provides the TargetManager used for changing targets, and
+// the token requests must provide to do so.
+//
+// This must be done before running.
+func (g *GRPCAPI) SetTargetManager(m TargetManager, token string) {
+	g.targets = m
+	g.token = token
+}
+
+// Stop will close down the server and cause Run to exit.
+func (g *GRPCAPI) Stop() {
+	g.server.Stop()
+	log.Println("gRPC API Stopped")
+}
+
+// Run calls RunForever in a separate goroutine for non-blocking behavior.
+func (g *GRPCAPI) Run() {
+	go g.RunForever()
+}
+
+// RunForever listens for requests until stopped or a fatal error occurs.
+//
+// Calling this will block until stopped/crashed.
+func (g *GRPCAPI) RunForever() {
+	listener, err := net.Listen("tcp", g.addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = g.server.Serve(listener)
+	// Serve only fails after stopping if it was stopped before it started
+	if err != nil && err != grpc.ErrServerStopped {
+		log.Fatal(err)
+	}
+}
+
+// NewGRPCAPI returns an initialized GRPCAPI, to listen on `addr`.
+//
+// `t` is shared with anything else applying tags to summaries, so that they
+// all reflect the same updates.
+func NewGRPCAPI(s *Summarizer, t *SharedTagSet, addr string) *GRPCAPI {
+	g := &GRPCAPI{summarizer: s, ts: t, addr: addr, server: grpc.NewServer()}
+	pb.RegisterCollectorServer(g.server, g)
+	return g
+}
@@ -0,0 +1,197 @@
+package llama
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/dropbox/llama/proto"
+)
+
+// newTestGRPCAPI provides a GRPCAPI with the same intervals as newTestAPI,
+// the latest of which has a summary for 10.0.0.2.
+func newTestGRPCAPI() *GRPCAPI {
+	s := NewSummarizer(make(chan *Result), time.Second, 0, 2)
+	s.summarize(time.Unix(101, 0))
+	s.summarize(time.Unix(102, 0))
+	latest, _ := s.Latest()
+	latest.Summaries = append(latest.Summaries, &Summary{
+		Pd: &PathDist{
+			SrcIP:   net.ParseIP("10.0.0.1"),
+			SrcPort: 1234,
+			DstIP:   net.ParseIP("10.0.0.2"),
+			DstPort: 8100,
+			Proto:   "udp",
+		},
+		RTTAvg: 1.5,
+		Sent:   10,
+		Lost:   1,
+		Loss:   0.1,
+	})
+	ts := NewSharedTagSet(TagSet{"10.0.0.2": Tags{"dst_name": "two"}})
+	return NewGRPCAPI(s, ts, "127.0.0.1:0")
+}
+
+func TestSummaryProtoRoundTrip(t *testing.T) {
+	g := newTestGRPCAPI()
+	latest, _ := g.summarizer.Latest()
+	interval, tags := IntervalFromProto(g.ts.ProtoSummaries(latest))
+	if interval.ID != latest.ID || !interval.Start.Equal(latest.Start) || !interval.End.Equal(latest.End) {
+		t.Error("Expected the same interval, got", interval.ID, interval.Start, interval.End)
+	}
+	if len(interval.Summaries) != 1 {
+		t.Fatal("Expected 1 summary, got", len(interval.Summaries))
+	}
+	s := interval.Summaries[0]
+	if s.Pd.DstIP.String() != "10.0.0.2" || s.Pd.SrcPort != 1234 || s.RTTAvg != 1.5 || s.Lost != 1 {
+		t.Error("Summary not converted correctly:", s.Pd, s)
+	}
+	if s.IntervalID != latest.ID || !s.TS.Equal(latest.Start) {
+		t.Error("Expected the summary to be from the interval, got", s.IntervalID, s.TS)
+	}
+	if tags["10.0.0.2"]["dst_name"] != "two" {
+		t.Error("Expected tags for 10.0.0.2, got", tags)
+	}
+}
+
+func TestGRPCGetSummaries(t *testing.T) {
+	g := newTestGRPCAPI()
+	ps, err := g.GetSummaries(context.Background(), &pb.SummariesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.IntervalId != 101 || len(ps.Summaries) != 1 {
+		t.Error("Expected the latest interval, got", ps.IntervalId, len(ps.Summaries))
+	}
+	ps, err = g.GetSummaries(context.Background(), &pb.SummariesRequest{IntervalId: 100})
+	if err != nil || ps.IntervalId != 100 {
+		t.Error("Expected interval 100, got", ps, err)
+	}
+	_, err = g.GetSummaries(context.Background(), &pb.SummariesRequest{IntervalId: 1})
+	if status.Code(err) != codes.NotFound {
+		t.Error("Expected NotFound, got", err)
+	}
+}
+
+func TestGRPCGetStatus(t *testing.T) {
+	g := newTestGRPCAPI()
+	s, err := g.GetStatus(context.Background(), &pb.StatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.LatestIntervalId != 101 || s.Intervals != 2 || s.Paths != 1 {
+		t.Error("Unexpected status:", s)
+	}
+}
+
+func TestGRPCTargets(t *testing.T) {
+	g := newTestGRPCAPI()
+	req := &pb.TargetsRequest{Test: "default"}
+	_, err := g.ListTargets(context.Background(), req)
+	if status.Code(err) != codes.Unimplemented {
+		t.Error("Expected Unimplemented without a TargetManager, got", err)
+	}
+
+	m := &mockTargetManager{}
+	g.SetTargetManager(m, "secret")
+	_, err = g.ListTargets(context.Background(), req)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Error("Expected Unauthenticated without a token, got", err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", "Bearer secret"))
+	_, err = g.ListTargets(ctx, &pb.TargetsRequest{Test: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Error("Expected NotFound for an unknown test, got", err)
+	}
+	_, err = g.AddTargets(ctx, &pb.TargetsRequest{Test: "default",
+		Targets: []*pb.Target{{Ip: "localhost", Port: 8100}}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Error("Expected InvalidArgument for an invalid target, got", err)
+	}
+
+	resp, err := g.AddTargets(ctx, &pb.TargetsRequest{Test: "default",
+		Targets: []*pb.Target{{Ip: "10.0.0.1", Port: 8100, Tags: map[string]string{"dst_name": "new"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Targets) != 1 || resp.Targets[0].Tags["dst_name"] != "new" {
+		t.Error("Expected the added target, got", resp.Targets)
+	}
+	resp, err = g.DelTargets(ctx, &pb.TargetsRequest{Test: "default",
+		Targets: []*pb.Target{{Ip: "10.0.0.1", Port: 8100}}})
+	if err != nil || len(resp.Targets) != 0 {
+		t.Error("Expected the target to be removed, got", resp, err)
+	}
+}
+
+func TestGRPCClient(t *testing.T) {
+	g := newTestGRPCAPI()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go g.server.Serve(listener)
+	defer g.Stop()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	c, err := NewGRPCClient(host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	points, id, err := c.GetIntervalPoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 101 || len(points) != 1 {
+		t.Fatal("Expected 1 point from interval 101, got", id, len(points))
+	}
+	if points[0].Tags["dst_name"] != "two" || points[0].Tags["dst_ip"] != "10.0.0.2" {
+		t.Error("Unexpected tags:", points[0].Tags)
+	}
+	intervals, err := c.GetIntervalsSince(99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(intervals) != 2 || intervals[0].ID != 100 || intervals[1].ID != 101 {
+		t.Error("Expected intervals 100 and 101, got", intervals)
+	}
+	intervals, err = c.GetIntervalsSince(100)
+	if err != nil || len(intervals) != 1 || intervals[0].ID != 101 {
+		t.Error("Expected only interval 101, got", intervals, err)
+	}
+}
+
+func TestGRPCClientRetries(t *testing.T) {
+	// Nothing is listening, so every attempt is Unavailable
+	c, err := NewGRPCClient("127.0.0.1", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetTimeout(100*time.Millisecond, false)
+	c.SetRetries(2, 10*time.Millisecond)
+	attempts := 0
+	err = c.call(func(ctx context.Context, opts ...grpc.CallOption) error {
+		attempts++
+		return status.Error(codes.Unavailable, "down")
+	})
+	if attempts != 3 || status.Code(err) != codes.Unavailable {
+		t.Error("Expected 3 attempts, got", attempts, err)
+	}
+	attempts = 0
+	c.call(func(ctx context.Context, opts ...grpc.CallOption) error {
+		attempts++
+		return status.Error(codes.NotFound, "missing")
+	})
+	if attempts != 1 {
+		t.Error("Expected no retries for NotFound, got", attempts)
+	}
+}
@@ -0,0 +1,177 @@
+// Llama client to pull summaries from the gRPC API of Llama collectors
+package llama
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // Registers the compressor
+	"google.golang.org/grpc/status"
+
+	pb "github.com/dropbox/llama/proto"
+)
+
+// grpcClient pulls from a collector's gRPC API instead of its JSON HTTP API,
+// which keeps the types of values intact and is more compact.
+type grpcClient struct {
+	hostname   string
+	port       string
+	conn       *grpc.ClientConn
+	client     pb.CollectorClient
+	timeout    time.Duration
+	compress   bool
+	retries    int           // Additional attempts after a failure
+	retryDelay time.Duration // Doubled after each retry
+}
+
+// NewGRPCClient creates a new collector client for the gRPC API at hostname
+// and port, using DefaultClientTimeout and DefaultClientRetries.
+//
+// Connecting happens in the background, so this only fails if the address
+// can't be used at all.
+func NewGRPCClient(hostname string, port string) (*grpcClient, error) {
+	conn, err := grpc.Dial(net.JoinHostPort(hostname, port), grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	return &grpcClient{
+		hostname:   hostname,
+		port:       port,
+		conn:       conn,
+		client:     pb.NewCollectorClient(conn),
+		timeout:    DefaultClientTimeout,
+		compress:   true,
+		retries:    DefaultClientRetries,
+		retryDelay: DefaultClientRetryDelay,
+	}, nil
+}
+
+// SetTimeout sets the timeout for each request, and whether responses should
+// be gzipped.
+func (c *grpcClient) SetTimeout(timeout time.Duration, compress bool) {
+	c.timeout = timeout
+	c.compress = compress
+}
+
+// SetRetries sets how many more times to attempt a request after a failure,
+// waiting `delay` before the first retry and doubling it each time after.
+func (c *grpcClient) SetRetries(retries int, delay time.Duration) {
+	c.retries = retries
+	c.retryDelay = delay
+}
+
+func (c *grpcClient) Hostname() string {
+	return c.hostname
+}
+
+func (c *grpcClient) Port() string {
+	return c.port
+}
+
+// Close closes the connection to the collector.
+func (c *grpcClient) Close() error {
+	return c.conn.Close()
+}
+
+// GetPoints will fetch data points from the associated collector, retrying
+// when it's unavailable.
+func (c *grpcClient) GetPoints() (Points, error) {
+	points, _, err := c.GetIntervalPoints()
+	return points, err
+}
+
+// GetIntervalPoints will fetch data points for the latest interval from the
+// associated collector, along with the ID of the interval.
+func (c *grpcClient) GetIntervalPoints() (Points, int64, error) {
+	var summaries *pb.Summaries
+	err := c.call(func(ctx context.Context, opts ...grpc.CallOption) error {
+		var err error
+		summaries, err = c.client.GetSummaries(ctx, &pb.SummariesRequest{}, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	ip := intervalPointsFromProto(summaries)
+	points := make(Points, 0, len(ip.Points))
+	for _, point := range ip.Points {
+		points = append(points, *point)
+	}
+	return points, ip.ID, nil
+}
+
+// GetIntervalsSince will fetch all of the intervals the collector has
+// retained after the one with `id`, oldest first.
+func (c *grpcClient) GetIntervalsSince(id int64) ([]*IntervalPoints, error) {
+	var s *pb.Status
+	err := c.call(func(ctx context.Context, opts ...grpc.CallOption) error {
+		var err error
+		s, err = c.client.GetStatus(ctx, &pb.StatusRequest{}, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Intervals are consecutive, so only ask for those still retained
+	first := s.LatestIntervalId - s.Intervals + 1
+	if first <= id {
+		first = id + 1
+	}
+	var intervals []*IntervalPoints
+	for i := first; i <= s.LatestIntervalId; i++ {
+		var summaries *pb.Summaries
+		err := c.call(func(ctx context.Context, opts ...grpc.CallOption) error {
+			var err error
+			summaries, err = c.client.GetSummaries(ctx, &pb.SummariesRequest{IntervalId: i}, opts...)
+			return err
+		})
+		// It may have aged out since getting the status
+		if status.Code(err) == codes.NotFound {
+			continue
+		} else if err != nil {
+			return intervals, err
+		}
+		intervals = append(intervals, intervalPointsFromProto(summaries))
+	}
+	return intervals, nil
+}
+
+// intervalPointsFromProto converts summaries to IntervalPoints, in the same
+// form as the JSON HTTP API provides them.
+func intervalPointsFromProto(summaries *pb.Summaries) *IntervalPoints {
+	interval, tags := IntervalFromProto(summaries)
+	return NewIntervalPoints(interval, tags)
+}
+
+// call makes a request with the timeout and compression for the client,
+// retrying while the collector is unavailable.
+func (c *grpcClient) call(request func(ctx context.Context, opts ...grpc.CallOption) error) error {
+	var opts []grpc.CallOption
+	if c.compress {
+		opts = append(opts, grpc.UseCompressor("gzip"))
+	}
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		err := request(ctx, opts...)
+		cancel()
+		if err == nil || !retryable(err) || attempt >= c.retries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// retryable determines if a gRPC error is worth retrying. Like the HTTP
+// client, that's when the collector can't be reached or is overloaded.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+// Writer for pushing points to any HTTP endpoint, such as from collectors
+// which can't be reached by a scraper.
+package llama
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats points can be sent to an HTTP endpoint in
+const (
+	HTTPFormatJSON = "json" // The same as provided by the collector's /influxdata
+	HTTPFormatLine = "line" // InfluxDB line protocol, with second precision
+)
+
+// HTTPWriter is used for writing datapoints to an HTTP endpoint, with a POST
+// request for each batch. Failed requests are retried, and can be spooled
+// like any other Writer if they keep failing.
+type HTTPWriter struct {
+	client     *http.Client
+	url        string
+	format     string
+	compress   bool
+	headers    map[string]string
+	retries    int           // Additional attempts after a failure
+	retryDelay time.Duration // Doubled after each retry
+}
+
+// encode converts the points to the body of a request, in the format for the
+// writer.
+func (w *HTTPWriter) encode(points Points) ([]byte, error) {
+	var data []byte
+	var err error
+	if w.format == HTTPFormatLine {
+		data, err = LineProtocol(points, "s")
+	} else {
+		data, err = json.Marshal(points)
+	}
+	if err != nil || !w.compress {
+		return data, err
+	}
+	return gzipBytes(data)
+}
+
+// BatchWrite will write the points to the endpoint as a single request,
+// retrying on connection errors and server errors.
+func (w *HTTPWriter) BatchWrite(points Points) error {
+	data, err := w.encode(points)
+	if err != nil {
+		return fmt.Errorf("Failed to encode points: %v", err)
+	}
+	// Lets the endpoint skip intervals it has already seen, like scrapers do
+	var interval string
+	if len(points) > 0 && points[0].IntervalID != 0 {
+		interval = strconv.FormatInt(points[0].IntervalID, 10)
+	}
+	start := time.Now()
+	delay := w.retryDelay
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = w.post(data, interval)
+		if err == nil || !retry || attempt >= w.retries {
+			break
+		}
+		log.Println("HTTP write failed, retrying in", delay, "-", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+	elapsed := time.Since(start).Seconds()
+	if err != nil {
+		log.Println("HTTP write failed after:", elapsed, "seconds")
+		return fmt.Errorf("Failed to write batch: %v", err)
+	}
+	log.Println("HTTP write completed in:", elapsed, "seconds")
+	return nil
+}
+
+// post makes a single attempt at sending the data, and indicates whether a
+// failure is worth retrying.
+func (w *HTTPWriter) post(data []byte, interval string) (bool, error) {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	if w.format == HTTPFormatLine {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		req.Header.Set("Content-Type", JSONContentType)
+	}
+	if w.compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if interval != "" {
+		req.Header.Set(IntervalHeader, interval)
+	}
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Client errors won't go away by trying again
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("%s (%s)", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return false, nil
+}
+
+// Close releases any idle connections to the endpoint
+func (w *HTTPWriter) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+// Health always succeeds, as there's no standard health check for arbitrary
+// endpoints.
+func (w *HTTPWriter) Health() error {
+	return nil
+}
+
+// SetHeader sets a header sent with each request, such as for auth.
+func (w *HTTPWriter) SetHeader(key string, value string) {
+	w.headers[key] = value
+}
+
+// SetRetries sets how many more times to attempt a request after a failure,
+// waiting `delay` before the first retry and doubling it each time after.
+func (w *HTTPWriter) SetRetries(retries int, delay time.Duration) {
+	w.retries = retries
+	w.retryDelay = delay
+}
+
+// NewHTTPWriter provides a client for writing LLAMA datapoints to an HTTP
+// endpoint at `url`.
+//
+// `format` is HTTPFormatJSON or HTTPFormatLine, and defaults to JSON if
+// empty. If `compress` is true, requests are gzipped. Requests are retried
+// using DefaultClientRetries and DefaultClientRetryDelay.
+func NewHTTPWriter(url string, format string, compress bool,
+	timeout time.Duration) (*HTTPWriter, error) {
+	if url == "" {
+		return nil, errors.New("A URL is required")
+	}
+	if format == "" {
+		format = HTTPFormatJSON
+	}
+	if format != HTTPFormatJSON && format != HTTPFormatLine {
+		return nil, fmt.Errorf("Unknown format: %s", format)
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	log.Println("Creating HTTP writer for", url)
+	return &HTTPWriter{
+		client:     &http.Client{Timeout: timeout},
+		url:        url,
+		format:     format,
+		compress:   compress,
+		headers:    make(map[string]string),
+		retries:    DefaultClientRetries,
+		retryDelay: DefaultClientRetryDelay,
+	}, nil
+}
+
+// newHTTPWriterFromOptions creates an HTTPWriter from WriterOptions.
+func newHTTPWriterFromOptions(opts WriterOptions) (Writer, error) {
+	compress, err := opts.Bool("gzip", true)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := opts.Duration("timeout", DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	retries, err := opts.Int("retries", DefaultClientRetries)
+	if err != nil {
+		return nil, err
+	}
+	retryDelay, err := opts.Duration("retry_delay", DefaultClientRetryDelay)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewHTTPWriter(opts.String("url", ""), opts.String("format", ""),
+		compress, timeout)
+	if err != nil {
+		return nil, err
+	}
+	w.SetRetries(int(retries), retryDelay)
+	if token := opts.String("bearer_token", ""); token != "" {
+		w.SetHeader("Authorization", "Bearer "+token)
+	}
+	return w, nil
+}
+
+func init() {
+	RegisterWriter("http", newHTTPWriterFromOptions)
+}
@@ -0,0 +1,98 @@
+package llama
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPWriter(t *testing.T) {
+	var attempts int
+	var received Points
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		attempts++
+		// Fail the first attempt, to make sure it's retried
+		if attempts == 1 {
+			rw.WriteHeader(503)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get(IntervalHeader) != "7" {
+			t.Error("Unexpected headers:", r.Header)
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = json.NewDecoder(gz).Decode(&received)
+		if err != nil {
+			t.Error("Failed to decode points:", err)
+		}
+	}))
+	defer server.Close()
+
+	w, err := newHTTPWriterFromOptions(WriterOptions{
+		"url":          server.URL,
+		"bearer_token": "secret",
+		"retry_delay":  "1ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := Points{examplePoints[0]}
+	points[0].IntervalID = 7
+	err = w.BatchWrite(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || len(received) != 1 || received[0].Tags["src_metro"] != "abc" {
+		t.Error("Expected the points after a retry, got", attempts, received)
+	}
+}
+
+func TestHTTPWriterLineProtocol(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+	w, err := NewHTTPWriter(server.URL, HTTPFormatLine, false, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.BatchWrite(Points{examplePoints[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(body, examplePoints[0].Measurement+",") {
+		t.Error("Expected line protocol, got", body)
+	}
+}
+
+func TestHTTPWriterNoRetry(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		attempts++
+		rw.WriteHeader(400)
+	}))
+	defer server.Close()
+	w, err := NewHTTPWriter(server.URL, "", true, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetRetries(2, time.Millisecond)
+	if err := w.BatchWrite(Points{examplePoints[0]}); err == nil {
+		t.Error("Expected an error for a 400")
+	}
+	if attempts != 1 {
+		t.Error("Expected client errors not to be retried, got", attempts)
+	}
+	if _, err := NewHTTPWriter(server.URL, "xml", true, 0); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -25,6 +25,11 @@ type DataPoint struct {
 	Tags        Tags                  `json:"tags"`
 	Time        time.Time             `json:"time"`
 	Measurement string                `json:"measurement"`
+	// Details about the interval the point was summarized from. Time is the
+	// same as IntervalStart, but these are kept separate for clarity.
+	IntervalID    int64     `json:"interval_id"`
+	IntervalStart time.Time `json:"interval_start"`
+	IntervalEnd   time.Time `json:"interval_end"`
 }
 
 // SetFieldFloat64 sets the value of "field" k to the value v.
@@ -37,13 +42,23 @@ func (dp *DataPoint) SetFieldInt(k string, v int) {
 	dp.Fields[k] = IDBFloat64(v)
 }
 
+// SetFieldBool sets the value of "field" k to 1 if v is true, otherwise 0.
+func (dp *DataPoint) SetFieldBool(k string, v bool) {
+	if v {
+		dp.Fields[k] = IDBFloat64(1)
+		return
+	}
+	dp.Fields[k] = IDBFloat64(0)
+}
+
 // FromSummary updates the values of dp to reflect what is available in s.
 func (dp *DataPoint) FromSummary(s *Summary) {
 	// Populate general fields from the provided summary
 	dp.FromPD(s.Pd)
-	// NOTE(dmar): Don't set this anymore, and just allow the zero value.
-	//      Leaving for posterity incase it's desired in the future.
+	// Use the start of the interval, so that the point has the same
+	// timestamp no matter when, or how many times, it is retrieved.
 	dp.SetTime(s.TS)
+	dp.SetInterval(s.IntervalID, s.TS, s.End)
 	dp.SetMeasurement("raw_stats")
 	// Set the field values
 	// TODO(dmar): Should update `Summary` to have a map of values, and then
@@ -61,6 +76,18 @@ func (dp *DataPoint) FromSummary(s *Summary) {
 	dp.SetFieldFloat64("loss", s.Loss)
 	dp.SetFieldInt("lost", s.Lost)
 	dp.SetFieldInt("sent", s.Sent)
+	dp.SetFieldInt("loss_episodes", s.LossEpisodes)
+	dp.SetFieldInt("loss_burst_max", s.LossBurstMax)
+	dp.SetFieldFloat64("loss_burst_avg", s.LossBurstAvg)
+	// Only include baseline scores once they're meaningful
+	if s.Scored {
+		dp.SetFieldFloat64("rtt_baseline", s.RTTBaseline)
+		dp.SetFieldFloat64("rtt_deviation", s.RTTDeviation)
+		dp.SetFieldBool("rtt_anomalous", s.RTTAnomalous)
+		dp.SetFieldFloat64("loss_baseline", s.LossBaseline)
+		dp.SetFieldFloat64("loss_anomaly", s.LossAnomaly)
+		dp.SetFieldBool("loss_anomalous", s.LossAnomalous)
+	}
 }
 
 // FromPD updates the values of dp to reflect what is available in pd.
@@ -89,6 +116,13 @@ func (dp *DataPoint) SetTime(t time.Time) {
 	dp.Time = t
 }
 
+// SetInterval updates the interval details of the dp.
+func (dp *DataPoint) SetInterval(id int64, start time.Time, end time.Time) {
+	dp.IntervalID = id
+	dp.IntervalStart = start
+	dp.IntervalEnd = end
+}
+
 // SetMeasurements set the measurement of the dp to the value of s.
 func (dp *DataPoint) SetMeasurement(s string) {
 	// Set the measurement
@@ -124,3 +158,22 @@ func NewDataPointsFromSummaries(summaries []*Summary, t TagSet) []*DataPoint {
 	}
 	return dps
 }
+
+// IntervalPoints represents the DataPoints for a single summarized Interval.
+type IntervalPoints struct {
+	ID     int64        `json:"id"`
+	Start  time.Time    `json:"start"`
+	End    time.Time    `json:"end"`
+	Points []*DataPoint `json:"points"`
+}
+
+// NewIntervalPoints provides a new IntervalPoints populated with values in i
+// and tags from t.
+func NewIntervalPoints(i *Interval, t TagSet) *IntervalPoints {
+	return &IntervalPoints{
+		ID:     i.ID,
+		Start:  i.Start,
+		End:    i.End,
+		Points: NewDataPointsFromSummaries(i.Summaries, t),
+	}
+}
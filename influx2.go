@@ -0,0 +1,199 @@
+// Writer for InfluxDB 2.x, using line protocol and token authentication.
+package llama
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// DefaultInfluxDb2URL is where InfluxDB 2.x listens by default
+const DefaultInfluxDb2URL = "http://127.0.0.1:8086"
+
+// influxDb2Precisions maps the precisions accepted by the v2 write API to
+// those used by the line protocol encoder.
+var influxDb2Precisions = map[string]string{
+	"ns": "n",
+	"us": "u",
+	"ms": "ms",
+	"s":  "s",
+}
+
+// LineProtocol encodes the points in InfluxDB line protocol, with timestamps
+// at the provided precision ("ns", "us", "ms", or "s").
+//
+// Points without a time are written without a timestamp, so the DB uses its
+// own time.
+func LineProtocol(points Points, precision string) ([]byte, error) {
+	lpPrecision, found := influxDb2Precisions[precision]
+	if !found {
+		return nil, fmt.Errorf("Unknown precision: %s", precision)
+	}
+	var buf bytes.Buffer
+	for _, dp := range points {
+		fields := make(models.Fields, len(dp.Fields))
+		for key, value := range dp.Fields {
+			fields[key] = float64(value)
+		}
+		pt, err := models.NewPoint(dp.Measurement, models.NewTags(dp.Tags), fields, dp.Time)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(pt.PrecisionString(lpPrecision))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// InfluxDb2Writer is used for writing datapoints to an InfluxDB 2.x bucket
+type InfluxDb2Writer struct {
+	client    *http.Client
+	base      string // URL of the InfluxDB host, without a trailing `/`
+	writeURL  string // Full URL of the write endpoint, including params
+	token     string
+	precision string
+	compress  bool
+}
+
+// BatchWrite will write the points to the bucket as a single request
+func (w *InfluxDb2Writer) BatchWrite(points Points) error {
+	data, err := LineProtocol(points, w.precision)
+	if err != nil {
+		return fmt.Errorf("Failed to encode points: %v", err)
+	}
+	if w.compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, err = gz.Write(data)
+		if err == nil {
+			err = gz.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to compress points: %v", err)
+		}
+		data = buf.Bytes()
+	}
+	req, err := http.NewRequest("POST", w.writeURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Token "+w.token)
+	}
+	if w.compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	start := time.Now()
+	resp, err := w.client.Do(req)
+	elapsed := time.Since(start).Seconds()
+	if err != nil {
+		log.Println("DB write failed after:", elapsed, "seconds")
+		return fmt.Errorf("Failed to write batch: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Println("DB write failed after:", elapsed, "seconds")
+		return fmt.Errorf("Failed to write batch: %s (%s)", resp.Status,
+			strings.TrimSpace(string(body)))
+	}
+	log.Println("DB write completed in:", elapsed, "seconds")
+	return nil
+}
+
+// Close releases any idle connections to InfluxDB
+func (w *InfluxDb2Writer) Close() error {
+	log.Println("Closing InfluxDB 2 client connections")
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+// Health checks that InfluxDB reports itself as healthy
+func (w *InfluxDb2Writer) Health() error {
+	resp, err := w.client.Get(w.base + "/health")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Unhealthy: %s (%s)", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// NewInfluxDb2Writer provides a client for writing LLAMA datapoints to an
+// InfluxDB 2.x bucket.
+//
+// `precision` is one of "ns", "us", "ms", or "s", and defaults to "s" if
+// empty, since LLAMA doesn't need anything more granular. If `compress` is
+// true, requests are gzipped.
+func NewInfluxDb2Writer(base string, org string, bucket string, token string,
+	precision string, compress bool, timeout time.Duration) (*InfluxDb2Writer, error) {
+	if org == "" || bucket == "" {
+		return nil, errors.New("Both an org and bucket are required")
+	}
+	if precision == "" {
+		precision = "s"
+	}
+	if _, found := influxDb2Precisions[precision]; !found {
+		return nil, fmt.Errorf("Unknown precision: %s", precision)
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	base = strings.TrimRight(base, "/")
+	_, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("org", org)
+	params.Set("bucket", bucket)
+	params.Set("precision", precision)
+	log.Println("Creating InfluxDB 2 writer for", base)
+	writer := &InfluxDb2Writer{
+		client:    &http.Client{Timeout: timeout},
+		base:      base,
+		writeURL:  base + "/api/v2/write?" + params.Encode(),
+		token:     token,
+		precision: precision,
+		compress:  compress,
+	}
+	return writer, nil
+}
+
+// newInfluxDb2WriterFromOptions creates an InfluxDb2Writer from WriterOptions.
+func newInfluxDb2WriterFromOptions(opts WriterOptions) (Writer, error) {
+	compress, err := opts.Bool("gzip", true)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := opts.Duration("timeout", DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewInfluxDb2Writer(
+		opts.String("url", DefaultInfluxDb2URL),
+		opts.String("org", ""),
+		opts.String("bucket", ""),
+		opts.String("token", ""),
+		opts.String("precision", "s"),
+		compress,
+		timeout,
+	)
+}
+
+func init() {
+	RegisterWriter("influxdb2", newInfluxDb2WriterFromOptions)
+}
@@ -0,0 +1,106 @@
+package llama
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLineProtocol(t *testing.T) {
+	data, err := LineProtocol(examplePoints[:1], "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "measurement,dst_metro=xyz,src_metro=abc loss=0,lost=0,rtt=2.45,sent=480 1514922624\n"
+	if string(data) != expected {
+		t.Errorf("Expected:\n%sGot:\n%s", expected, data)
+	}
+	// Points without a time don't get a timestamp
+	noTime := Points{DataPoint{Measurement: "m", Fields: map[string]IDBFloat64{"x": 1}}}
+	data, err = LineProtocol(noTime, "ns")
+	if err != nil || string(data) != "m x=1\n" {
+		t.Error("Expected no timestamp, got", string(data), err)
+	}
+	_, err = LineProtocol(examplePoints, "fortnight")
+	if err == nil {
+		t.Error("Expected an error for an unknown precision")
+	}
+}
+
+func TestInfluxDb2Writer(t *testing.T) {
+	var request *http.Request
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, r *http.Request) {
+			request = r
+			if r.URL.Path == "/health" {
+				return
+			}
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, _ := ioutil.ReadAll(reader)
+			body = string(data)
+			rw.WriteHeader(http.StatusNoContent)
+		}))
+	defer server.Close()
+	w, err := NewInfluxDb2Writer(server.URL+"/", "org", "llama", "secret", "ms", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.BatchWrite(examplePoints)
+	if err != nil {
+		t.Fatal("Failed to write:", err)
+	}
+	if request.URL.Path != "/api/v2/write" {
+		t.Error("Unexpected path:", request.URL.Path)
+	}
+	query := request.URL.Query()
+	if query.Get("org") != "org" || query.Get("bucket") != "llama" || query.Get("precision") != "ms" {
+		t.Error("Unexpected params:", query)
+	}
+	if request.Header.Get("Authorization") != "Token secret" {
+		t.Error("Token not provided:", request.Header.Get("Authorization"))
+	}
+	if strings.Count(body, "\n") != 2 || !strings.Contains(body, " 1514922624000\n") {
+		t.Error("Unexpected body:", body)
+	}
+	if w.Health() != nil {
+		t.Error("Expected a healthy response")
+	}
+	HandleMinorError(w.Close())
+}
+
+func TestInfluxDb2WriterErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusUnauthorized)
+			rw.Write([]byte(`{"code":"unauthorized","message":"unauthorized access"}`))
+		}))
+	defer server.Close()
+	w, err := NewInfluxDb2Writer(server.URL, "org", "llama", "bad", "", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.BatchWrite(examplePoints)
+	if err == nil || !strings.Contains(err.Error(), "unauthorized access") {
+		t.Error("Expected the error from InfluxDB, got", err)
+	}
+	if w.Health() == nil {
+		t.Error("Expected the health check to fail")
+	}
+	// Bad configs
+	_, err = NewInfluxDb2Writer(server.URL, "", "llama", "", "", false, 0)
+	if err == nil {
+		t.Error("Expected an error without an org")
+	}
+	_, err = NewInfluxDb2Writer(server.URL, "org", "llama", "", "m", false, 0)
+	if err == nil {
+		t.Error("Expected an error for an invalid precision")
+	}
+}
@@ -35,6 +35,13 @@ func TestFromSummary(t *testing.T) {
 		Lost:   2,
 		Loss:   0.4,
 		TS:     time.Now(),
+		// Loss burst stats
+		LossEpisodes: 1,
+		LossBurstMax: 2,
+		LossBurstAvg: 2.0,
+		// Interval details
+		End:        time.Now(),
+		IntervalID: 42,
 	}
 	dp.FromSummary(s)
 	// Check PD
@@ -45,6 +52,9 @@ func TestFromSummary(t *testing.T) {
 	if dp.Time.IsZero() {
 		t.Error("Time is not being set")
 	}
+	if dp.IntervalID != 42 || dp.IntervalStart != s.TS || dp.IntervalEnd != s.End {
+		t.Error("Interval details are not being set")
+	}
 	// Check measurement
 	if dp.Measurement != "raw_stats" {
 		t.Error("Measurement is not being set")
@@ -53,6 +63,28 @@ func TestFromSummary(t *testing.T) {
 	if dp.Fields["rtt"] != 100.0 || dp.Fields["lost"] != 2 {
 		t.Error("Fields are not being populated")
 	}
+	if dp.Fields["loss_episodes"] != 1 || dp.Fields["loss_burst_max"] != 2 ||
+		dp.Fields["loss_burst_avg"] != 2.0 {
+		t.Error("Loss burst fields are not being populated:", dp.Fields)
+	}
+}
+
+func TestFromSummaryScored(t *testing.T) {
+	s := &Summary{Pd: &PathDist{}}
+	dp := NewDataPoint()
+	dp.FromSummary(s)
+	if _, found := dp.Fields["rtt_deviation"]; found {
+		t.Error("Baseline fields shouldn't be set unless scored")
+	}
+	s.Scored = true
+	s.RTTDeviation = 2.5
+	s.LossAnomalous = true
+	dp = NewDataPoint()
+	dp.FromSummary(s)
+	if dp.Fields["rtt_deviation"] != 2.5 || dp.Fields["loss_anomalous"] != 1 ||
+		dp.Fields["rtt_anomalous"] != 0 {
+		t.Error("Baseline fields not populated:", dp.Fields)
+	}
 }
 
 func TestFromPD(t *testing.T) {
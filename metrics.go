@@ -0,0 +1,278 @@
+// Metrics provides simple counters and gauges for tracking the internal
+// health of LLAMA components, exposed in the Prometheus text format.
+package llama
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMetrics is the registry used by LLAMA components for their own
+// metrics, similar to how flags are registered globally.
+var DefaultMetrics = NewMetricsRegistry()
+
+// Counter is a value which only increases, and is safe for concurrent use.
+//
+// A nil Counter can be used, and discards all updates. This allows
+// components created without their constructor to still function.
+type Counter struct {
+	value uint64 // Must be first for 64-bit alignment of atomic operations
+}
+
+// Inc increments the counter by 1.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increments the counter by n.
+func (c *Counter) Add(n uint64) {
+	if c == nil {
+		return
+	}
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value provides the current value of the counter.
+func (c *Counter) Value() uint64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.value)
+}
+
+// FloatCounter is a Counter for fractional values, like durations in seconds.
+//
+// Like Counter, a nil FloatCounter discards all updates.
+type FloatCounter struct {
+	bits uint64
+}
+
+// Add increments the counter by v.
+func (c *FloatCounter) Add(v float64) {
+	if c == nil {
+		return
+	}
+	for {
+		old := atomic.LoadUint64(&c.bits)
+		updated := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&c.bits, old, updated) {
+			return
+		}
+	}
+}
+
+// AddDuration increments the counter by d, in seconds.
+func (c *FloatCounter) AddDuration(d time.Duration) {
+	c.Add(d.Seconds())
+}
+
+// Value provides the current value of the counter.
+func (c *FloatCounter) Value() float64 {
+	if c == nil {
+		return 0
+	}
+	return math.Float64frombits(atomic.LoadUint64(&c.bits))
+}
+
+// Gauge is a value which can go up and down, and is safe for concurrent use.
+//
+// Like Counter, a nil Gauge discards all updates.
+type Gauge struct {
+	bits uint64
+}
+
+// Set updates the gauge to v.
+func (g *Gauge) Set(v float64) {
+	if g == nil {
+		return
+	}
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Value provides the current value of the gauge.
+func (g *Gauge) Value() float64 {
+	if g == nil {
+		return 0
+	}
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// metricSeries is a single set of labels for a metric, and how to get the
+// current value.
+type metricSeries struct {
+	labels Tags
+	value  func() float64
+	metric interface{} // The Counter, FloatCounter, or Gauge, if any
+}
+
+// metricFamily is all of the series for a single metric name.
+type metricFamily struct {
+	help   string
+	kind   string
+	series map[string]*metricSeries // Keyed on the formatted labels
+}
+
+// MetricsRegistry keeps track of metrics, so they can all be written out
+// together.
+type MetricsRegistry struct {
+	mutex    sync.RWMutex
+	families map[string]*metricFamily
+}
+
+// labelsKey provides a consistent string representation of labels.
+func labelsKey(labels Tags) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// register finds the existing series for the name and labels, or creates it
+// with the values provided by `create`.
+func (r *MetricsRegistry) register(name string, help string, kind string,
+	labels Tags, create func() *metricSeries) *metricSeries {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	family, found := r.families[name]
+	if !found {
+		family = &metricFamily{
+			help:   help,
+			kind:   kind,
+			series: make(map[string]*metricSeries),
+		}
+		r.families[name] = family
+	}
+	key := labelsKey(labels)
+	series, found := family.series[key]
+	if !found {
+		series = create()
+		series.labels = labels
+		family.series[key] = series
+	}
+	return series
+}
+
+// Counter provides the Counter for the name and labels, creating it if needed.
+func (r *MetricsRegistry) Counter(name string, help string, labels Tags) *Counter {
+	series := r.register(name, help, "counter", labels, func() *metricSeries {
+		c := &Counter{}
+		return &metricSeries{
+			value:  func() float64 { return float64(c.Value()) },
+			metric: c,
+		}
+	})
+	c, ok := series.metric.(*Counter)
+	if !ok {
+		// Registered as something else, so don't track it
+		return nil
+	}
+	return c
+}
+
+// FloatCounter provides the FloatCounter for the name and labels, creating it
+// if needed.
+func (r *MetricsRegistry) FloatCounter(name string, help string, labels Tags) *FloatCounter {
+	series := r.register(name, help, "counter", labels, func() *metricSeries {
+		c := &FloatCounter{}
+		return &metricSeries{value: c.Value, metric: c}
+	})
+	c, ok := series.metric.(*FloatCounter)
+	if !ok {
+		return nil
+	}
+	return c
+}
+
+// Gauge provides the Gauge for the name and labels, creating it if needed.
+func (r *MetricsRegistry) Gauge(name string, help string, labels Tags) *Gauge {
+	series := r.register(name, help, "gauge", labels, func() *metricSeries {
+		g := &Gauge{}
+		return &metricSeries{value: g.Value, metric: g}
+	})
+	g, ok := series.metric.(*Gauge)
+	if !ok {
+		return nil
+	}
+	return g
+}
+
+// GaugeFunc registers a gauge for the name and labels, which calls `fn` to
+// get the current value. Any existing one is replaced.
+//
+// `fn` must be safe to call concurrently, and shouldn't hold on to anything
+// which would otherwise be garbage collected.
+func (r *MetricsRegistry) GaugeFunc(name string, help string, labels Tags, fn func() float64) {
+	r.Unregister(name, labels)
+	r.register(name, help, "gauge", labels, func() *metricSeries {
+		return &metricSeries{value: fn}
+	})
+}
+
+// Unregister removes the series for the name and labels.
+func (r *MetricsRegistry) Unregister(name string, labels Tags) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	family, found := r.families[name]
+	if !found {
+		return
+	}
+	delete(family.series, labelsKey(labels))
+}
+
+// Write writes all of the registered metrics to w in the Prometheus text
+// format, sorted by name and labels.
+func (r *MetricsRegistry) Write(w io.Writer) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		family := r.families[name]
+		if len(family.series) == 0 {
+			continue
+		}
+		WritePromHeader(w, name, family.help, family.kind)
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			series := family.series[key]
+			WritePromSample(w, name, series.labels, series.value())
+		}
+	}
+}
+
+// Snapshot provides the current value of every series, keyed by name and
+// then by formatted labels. This is mostly useful for tests and status
+// output.
+func (r *MetricsRegistry) Snapshot() map[string]map[string]float64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	snapshot := make(map[string]map[string]float64)
+	for name, family := range r.families {
+		values := make(map[string]float64)
+		for key, series := range family.series {
+			values[key] = series.value()
+		}
+		snapshot[name] = values
+	}
+	return snapshot
+}
+
+// NewMetricsRegistry creates an empty MetricsRegistry.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{families: make(map[string]*metricFamily)}
+}
@@ -0,0 +1,70 @@
+package llama
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	r := NewMetricsRegistry()
+	c := r.Counter("test_total", "A counter.", Tags{"port": "a"})
+	c.Inc()
+	c.Add(2)
+	// The same name and labels provide the same counter
+	if r.Counter("test_total", "A counter.", Tags{"port": "a"}) != c {
+		t.Error("Expected the existing counter to be reused")
+	}
+	r.Counter("test_total", "A counter.", Tags{"port": "b"}).Inc()
+	f := r.FloatCounter("test_seconds_total", "A float counter.", nil)
+	f.AddDuration(1500 * time.Millisecond)
+	r.Gauge("test_gauge", "A gauge.", nil).Set(4.5)
+	r.GaugeFunc("test_func", "A gauge func.", nil, func() float64 { return 7 })
+	// Mismatched types aren't tracked
+	if r.Gauge("test_total", "Not a gauge.", Tags{"port": "a"}) != nil {
+		t.Error("Expected nil for a name registered as a different type")
+	}
+	var buf bytes.Buffer
+	r.Write(&buf)
+	expected := `# HELP test_func A gauge func.
+# TYPE test_func gauge
+test_func 7
+# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge 4.5
+# HELP test_seconds_total A float counter.
+# TYPE test_seconds_total counter
+test_seconds_total 1.5
+# HELP test_total A counter.
+# TYPE test_total counter
+test_total{port="a"} 3
+test_total{port="b"} 1
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+	// Unregistered series, and empty families, aren't written
+	r.Unregister("test_func", nil)
+	buf.Reset()
+	r.Write(&buf)
+	if strings.Contains(buf.String(), "test_func") {
+		t.Error("Unregistered metric still written")
+	}
+	if r.Snapshot()["test_total"][`port="b"`] != 1 {
+		t.Error("Unexpected snapshot:", r.Snapshot())
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	// These should all be safe to use on components created without them
+	var c *Counter
+	c.Inc()
+	var f *FloatCounter
+	f.Add(1)
+	var g *Gauge
+	g.Set(1)
+	if c.Value() != 0 || f.Value() != 0 || g.Value() != 0 {
+		t.Error("Nil metrics should have no value")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	gocache "github.com/patrickmn/go-cache"
@@ -23,6 +24,53 @@ type Port struct {
 	cbc         chan *Probe       // Callback channel for sending expired Probes
 	readTimeout time.Duration     // How long to wait for reads
 	basePD      *PathDist         // A partially filled PathDist based on conn
+	metrics     portMetrics       // Counters for the health of the port
+	padding     []byte            // Included in probes to fill them out
+	errs        chan error        // Send failures, if they aren't fatal
+	stopped     int32             // Set once stopped, to drop expired probes
+}
+
+// DefaultPadding is the number of bytes probes are padded with, unless a size
+// is set with SetSize.
+const DefaultPadding = 1000
+
+// MaxProbeSize is the largest size probes can be set to, so that they fit in
+// the buffers used for receiving them.
+const MaxProbeSize = 4000
+
+// portMetrics tracks what's happening on a Port, so problems in the collector
+// itself can be told apart from loss on the network.
+type portMetrics struct {
+	sent      *Counter // Probes written to the socket
+	received  *Counter // Probes read from the socket
+	unmatched *Counter // Received probes without a cache entry
+	cached    *Gauge   // Probes waiting in the cache
+}
+
+// portMetricNames are the names of every metric in portMetrics.
+var portMetricNames = []string{
+	"llama_port_probes_sent_total",
+	"llama_port_probes_received_total",
+	"llama_port_probes_unmatched_total",
+	"llama_port_cache_items",
+}
+
+// newPortMetrics registers the metrics for the port with the local address.
+//
+// Ports are recreated on reload, but often on the same address, so these are
+// never unregistered. Doing so could remove the metrics of the replacement.
+func newPortMetrics(addr string) portMetrics {
+	labels := Tags{"port": addr}
+	return portMetrics{
+		sent: DefaultMetrics.Counter("llama_port_probes_sent_total",
+			"Probes sent from the port.", labels),
+		received: DefaultMetrics.Counter("llama_port_probes_received_total",
+			"Probes received back on the port.", labels),
+		unmatched: DefaultMetrics.Counter("llama_port_probes_unmatched_total",
+			"Probes received with an unknown or already expired signature.", labels),
+		cached: DefaultMetrics.Gauge("llama_port_cache_items",
+			"Probes in the port's cache, waiting to be received or expire.", labels),
+	}
 }
 
 // srcPD creates a PathDist based on the known socket details for the port.
@@ -61,6 +109,45 @@ func (p *Port) pd(dst *net.UDPAddr) *PathDist {
 	return pathDist
 }
 
+// SetSize pads probes sent from the port so they're as close to size bytes as
+// possible, not including UDP/IP headers. Probes can't be smaller than their
+// required fields, or larger than MaxProbeSize.
+//
+// This must NOT be used after running.
+func (p *Port) SetSize(size int) {
+	if size > MaxProbeSize {
+		size = MaxProbeSize
+	}
+	p.padding = make([]byte, ProbePadding(size))
+}
+
+// SetErrors makes failures to send probes be provided on errs, rather than
+// being fatal, for ports sending to targets which may not be reachable.
+//
+// This must NOT be used after running.
+func (p *Port) SetErrors(errs chan error) {
+	p.errs = errs
+}
+
+// ProbePadding determines the padding needed for a probe to be as close to
+// size bytes as possible, without going over.
+func ProbePadding(size int) int {
+	probe := pb.Probe{
+		Signature: make([]byte, 10),
+		Tos:       []byte{0},
+		Sent:      NowUint64(),
+	}
+	// The padding's length prefix grows with it, so work down from the most
+	// it could be.
+	for padding := size - probe.ProtoSize(); padding > 0; padding-- {
+		probe.Padding = make([]byte, padding)
+		if probe.ProtoSize() <= size {
+			return padding
+		}
+	}
+	return 0
+}
+
 // ToS provides the currently active ToS byte value for the port's conn.
 func (p *Port) Tos() byte {
 	val := GetTos(p.conn)
@@ -100,23 +187,26 @@ func (p *Port) send() {
 			//             making `now` more stale as things are going on.
 			p.cache.SetDefault(key, &probe)
 			signature := IDToBytes(key)
-			var padding [1000]byte
 			data := pb.Probe{
 				Signature: signature[:],
 				Tos:       []byte{tos},
 				Sent:      now,
-				// TODO(dmar): This should be customizable, and relative to
-				//			   to the rest of the probe. This should really
-				//             be used to fill to a maximum size.
-				//			   Likely based on the return from Marshal.
-				Padding: padding[:],
+				Padding:   p.padding,
 			}
 			packedData, err := data.Marshal()
 			HandleError(err)
 			// Send the probe
 			_, err = p.conn.WriteToUDP(packedData, addr)
+			if err != nil && p.errs != nil {
+				// Only the first matters to whoever is waiting
+				select {
+				case p.errs <- err:
+				default:
+				}
+				continue
+			}
 			HandleError(err)
-			// TODO(dmar): Log rate of `packets_sent`
+			p.metrics.sent.Inc()
 		}
 	}
 }
@@ -134,20 +224,28 @@ func (p *Port) recv() {
 	dataBuf := make([]byte, 4096) // Reuse this for the received data
 	// This will be implemented for timestamps in the future
 	oobBuf := make([]byte, 4096) // Reuse this for the received oob data
+	// Counting cache items takes a lock shared with the expiration handling,
+	// so only do it periodically.
+	var lastCounted time.Time
 	for {
 		select {
 		case <-p.stop:
 			log.Println("Stopping Port.recv for:", p.conn.LocalAddr())
 			// Don't process expirations anymore
 			// This prevents outstanding probes from reporting as loss
-			// NOTE(dmar): Setting this to an empty function instead of nil, as
-			//   as the underlying library has a race condition, which can result
-			//   in a nil pointer refernce (it calls nil like a function).
-			p.cache.OnEvicted(func(s string, i interface{}) {})
+			// NOTE: The cache's janitor calls the eviction callback without
+			//   holding its lock, so swapping the callback while it runs is a
+			//   race. The callback checks this instead.
+			atomic.StoreInt32(&p.stopped, 1)
 			return // Stop receiving
 		default:
 			// This is a specific point in time, so it needs to be refreshed
-			timeout := time.Now().Add(p.readTimeout)
+			now := time.Now()
+			if now.Sub(lastCounted) >= time.Second {
+				p.metrics.cached.Set(float64(p.cache.ItemCount()))
+				lastCounted = now
+			}
+			timeout := now.Add(p.readTimeout)
 			err := p.conn.SetReadDeadline(timeout)
 			HandleError(err)
 			// TODO(dmar):
@@ -180,6 +278,7 @@ func (p *Port) recv() {
 						"\n", err.Error())
 				}
 			}
+			p.metrics.received.Inc()
 			data := dataBuf[0:dataLen]
 			udpData := &pb.Probe{}
 			err = udpData.Unmarshal(data)
@@ -190,7 +289,7 @@ func (p *Port) recv() {
 			if !found {
 				// This means it expired already or doesn't exist
 				// so there's nothing to do.
-				// TODO(dmar): Log/stat on occurrences of this
+				p.metrics.unmatched.Inc()
 				continue
 			}
 			// TODO(dmar): Make wish to make a `ProbeCache` that does this
@@ -201,9 +300,10 @@ func (p *Port) recv() {
 			probe.CRcvd = NowUint64()
 			// Error would be if the key didn't exist, meaning it expired
 			// since the Get above. Rare but possible. Acceptable for now.
-			// TODO(dmar): Log/stat on occurrences of this
-			_ = p.cache.Replace(id, probe, ExpireNow)
-			// TODO(dmar): Log rate of `packets_received`
+			err = p.cache.Replace(id, probe, ExpireNow)
+			if err != nil {
+				p.metrics.unmatched.Inc()
+			}
 		}
 	}
 }
@@ -214,6 +314,9 @@ func (p *Port) recv() {
 // This basically just exists to the do the type conversion and pass to the
 // channel.
 func (p *Port) done(key string, value interface{}) {
+	if atomic.LoadInt32(&p.stopped) == 1 {
+		return
+	}
 	probe, err := IfaceToProbe(value)
 	HandleMinorError(err)
 	p.cbc <- probe
@@ -261,7 +364,9 @@ func NewPort(conn *net.UDPConn, tosend chan *net.UDPAddr, stop chan bool,
 	cache := gocache.New(cTimeout, cCleanRate)
 	// Create the port
 	port := Port{tosend: tosend, conn: conn, cache: cache,
-		stop: stop, cbc: cbc, readTimeout: readTimeout}
+		stop: stop, cbc: cbc, readTimeout: readTimeout,
+		metrics: newPortMetrics(conn.LocalAddr().String()),
+		padding: make([]byte, DefaultPadding)}
 	// Used for wrapping the callback channel
 	port.cache.OnEvicted(port.done)
 	// Ensure that when the port is stopped, we cleanup.
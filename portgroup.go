@@ -9,10 +9,11 @@ import (
 )
 
 type PortGroup struct {
-	ports  map[*Port](chan *net.UDPAddr)
-	stop   chan bool
-	cbc    chan *Probe
-	tosend chan *net.UDPAddr
+	ports   map[*Port](chan *net.UDPAddr)
+	stop    chan bool
+	cbc     chan *Probe
+	tosend  chan *net.UDPAddr
+	blocked *FloatCounter // Time spent waiting on ports in mux
 }
 
 // Add will add a Port and channel to the PortGroup.
@@ -29,16 +30,31 @@ func (pg *PortGroup) AddNew(portStr string, tos byte, cTimeout time.Duration,
 	cCleanRate time.Duration,
 	readTimeout time.Duration) (
 	*Port, chan *net.UDPAddr) {
+	p, input, err := pg.TryAddNew(portStr, tos, cTimeout, cCleanRate, readTimeout)
+	HandleError(err)
+	return p, input
+}
+
+// TryAddNew is like AddNew, but provides an error if the Port can't be
+// created, rather than exiting.
+func (pg *PortGroup) TryAddNew(portStr string, tos byte, cTimeout time.Duration,
+	cCleanRate time.Duration,
+	readTimeout time.Duration) (
+	*Port, chan *net.UDPAddr, error) {
 	/* Because of typing and how net works, it's just cleaner to pass in a
 	   string that identifies the addr/port Oddly enough, passing in a
 	   port number and net.IP object would involve more conversions.
 	*/
 	// Create the address/port we want
 	addr, err := net.ResolveUDPAddr("udp", portStr)
-	HandleError(err)
+	if err != nil {
+		return nil, nil, err
+	}
 	// Grab that socket
 	conn, err := net.ListenUDP("udp", addr)
-	HandleError(err)
+	if err != nil {
+		return nil, nil, err
+	}
 	// Update the ToS value for the socket
 	SetTos(conn, tos)
 	// Tell the socket to keep timestamps
@@ -48,7 +64,10 @@ func (pg *PortGroup) AddNew(portStr string, tos byte, cTimeout time.Duration,
 	// TODO(dmar): This should be configurable higher up, as well want to be
 	//             able to tweak this behavior more easily in the config.
 	err = conn.SetReadBuffer(DefaultRcvBuff)
-	HandleError(err)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
 	// TODO(dmar): May want to set a global/default buffer size for use here
 	input := make(chan *net.UDPAddr, 10)
 	// Create the port
@@ -63,7 +82,7 @@ func (pg *PortGroup) AddNew(portStr string, tos byte, cTimeout time.Duration,
 	)
 	// Add it to the port group
 	pg.Add(p, input)
-	return p, input
+	return p, input, nil
 }
 
 // Del removes a Port from the PortGroup.
@@ -118,11 +137,15 @@ func (pg *PortGroup) run() {
 // here, similar to Add and Del.
 func (pg *PortGroup) mux(addr *net.UDPAddr) {
 	for _, c := range pg.ports {
-		// TODO(dmar): Update this with a select and default in the future
-		//     if we want to track cases where something breaks here.
-		//     Tried it before, but apparently hit some weird issues.
-		c <- addr
-		continue
+		select {
+		case c <- addr:
+		default:
+			// The port isn't keeping up, so still wait for it, but keep track
+			// of how long. Otherwise this just looks like fewer probes sent.
+			start := time.Now()
+			c <- addr
+			pg.blocked.AddDuration(time.Since(start))
+		}
 	}
 }
 
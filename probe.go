@@ -0,0 +1,471 @@
+// One-off probes, run on demand from the collector's vantage point.
+package llama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Defaults and limits for ProbeRequests, so a single request can't take over
+// the collector.
+const (
+	DefaultProbeDuration = 10     // In seconds
+	DefaultProbeRate     = 10.0   // Probes per second, from each port
+	DefaultProbePorts    = 1      // Source ports to send from
+	DefaultProbeTimeout  = 1000   // In milliseconds
+	MaxProbeDuration     = 300    // In seconds
+	MaxProbeRate         = 1000.0 // Probes per second, from each port
+	MaxProbePorts        = 16     // Source ports to send from
+	MaxProbeTimeout      = 10000  // In milliseconds
+	DefaultProbeJobs     = 4      // Running at once
+	DefaultProbeHistory  = 100    // Finished jobs retained for polling
+)
+
+// Statuses of a ProbeJob
+const (
+	probeJobRunning = "running"
+	probeJobDone    = "done"
+	probeJobFailed  = "failed"
+)
+
+// ErrProbesBusy is provided when the maximum number of probes are already
+// running.
+var ErrProbesBusy = errors.New("Too many probes running")
+
+// ProbeRequest describes a one-off test to a single target.
+type ProbeRequest struct {
+	Target   string  `json:"target"`   // "host:port" of a reflector
+	Duration int64   `json:"duration"` // In seconds
+	Rate     float64 `json:"rate"`     // Probes per second, from each port
+	Tos      byte    `json:"tos"`
+	Size     int     `json:"size"`    // In bytes, or the default padding if 0
+	Ports    int     `json:"ports"`   // Source ports to send from
+	Timeout  int64   `json:"timeout"` // In milliseconds
+
+	addr *net.UDPAddr // The target, once resolved by Validate
+}
+
+// Validate fills in defaults for anything not provided, and checks that the
+// request is within limits. The target is resolved, and must be a single
+// host, rather than a broadcast, multicast or unspecified address.
+func (r *ProbeRequest) Validate() error {
+	if r.Duration == 0 {
+		r.Duration = DefaultProbeDuration
+	}
+	if r.Rate == 0 {
+		r.Rate = DefaultProbeRate
+	}
+	if r.Ports == 0 {
+		r.Ports = DefaultProbePorts
+	}
+	if r.Timeout == 0 {
+		r.Timeout = DefaultProbeTimeout
+	}
+	if _, _, err := net.SplitHostPort(r.Target); err != nil {
+		return fmt.Errorf("Invalid target: %s", err)
+	}
+	addr, err := net.ResolveUDPAddr("udp", r.Target)
+	if err != nil {
+		return fmt.Errorf("Invalid target: %s", err)
+	}
+	if addr.IP == nil || addr.IP.IsUnspecified() || addr.IP.IsMulticast() || isBroadcast(addr.IP) {
+		return fmt.Errorf("Invalid target: %s is not a single host", addr.IP)
+	}
+	r.addr = addr
+	if r.Duration < 0 || r.Duration > MaxProbeDuration {
+		return fmt.Errorf("Duration must be between 1 and %d seconds", MaxProbeDuration)
+	}
+	if r.Rate < 0 || r.Rate > MaxProbeRate {
+		return fmt.Errorf("Rate must be between 0 and %v per second", MaxProbeRate)
+	}
+	if r.Ports < 0 || r.Ports > MaxProbePorts {
+		return fmt.Errorf("Ports must be between 1 and %d", MaxProbePorts)
+	}
+	if r.Timeout < 0 || r.Timeout > MaxProbeTimeout {
+		return fmt.Errorf("Timeout must be between 1 and %d milliseconds", MaxProbeTimeout)
+	}
+	if r.Size < 0 || r.Size > MaxProbeSize {
+		return fmt.Errorf("Size must be between 0 and %d bytes", MaxProbeSize)
+	}
+	return nil
+}
+
+// isBroadcast determines if ip is the limited broadcast address, or the
+// broadcast address of a subnet on one of the local interfaces.
+func isBroadcast(ip net.IP) bool {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		// IPv6 doesn't have broadcast
+		return false
+	}
+	if ip4.Equal(net.IPv4bcast) {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil || !ipNet.Contains(ip4) {
+			continue
+		}
+		mask := ipNet.Mask
+		if len(mask) == net.IPv6len {
+			mask = mask[12:]
+		}
+		// Subnets without room for a broadcast address don't have one
+		if ones, bits := ipNet.Mask.Size(); bits-ones < 2 {
+			continue
+		}
+		bcast := make(net.IP, net.IPv4len)
+		for i := range bcast {
+			bcast[i] = ipNet.IP.To4()[i] | ^mask[i]
+		}
+		if bcast.Equal(ip4) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProbeRTT describes the distribution of RTTs, in milliseconds.
+type ProbeRTT struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// NewProbeRTT calculates the RTT distribution for the results which weren't
+// lost.
+func NewProbeRTT(results []*Result) ProbeRTT {
+	var values []float64
+	for _, r := range results {
+		if !r.Lost {
+			values = append(values, NsToMs(float64(r.RTT)))
+		}
+	}
+	if len(values) == 0 {
+		return ProbeRTT{}
+	}
+	sort.Float64s(values)
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return ProbeRTT{
+		Min: values[0],
+		Avg: total / float64(len(values)),
+		P50: percentile(values, 50),
+		P90: percentile(values, 90),
+		P99: percentile(values, 99),
+		Max: values[len(values)-1],
+	}
+}
+
+// percentile provides the nearest-rank percentile p of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// ProbePortResult is the result for probes sent from a single source port.
+type ProbePortResult struct {
+	SrcIP   string   `json:"src_ip"`
+	SrcPort int      `json:"src_port"`
+	Sent    int      `json:"sent"`
+	Lost    int      `json:"lost"`
+	Loss    float64  `json:"loss"`
+	RTT     ProbeRTT `json:"rtt"`
+}
+
+// ProbeResult is the result of a ProbeRequest, overall and for each source
+// port, since they may take different paths.
+type ProbeResult struct {
+	Target string             `json:"target"`
+	Start  time.Time          `json:"start"`
+	End    time.Time          `json:"end"`
+	Sent   int                `json:"sent"`
+	Lost   int                `json:"lost"`
+	Loss   float64            `json:"loss"`
+	RTT    ProbeRTT           `json:"rtt"`
+	Ports  []*ProbePortResult `json:"ports"`
+}
+
+// NewProbeResult summarizes the results of probes sent to target.
+func NewProbeResult(target string, results []*Result) *ProbeResult {
+	summary := &Summary{}
+	CalcCounts(results, summary)
+	if summary.Sent > 0 {
+		CalcLoss(summary)
+	}
+	pr := &ProbeResult{
+		Target: target,
+		Sent:   summary.Sent,
+		Lost:   summary.Lost,
+		Loss:   summary.Loss,
+		RTT:    NewProbeRTT(results),
+		//nolint:gosimple
+		Ports: make([]*ProbePortResult, 0), // To avoid JSON issues with nil
+	}
+	// Split up by source, since each port is a separate path
+	bySrc := make(map[string][]*Result)
+	var srcs []string
+	for _, r := range results {
+		key := net.JoinHostPort(r.Pd.SrcIP.String(), strconv.Itoa(r.Pd.SrcPort))
+		if _, found := bySrc[key]; !found {
+			srcs = append(srcs, key)
+		}
+		bySrc[key] = append(bySrc[key], r)
+	}
+	sort.Strings(srcs)
+	for _, src := range srcs {
+		set := bySrc[src]
+		portSummary := &Summary{}
+		CalcCounts(set, portSummary)
+		CalcLoss(portSummary)
+		pr.Ports = append(pr.Ports, &ProbePortResult{
+			SrcIP:   set[0].Pd.SrcIP.String(),
+			SrcPort: set[0].Pd.SrcPort,
+			Sent:    portSummary.Sent,
+			Lost:    portSummary.Lost,
+			Loss:    portSummary.Loss,
+			RTT:     NewProbeRTT(set),
+		})
+	}
+	return pr
+}
+
+// ProbeJob tracks a ProbeRequest which is running or has finished.
+type ProbeJob struct {
+	ID      string        `json:"id"`
+	Status  string        `json:"status"`
+	Request *ProbeRequest `json:"request"`
+	Result  *ProbeResult  `json:"result,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	done    chan bool     // Closed once the job finishes
+}
+
+// Wait blocks until the job has finished, or ctx is done. The job isn't
+// updated, so it needs to be retrieved again afterwards.
+func (j ProbeJob) Wait(ctx context.Context) error {
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Prober runs ProbeRequests with their own Ports, separate from any tests,
+// and keeps their results around for polling.
+type Prober struct {
+	mutex   sync.Mutex
+	jobs    map[string]*ProbeJob
+	order   []string // Job IDs, oldest first
+	nextID  int64
+	running int
+	maxJobs int
+	history int
+	addr    string // Where ports listen
+	runFunc func(req *ProbeRequest) (*ProbeResult, error)
+}
+
+// Start validates req and starts running it, providing a copy of the job to
+// poll.
+func (p *Prober) Start(req *ProbeRequest) (ProbeJob, error) {
+	err := req.Validate()
+	if err != nil {
+		return ProbeJob{}, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.running >= p.maxJobs {
+		return ProbeJob{}, ErrProbesBusy
+	}
+	p.running++
+	p.nextID++
+	job := &ProbeJob{
+		ID:      strconv.FormatInt(p.nextID, 10),
+		Status:  probeJobRunning,
+		Request: req,
+		done:    make(chan bool),
+	}
+	p.jobs[job.ID] = job
+	p.order = append(p.order, job.ID)
+	// Forget the oldest finished jobs, but never running ones
+	for len(p.order) > p.history {
+		oldest := p.jobs[p.order[0]]
+		if oldest.Status == probeJobRunning {
+			break
+		}
+		delete(p.jobs, oldest.ID)
+		p.order = p.order[1:]
+	}
+	go p.run(job)
+	return *job, nil
+}
+
+// run runs the job's request, and records how it went.
+func (p *Prober) run(job *ProbeJob) {
+	log.Println("Starting probe", job.ID, "to", job.Request.Target)
+	result, err := p.runFunc(job.Request)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.running--
+	if err != nil {
+		log.Println("Probe", job.ID, "failed:", err)
+		job.Status = probeJobFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = probeJobDone
+		job.Result = result
+	}
+	close(job.done)
+}
+
+// Job provides a copy of the job with the ID, if it's still retained.
+func (p *Prober) Job(id string) (ProbeJob, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	job, found := p.jobs[id]
+	if !found {
+		return ProbeJob{}, false
+	}
+	return *job, true
+}
+
+// runProbe sends probes as described by req and waits for them to complete
+// or expire, before summarizing them.
+//
+// The target isn't one the collector was configured with, so failing to
+// bind or send fails the probe, rather than the collector.
+func (p *Prober) runProbe(req *ProbeRequest) (*ProbeResult, error) {
+	if req.addr == nil {
+		err := req.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	target := req.addr
+	timeout := time.Duration(req.Timeout) * time.Millisecond
+	stop := make(chan bool)
+	cbc := make(chan *Probe, DEFAULT_CHANNEL_SIZE)
+	tosend := make(chan *net.UDPAddr)
+	errs := make(chan error, 1)
+	pg := NewPortGroup(stop, cbc, tosend)
+	var addrs []string
+	// However this ends, the ports are stopped, and closed once collected
+	defer func() {
+		close(stop)
+		// Ports may still be expiring probes until they see the stop, so
+		// keep draining until then.
+		go func() {
+			done := time.After(timeout + DefaultSummarizerMargin)
+			for {
+				select {
+				case <-cbc:
+				case <-done:
+					return
+				}
+			}
+		}()
+		// The ports are gone, so their metrics don't need to stick around
+		for _, addr := range addrs {
+			for _, name := range portMetricNames {
+				DefaultMetrics.Unregister(name, Tags{"port": addr})
+			}
+		}
+	}()
+	for i := 0; i < req.Ports; i++ {
+		port, _, err := pg.TryAddNew(p.addr, req.Tos, timeout, timeout, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create port: %v", err)
+		}
+		if req.Size > 0 {
+			port.SetSize(req.Size)
+		}
+		port.SetErrors(errs)
+		addrs = append(addrs, port.conn.LocalAddr().String())
+	}
+	pg.Run()
+	start := time.Now()
+
+	// Send until the duration is up, while collecting anything that's
+	// already completed.
+	var results []*Result
+	sent := 0
+	ctx, cancel := context.WithTimeout(context.Background(),
+		time.Duration(req.Duration)*time.Second)
+	defer cancel()
+	// A single probe at a time, so the rate is spread evenly
+	rl := rate.NewLimiter(rate.Limit(req.Rate), 1)
+	var sendErr error
+	for sendErr == nil && rl.Wait(ctx) == nil {
+		select {
+		case tosend <- target:
+			sent++
+		case sendErr = <-errs:
+		case <-ctx.Done():
+		}
+		for len(cbc) > 0 {
+			results = append(results, Process(<-cbc))
+		}
+	}
+	// Then wait for the rest to be received or expire. Like the summarizer,
+	// expiring a probe can take up to twice the timeout.
+	expected := sent * req.Ports
+	deadline := time.After(2*timeout + DefaultSummarizerMargin)
+	for sendErr == nil && len(results) < expected {
+		var probe *Probe
+		select {
+		case probe = <-cbc:
+		case <-deadline:
+			log.Println("Probe to", req.Target, "gave up waiting on",
+				expected-len(results), "probes")
+		}
+		if probe == nil {
+			break
+		}
+		results = append(results, Process(probe))
+	}
+	if sendErr == nil && len(errs) > 0 {
+		sendErr = <-errs
+	}
+	if sendErr != nil {
+		return nil, fmt.Errorf("Failed to send probes: %v", sendErr)
+	}
+	pr := NewProbeResult(req.Target, results)
+	pr.Start = start
+	pr.End = time.Now()
+	return pr, nil
+}
+
+// NewProber creates a Prober which runs up to maxJobs requests at once, and
+// retains up to history jobs for polling.
+func NewProber(maxJobs int, history int) *Prober {
+	p := &Prober{
+		jobs:    make(map[string]*ProbeJob),
+		maxJobs: maxJobs,
+		history: history,
+		addr:    DefaultAddrStr,
+	}
+	p.runFunc = p.runProbe
+	return p
+}
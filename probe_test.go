@@ -0,0 +1,211 @@
+package llama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestProbeRequestValidate(t *testing.T) {
+	req := &ProbeRequest{Target: "10.0.0.1:8100"}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if req.Duration != DefaultProbeDuration || req.Ports != DefaultProbePorts {
+		t.Error("Expected defaults to be filled in, got", req)
+	}
+	for _, req := range []*ProbeRequest{
+		{Target: "10.0.0.1"},
+		{Target: "10.0.0.1:8100", Duration: MaxProbeDuration + 1},
+		{Target: "10.0.0.1:8100", Ports: MaxProbePorts + 1},
+		{Target: "10.0.0.1:8100", Size: MaxProbeSize + 1},
+		// Only single hosts can be probed
+		{Target: "255.255.255.255:8100"},
+		{Target: "224.0.0.1:8100"},
+		{Target: "[ff02::1]:8100"},
+		{Target: "0.0.0.0:8100"},
+		{Target: ":8100"},
+	} {
+		if req.Validate() == nil {
+			t.Error("Expected an error for", req)
+		}
+	}
+}
+
+func TestProbePadding(t *testing.T) {
+	for _, size := range []int{100, 127, 128, 200, 1000, MaxProbeSize} {
+		padding := ProbePadding(size)
+		if padding <= 0 || padding >= size {
+			t.Error("Unexpected padding for", size, "got", padding)
+		}
+	}
+	if ProbePadding(10) != 0 {
+		t.Error("Expected no padding for a tiny size")
+	}
+}
+
+func TestNewProbeResult(t *testing.T) {
+	src1 := &PathDist{SrcIP: net.ParseIP("10.0.0.1"), SrcPort: 1}
+	src2 := &PathDist{SrcIP: net.ParseIP("10.0.0.1"), SrcPort: 2}
+	var results []*Result
+	for i := 1; i <= 100; i++ {
+		results = append(results, &Result{Pd: src1, RTT: uint64(i) * 1000000})
+	}
+	results = append(results, &Result{Pd: src2, Lost: true}, &Result{Pd: src2, RTT: 5000000})
+	pr := NewProbeResult("10.0.0.2:8100", results)
+	if pr.Sent != 102 || pr.Lost != 1 {
+		t.Error("Unexpected counts:", pr.Sent, pr.Lost)
+	}
+	if pr.RTT.Min != 1 || pr.RTT.Max != 100 || pr.RTT.P50 != 50 || pr.RTT.P99 != 99 {
+		t.Error("Unexpected RTT distribution:", pr.RTT)
+	}
+	if len(pr.Ports) != 2 || pr.Ports[0].SrcPort != 1 || pr.Ports[1].Loss != 50 {
+		t.Error("Unexpected per port results:", pr.Ports)
+	}
+}
+
+func TestProberRun(t *testing.T) {
+	addr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The reflector can't be stopped, so it's left running
+	go Reflect(conn, rate.NewLimiter(rate.Inf, 0))
+
+	p := NewProber(1, DefaultProbeHistory)
+	p.addr = "127.0.0.1:0"
+	job, err := p.Start(&ProbeRequest{
+		Target:   conn.LocalAddr().String(),
+		Duration: 1,
+		Rate:     20,
+		Ports:    2,
+		Size:     200,
+		Timeout:  200,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only one can run at once
+	_, err = p.Start(&ProbeRequest{Target: conn.LocalAddr().String()})
+	if err != ErrProbesBusy {
+		t.Error("Expected ErrProbesBusy, got", err)
+	}
+	HandleMinorError(job.Wait(context.Background()))
+	job, _ = p.Job(job.ID)
+	if job.Status != probeJobDone {
+		t.Fatal("Expected the probe to succeed, got", job.Status, job.Error)
+	}
+	if job.Result.Sent < 20 || job.Result.Lost != 0 || len(job.Result.Ports) != 2 {
+		t.Error("Unexpected result:", job.Result.Sent, job.Result.Lost, job.Result.Ports)
+	}
+}
+
+func TestProberRunErrors(t *testing.T) {
+	p := NewProber(1, DefaultProbeHistory)
+	// An IPv6 target can't be sent to from an IPv4 port
+	p.addr = "127.0.0.1:0"
+	req := &ProbeRequest{Target: "[::1]:8100", Duration: 1, Timeout: 100}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := p.runProbe(req)
+	if err == nil {
+		t.Error("Expected an error when probes can't be sent")
+	}
+	// Nor can ports be created on an invalid address
+	p.addr = "127.0.0.1:-1"
+	_, err = p.runProbe(req)
+	if err == nil {
+		t.Error("Expected an error when ports can't be created")
+	}
+
+	// With a fixed address, only the first port can be created, and it's
+	// cleaned up after the second fails
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.addr = conn.LocalAddr().String()
+	HandleMinorError(conn.Close())
+	req.Ports = 2
+	_, err = p.runProbe(req)
+	if err == nil {
+		t.Error("Expected an error when the second port can't be created")
+	}
+	sent := DefaultMetrics.Snapshot()["llama_port_probes_sent_total"]
+	if _, found := sent[`port="`+p.addr+`"`]; found {
+		t.Error("Expected the first port's metrics to be unregistered, got", sent)
+	}
+}
+
+func TestProbeHandler(t *testing.T) {
+	api := newTestAPI()
+	p := NewProber(1, 1)
+	fail := false
+	p.runFunc = func(req *ProbeRequest) (*ProbeResult, error) {
+		if fail {
+			return nil, errors.New("failed")
+		}
+		return &ProbeResult{Target: req.Target, Sent: 10}, nil
+	}
+	api.SetProber(p, "secret")
+	request := func(method, url, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer secret")
+		rw := httptest.NewRecorder()
+		api.ProbeHandler(rw, r)
+		return rw
+	}
+
+	rw := request("POST", "/probe", `{"target": "10.0.0.1:8100"}`)
+	if rw.Code != 200 {
+		t.Fatal("Expected 200, got", rw.Code, rw.Body.String())
+	}
+	var result ProbeResult
+	err := json.Unmarshal(rw.Body.Bytes(), &result)
+	if err != nil || result.Sent != 10 {
+		t.Error("Expected the result, got", rw.Body.String(), err)
+	}
+
+	rw = request("POST", "/probe?async=true", `{"target": "10.0.0.1:8100"}`)
+	if rw.Code != 202 {
+		t.Fatal("Expected 202, got", rw.Code)
+	}
+	var job ProbeJob
+	err = json.Unmarshal(rw.Body.Bytes(), &job)
+	if err != nil || job.ID != "2" {
+		t.Fatal("Expected the job, got", rw.Body.String(), err)
+	}
+	// Poll until it's done
+	for i := 0; i < 100 && job.Status == probeJobRunning; i++ {
+		time.Sleep(time.Millisecond)
+		rw = request("GET", "/probe?id=2", "")
+		HandleMinorError(json.Unmarshal(rw.Body.Bytes(), &job))
+	}
+	if job.Status != probeJobDone || job.Result == nil {
+		t.Error("Expected the job to be done, got", rw.Body.String())
+	}
+	// Only a single job is retained
+	if rw := request("GET", "/probe?id=1", ""); rw.Code != 404 {
+		t.Error("Expected 404 for a forgotten job, got", rw.Code)
+	}
+
+	if rw := request("POST", "/probe", `{"target": "10.0.0.1"}`); rw.Code != 400 {
+		t.Error("Expected 400 for an invalid request, got", rw.Code)
+	}
+	fail = true
+	if rw := request("POST", "/probe", `{"target": "10.0.0.1:8100"}`); rw.Code != 500 {
+		t.Error("Expected 500 for a failed probe, got", rw.Code)
+	}
+}
@@ -0,0 +1,240 @@
+// Helpers for exposing summaries and other metrics in the Prometheus text
+// exposition format.
+package llama
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PromContentType is the content type for the Prometheus text format.
+const PromContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultPromPrefix is prepended to the names of summary metrics when no
+// other prefix is provided in the config.
+const DefaultPromPrefix = "llama_"
+
+// Ways of handling tags which aren't valid Prometheus label names
+const (
+	PromLabelsReplace = "replace" // Replace invalid characters with `_`
+	PromLabelsDrop    = "drop"    // Leave the tag out entirely
+)
+
+// SanitizePromName replaces any characters which aren't valid in a Prometheus
+// metric or label name with `_`.
+func SanitizePromName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		valid := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(i > 0 && r >= '0' && r <= '9')
+		if !valid {
+			// Keep the leading digit, just not at the start
+			if i == 0 && r >= '0' && r <= '9' {
+				b.WriteRune('_')
+				b.WriteRune(r)
+				continue
+			}
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// validPromName determines if the name is already a valid label name.
+func validPromName(name string) bool {
+	return name != "" && SanitizePromName(name) == name
+}
+
+// escapePromValue escapes a label value for the text format.
+func escapePromValue(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, "\n", `\n`, -1)
+	return strings.Replace(v, `"`, `\"`, -1)
+}
+
+// formatPromFloat formats a sample value for the text format.
+func formatPromFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WritePromHeader writes the HELP and TYPE lines for a metric.
+func WritePromHeader(w io.Writer, name string, help string, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// WritePromSample writes a single sample of a metric, with labels sorted by
+// name. Labels are expected to already have valid names.
+func WritePromSample(w io.Writer, name string, labels Tags, value float64) {
+	if len(labels) == 0 {
+		fmt.Fprintf(w, "%s %s\n", name, formatPromFloat(value))
+		return
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, escapePromValue(labels[k])))
+	}
+	fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), formatPromFloat(value))
+}
+
+// promSummaryMetric describes how a single value is exposed for each summary.
+type promSummaryMetric struct {
+	name   string
+	help   string
+	scored bool // Only exposed if the summary was scored against a baseline
+	value  func(s *Summary) float64
+}
+
+// boolToFloat converts true to 1 and false to 0.
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var promSummaryMetrics = []promSummaryMetric{
+	{"loss_percent", "Percentage of probes lost in the interval.", false,
+		func(s *Summary) float64 { return s.Loss }},
+	{"sent_probes", "Number of probes sent in the interval.", false,
+		func(s *Summary) float64 { return float64(s.Sent) }},
+	{"lost_probes", "Number of probes lost in the interval.", false,
+		func(s *Summary) float64 { return float64(s.Lost) }},
+	{"rtt_avg_milliseconds", "Average round trip time of probes in the interval.", false,
+		func(s *Summary) float64 { return s.RTTAvg }},
+	{"rtt_min_milliseconds", "Minimum round trip time of probes in the interval.", false,
+		func(s *Summary) float64 { return s.RTTMin }},
+	{"rtt_max_milliseconds", "Maximum round trip time of probes in the interval.", false,
+		func(s *Summary) float64 { return s.RTTMax }},
+	{"loss_episodes", "Number of separate runs of consecutive losses in the interval.", false,
+		func(s *Summary) float64 { return float64(s.LossEpisodes) }},
+	{"loss_burst_max", "Longest run of consecutive losses in the interval.", false,
+		func(s *Summary) float64 { return float64(s.LossBurstMax) }},
+	{"loss_burst_avg", "Mean length of runs of consecutive losses in the interval.", false,
+		func(s *Summary) float64 { return s.LossBurstAvg }},
+	{"rtt_baseline_milliseconds", "Typical average round trip time for the path.", true,
+		func(s *Summary) float64 { return s.RTTBaseline }},
+	{"rtt_deviation_ratio", "Ratio of the average round trip time to the baseline.", true,
+		func(s *Summary) float64 { return s.RTTDeviation }},
+	{"rtt_anomalous", "Whether the round trip time deviation exceeded the threshold.", true,
+		func(s *Summary) float64 { return boolToFloat(s.RTTAnomalous) }},
+	{"loss_baseline_percent", "Typical percentage of probes lost for the path.", true,
+		func(s *Summary) float64 { return s.LossBaseline }},
+	{"loss_anomaly_ratio", "Ratio of loss to the baseline, with a floor applied to both.", true,
+		func(s *Summary) float64 { return s.LossAnomaly }},
+	{"loss_anomalous", "Whether the loss anomaly exceeded the threshold.", true,
+		func(s *Summary) float64 { return boolToFloat(s.LossAnomalous) }},
+}
+
+// promPathLabels identify the path of each summary, so they're always kept
+// as labels. Without them, summaries for different paths would have the same
+// labels, and the duplicate samples would fail the whole scrape.
+var promPathLabels = map[string]bool{"src_ip": true, "dst_ip": true}
+
+// PromExporter renders summaries as per-path Prometheus gauges, labeled with
+// their tags.
+type PromExporter struct {
+	prefix  string
+	allowed map[string]bool // If empty, all tags are allowed
+	invalid string          // How to handle tags that aren't valid label names
+}
+
+// Labels converts tags to labels, applying the allowlist and handling
+// invalid names.
+//
+// Tags with valid names take precedence over sanitized ones with the same
+// name, and if several tags sanitize to the same name, the first in sorted
+// order is used.
+func (e *PromExporter) Labels(t Tags) Tags {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	labels := make(Tags)
+	var invalid []string
+	for _, k := range keys {
+		if len(e.allowed) > 0 && !e.allowed[k] && !promPathLabels[k] {
+			continue
+		}
+		if !validPromName(k) {
+			invalid = append(invalid, k)
+			continue
+		}
+		labels[k] = t[k]
+	}
+	if e.invalid == PromLabelsDrop {
+		return labels
+	}
+	for _, k := range invalid {
+		name := SanitizePromName(k)
+		if _, found := labels[name]; found {
+			continue
+		}
+		labels[name] = t[k]
+	}
+	return labels
+}
+
+// WriteSummaries writes the gauges for the summaries to w. `points` must be
+// the DataPoints for the summaries, in the same order, which provide the
+// tags.
+func (e *PromExporter) WriteSummaries(w io.Writer, summaries []*Summary, points []*DataPoint) {
+	labels := make([]Tags, len(points))
+	for i, dp := range points {
+		labels[i] = e.Labels(dp.Tags)
+	}
+	for _, m := range promSummaryMetrics {
+		name := e.prefix + m.name
+		WritePromHeader(w, name, m.help, "gauge")
+		for i, s := range summaries {
+			if m.scored && !s.Scored {
+				continue
+			}
+			WritePromSample(w, name, labels[i], m.value(s))
+		}
+	}
+}
+
+// NewPromExporter creates a PromExporter based on the provided config.
+func NewPromExporter(cfg MetricsConfig) (*PromExporter, error) {
+	e := &PromExporter{
+		prefix:  cfg.Prefix,
+		allowed: make(map[string]bool),
+		invalid: cfg.InvalidLabels,
+	}
+	if e.prefix == "" {
+		e.prefix = DefaultPromPrefix
+	}
+	if !validPromName(e.prefix) {
+		return nil, fmt.Errorf("Invalid metrics prefix: %s", e.prefix)
+	}
+	if e.invalid == "" {
+		e.invalid = PromLabelsReplace
+	}
+	if e.invalid != PromLabelsReplace && e.invalid != PromLabelsDrop {
+		return nil, fmt.Errorf("Unknown handling for invalid labels: %s", e.invalid)
+	}
+	for _, label := range cfg.Labels {
+		e.allowed[label] = true
+	}
+	return e, nil
+}
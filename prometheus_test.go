@@ -0,0 +1,119 @@
+package llama
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSanitizePromName(t *testing.T) {
+	cases := map[string]string{
+		"dst_region":  "dst_region",
+		"dst-region":  "dst_region",
+		"dst.region":  "dst_region",
+		"1st_tag":     "_1st_tag",
+		"tag2":        "tag2",
+		"with spaces": "with_spaces",
+	}
+	for in, expected := range cases {
+		if out := SanitizePromName(in); out != expected {
+			t.Error("Expected", expected, "for", in, "but got", out)
+		}
+	}
+}
+
+func TestWritePromSample(t *testing.T) {
+	var buf bytes.Buffer
+	WritePromSample(&buf, "metric", Tags{"b": "two", "a": `"quoted"\`}, 1.5)
+	expected := `metric{a="\"quoted\"\\",b="two"} 1.5` + "\n"
+	if buf.String() != expected {
+		t.Error("Expected", expected, "but got", buf.String())
+	}
+	buf.Reset()
+	WritePromSample(&buf, "metric", nil, 2)
+	if buf.String() != "metric 2\n" {
+		t.Error("Unexpected sample without labels:", buf.String())
+	}
+}
+
+func TestPromExporterLabels(t *testing.T) {
+	tags := Tags{"dst_region": "west", "dst-host": "host1", "src_ip": "10.0.0.1"}
+	// Replace by default
+	e, err := NewPromExporter(MetricsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := e.Labels(tags)
+	if len(labels) != 3 || labels["dst_host"] != "host1" {
+		t.Error("Expected invalid names to be replaced, got", labels)
+	}
+	// Dropping and allowlisting
+	e, err = NewPromExporter(MetricsConfig{
+		Labels:        []string{"dst_region", "dst-host"},
+		InvalidLabels: PromLabelsDrop,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels = e.Labels(tags)
+	if len(labels) != 2 || labels["dst_region"] != "west" || labels["src_ip"] != "10.0.0.1" {
+		t.Error("Expected only dst_region and the path, got", labels)
+	}
+	// Collisions are resolved the same way every time
+	e, _ = NewPromExporter(MetricsConfig{})
+	for i := 0; i < 10; i++ {
+		labels = e.Labels(Tags{"a-b": "dash", "a.b": "dot", "c.d": "dot", "c_d": "valid"})
+		if labels["a_b"] != "dash" || labels["c_d"] != "valid" {
+			t.Fatal("Expected the first sanitized tag, and valid ones, to win, got", labels)
+		}
+	}
+	// Bad configs
+	_, err = NewPromExporter(MetricsConfig{InvalidLabels: "nope"})
+	if err == nil {
+		t.Error("Expected an error for unknown invalid label handling")
+	}
+	_, err = NewPromExporter(MetricsConfig{Prefix: "bad-prefix"})
+	if err == nil {
+		t.Error("Expected an error for an invalid prefix")
+	}
+}
+
+func TestPromExporterWriteSummaries(t *testing.T) {
+	summaries := []*Summary{
+		&Summary{
+			Pd: &PathDist{
+				SrcIP: net.ParseIP("10.0.0.1"),
+				DstIP: net.ParseIP("10.0.0.2"),
+			},
+			Sent:   10,
+			Lost:   1,
+			Loss:   10.0,
+			RTTAvg: 1.25,
+		},
+	}
+	ts := TagSet{"10.0.0.2": Tags{"dst_region": "west"}}
+	points := NewDataPointsFromSummaries(summaries, ts)
+	e, err := NewPromExporter(MetricsConfig{Prefix: "test_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	e.WriteSummaries(&buf, summaries, points)
+	out := buf.String()
+	expected := []string{
+		"# TYPE test_loss_percent gauge\n",
+		`test_loss_percent{dst_ip="10.0.0.2",dst_region="west",src_ip="10.0.0.1"} 10` + "\n",
+		`test_sent_probes{dst_ip="10.0.0.2",dst_region="west",src_ip="10.0.0.1"} 10` + "\n",
+		`test_rtt_avg_milliseconds{dst_ip="10.0.0.2",dst_region="west",src_ip="10.0.0.1"} 1.25` + "\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Error("Expected output to contain", line, "but got", out)
+		}
+	}
+	// Not scored, so no baseline samples
+	if strings.Contains(out, "test_rtt_deviation_ratio{") {
+		t.Error("Baseline metrics shouldn't be exposed unless scored")
+	}
+}
@@ -0,0 +1,3238 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: github.com/dropbox/llama/proto/collector.proto
+
+package proto
+
+import (
+	context "context"
+	encoding_binary "encoding/binary"
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	io "io"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
+
+// Summary is the summary of a single path for an interval.
+type Summary struct {
+	SrcIp         string            `protobuf:"bytes,1,opt,name=src_ip,json=srcIp,proto3" json:"src_ip,omitempty"`
+	SrcPort       int64             `protobuf:"varint,2,opt,name=src_port,json=srcPort,proto3" json:"src_port,omitempty"`
+	DstIp         string            `protobuf:"bytes,3,opt,name=dst_ip,json=dstIp,proto3" json:"dst_ip,omitempty"`
+	DstPort       int64             `protobuf:"varint,4,opt,name=dst_port,json=dstPort,proto3" json:"dst_port,omitempty"`
+	Proto         string            `protobuf:"bytes,5,opt,name=proto,proto3" json:"proto,omitempty"`
+	RttAvg        float64           `protobuf:"fixed64,6,opt,name=rtt_avg,json=rttAvg,proto3" json:"rtt_avg,omitempty"`
+	RttMin        float64           `protobuf:"fixed64,7,opt,name=rtt_min,json=rttMin,proto3" json:"rtt_min,omitempty"`
+	RttMax        float64           `protobuf:"fixed64,8,opt,name=rtt_max,json=rttMax,proto3" json:"rtt_max,omitempty"`
+	Sent          int64             `protobuf:"varint,9,opt,name=sent,proto3" json:"sent,omitempty"`
+	Lost          int64             `protobuf:"varint,10,opt,name=lost,proto3" json:"lost,omitempty"`
+	Loss          float64           `protobuf:"fixed64,11,opt,name=loss,proto3" json:"loss,omitempty"`
+	LossEpisodes  int64             `protobuf:"varint,12,opt,name=loss_episodes,json=lossEpisodes,proto3" json:"loss_episodes,omitempty"`
+	LossBurstMax  int64             `protobuf:"varint,13,opt,name=loss_burst_max,json=lossBurstMax,proto3" json:"loss_burst_max,omitempty"`
+	LossBurstAvg  float64           `protobuf:"fixed64,14,opt,name=loss_burst_avg,json=lossBurstAvg,proto3" json:"loss_burst_avg,omitempty"`
+	Scored        bool              `protobuf:"varint,15,opt,name=scored,proto3" json:"scored,omitempty"`
+	RttBaseline   float64           `protobuf:"fixed64,16,opt,name=rtt_baseline,json=rttBaseline,proto3" json:"rtt_baseline,omitempty"`
+	RttDeviation  float64           `protobuf:"fixed64,17,opt,name=rtt_deviation,json=rttDeviation,proto3" json:"rtt_deviation,omitempty"`
+	RttAnomalous  bool              `protobuf:"varint,18,opt,name=rtt_anomalous,json=rttAnomalous,proto3" json:"rtt_anomalous,omitempty"`
+	LossBaseline  float64           `protobuf:"fixed64,19,opt,name=loss_baseline,json=lossBaseline,proto3" json:"loss_baseline,omitempty"`
+	LossAnomaly   float64           `protobuf:"fixed64,20,opt,name=loss_anomaly,json=lossAnomaly,proto3" json:"loss_anomaly,omitempty"`
+	LossAnomalous bool              `protobuf:"varint,21,opt,name=loss_anomalous,json=lossAnomalous,proto3" json:"loss_anomalous,omitempty"`
+	Tags          map[string]string `protobuf:"bytes,22,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *Summary) Reset()         { *m = Summary{} }
+func (m *Summary) String() string { return proto.CompactTextString(m) }
+func (*Summary) ProtoMessage()    {}
+func (*Summary) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb5bd2d5df0b1a5a, []int{0}
+}
+func (m *Summary) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Summary) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Summary.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Summary) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Summary.Merge(m, src)
+}
+func (m *Summary) XXX_Size() int {
+	return m.Size()
+}
+func (m *Summary) XXX_DiscardUnknown() {
+	xxx_messageInfo_Summary.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Summary proto.InternalMessageInfo
+
+func (m *Summary) GetSrcIp() string {
+	if m != nil {
+		return m.SrcIp
+	}
+	return ""
+}
+
+func (m *Summary) GetSrcPort() int64 {
+	if m != nil {
+		return m.SrcPort
+	}
+	return 0
+}
+
+func (m *Summary) GetDstIp() string {
+	if m != nil {
+		return m.DstIp
+	}
+	return ""
+}
+
+func (m *Summary) GetDstPort() int64 {
+	if m != nil {
+		return m.DstPort
+	}
+	return 0
+}
+
+func (m *Summary) GetProto() string {
+	if m != nil {
+		return m.Proto
+	}
+	return ""
+}
+
+func (m *Summary) GetRttAvg() float64 {
+	if m != nil {
+		return m.RttAvg
+	}
+	return 0
+}
+
+func (m *Summary) GetRttMin() float64 {
+	if m != nil {
+		return m.RttMin
+	}
+	return 0
+}
+
+func (m *Summary) GetRttMax() float64 {
+	if m != nil {
+		return m.RttMax
+	}
+	return 0
+}
+
+func (m *Summary) GetSent() int64 {
+	if m != nil {
+		return m.Sent
+	}
+	return 0
+}
+
+func (m *Summary) GetLost() int64 {
+	if m != nil {
+		return m.Lost
+	}
+	return 0
+}
+
+func (m *Summary) GetLoss() float64 {
+	if m != nil {
+		return m.Loss
+	}
+	return 0
+}
+
+func (m *Summary) GetLossEpisodes() int64 {
+	if m != nil {
+		return m.LossEpisodes
+	}
+	return 0
+}
+
+func (m *Summary) GetLossBurstMax() int64 {
+	if m != nil {
+		return m.LossBurstMax
+	}
+	return 0
+}
+
+func (m *Summary) GetLossBurstAvg() float64 {
+	if m != nil {
+		return m.LossBurstAvg
+	}
+	return 0
+}
+
+func (m *Summary) GetScored() bool {
+	if m != nil {
+		return m.Scored
+	}
+	return false
+}
+
+func (m *Summary) GetRttBaseline() float64 {
+	if m != nil {
+		return m.RttBaseline
+	}
+	return 0
+}
+
+func (m *Summary) GetRttDeviation() float64 {
+	if m != nil {
+		return m.RttDeviation
+	}
+	return 0
+}
+
+func (m *Summary) GetRttAnomalous() bool {
+	if m != nil {
+		return m.RttAnomalous
+	}
+	return false
+}
+
+func (m *Summary) GetLossBaseline() float64 {
+	if m != nil {
+		return m.LossBaseline
+	}
+	return 0
+}
+
+func (m *Summary) GetLossAnomaly() float64 {
+	if m != nil {
+		return m.LossAnomaly
+	}
+	return 0
+}
+
+func (m *Summary) GetLossAnomalous() bool {
+	if m != nil {
+		return m.LossAnomalous
+	}
+	return false
+}
+
+func (m *Summary) GetTags() map[string]string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+type SummariesRequest struct {
+	IntervalId int64 `protobuf:"varint,1,opt,name=interval_id,json=intervalId,proto3" json:"interval_id,omitempty"`
+}
+
+func (m *SummariesRequest) Reset()         { *m = SummariesRequest{} }
+func (m *SummariesRequest) String() string { return proto.CompactTextString(m) }
+func (*SummariesRequest) ProtoMessage()    {}
+func (*SummariesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb5bd2d5df0b1a5a, []int{1}
+}
+func (m *SummariesRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SummariesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SummariesRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SummariesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SummariesRequest.Merge(m, src)
+}
+func (m *SummariesRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *SummariesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SummariesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SummariesRequest proto.InternalMessageInfo
+
+func (m *SummariesRequest) GetIntervalId() int64 {
+	if m != nil {
+		return m.IntervalId
+	}
+	return 0
+}
+
+type StreamSummariesRequest struct {
+}
+
+func (m *StreamSummariesRequest) Reset()         { *m = StreamSummariesRequest{} }
+func (m *StreamSummariesRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamSummariesRequest) ProtoMessage()    {}
+func (*StreamSummariesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb5bd2d5df0b1a5a, []int{2}
+}
+func (m *StreamSummariesRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamSummariesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamSummariesRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StreamSummariesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamSummariesRequest.Merge(m, src)
+}
+func (m *StreamSummariesRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamSummariesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamSummariesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamSummariesRequest proto.InternalMessageInfo
+
+// Summaries are the summaries of every path for an interval.
+type Summaries struct {
+	IntervalId int64      `protobuf:"varint,1,opt,name=interval_id,json=intervalId,proto3" json:"interval_id,omitempty"`
+	Start      int64      `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	End        int64      `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	Summaries  []*Summary `protobuf:"bytes,4,rep,name=summaries,proto3" json:"summaries,omitempty"`
+}
+
+func (m *Summaries) Reset()         { *m = Summaries{} }
+func (m *Summaries) String() string { return proto.CompactTextString(m) }
+func (*Summaries) ProtoMessage()    {}
+func (*Summaries) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb5bd2d5df0b1a5a, []int{3}
+}
+func (m *Summaries) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Summaries) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Summaries.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Summaries) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Summaries.Merge(m, src)
+}
+func (m *Summaries) XXX_Size() int {
+	return m.Size()
+}
+func (m *Summaries) XXX_DiscardUnknown() {
+	xxx_messageInfo_Summaries.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Summaries proto.InternalMessageInfo
+
+func (m *Summaries) GetIntervalId() int64 {
+	if m != nil {
+		return m.IntervalId
+	}
+	return 0
+}
+
+func (m *Summaries) GetStart() int64 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
+}
+
+func (m *Summaries) GetEnd() int64 {
+	if m != nil {
+		return m.End
+	}
+	return 0
+}
+
+func (m *Summaries) GetSummaries() []*Summary {
+	if m != nil {
+		return m.Summaries
+	}
+	return nil
+}
+
+type StatusRequest struct {
+}
+
+func (m *StatusRequest) Reset()         { *m = StatusRequest{} }
+func (m *StatusRequest) String() string { return proto.CompactTextString(m) }
+func (*StatusRequest) ProtoMessage()    {}
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb5bd2d5df0b1a5a, []int{4}
+}
+func (m *StatusRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StatusRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StatusRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StatusRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatusRequest.Merge(m, src)
+}
+func (m *StatusRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *StatusRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatusRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatusRequest proto.InternalMessageInfo
+
+// Status describes the health of the collector.
+type Status struct {
+	Status           string `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	LatestIntervalId int64  `protobuf:"varint,2,opt,name=latest_interval_id,json=latestIntervalId,proto3" json:"latest_interval_id,omitempty"`
+	Intervals        int64  `protobuf:"varint,3,opt,name=intervals,proto3" json:"intervals,omitempty"`
+	Paths            int64  `protobuf:"varint,4,opt,name=paths,proto3" json:"paths,omitempty"`
+}
+
+func (m *Status) Reset()         { *m = Status{} }
+func (m *Status) String() string { return proto.CompactTextString(m) }
+func (*Status) ProtoMessage()    {}
+func (*Status) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb5bd2d5df0b1a5a, []int{5}
+}
+func (m *Status) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Status) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Status.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Status) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Status.Merge(m, src)
+}
+func (m *Status) XXX_Size() int {
+	return m.Size()
+}
+func (m *Status) XXX_DiscardUnknown() {
+	xxx_messageInfo_Status.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Status proto.InternalMessageInfo
+
+func (m *Status) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *Status) GetLatestIntervalId() int64 {
+	if m != nil {
+		return m.LatestIntervalId
+	}
+	return 0
+}
+
+func (m *Status) GetIntervals() int64 {
+	if m != nil {
+		return m.Intervals
+	}
+	return 0
+}
+
+func (m *Status) GetPaths() int64 {
+	if m != nil {
+		return m.Paths
+	}
+	return 0
+}
+
+// Target is a reflector that a test sends probes to.
+type Target struct {
+	Ip   string            `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	Port int64             `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	Tags map[string]string `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *Target) Reset()         { *m = Target{} }
+func (m *Target) String() string { return proto.CompactTextString(m) }
+func (*Target) ProtoMessage()    {}
+func (*Target) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb5bd2d5df0b1a5a, []int{6}
+}
+func (m *Target) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Target) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Target.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Target) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Target.Merge(m, src)
+}
+func (m *Target) XXX_Size() int {
+	return m.Size()
+}
+func (m *Target) XXX_DiscardUnknown() {
+	xxx_messageInfo_Target.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Target proto.InternalMessageInfo
+
+func (m *Target) GetIp() string {
+	if m != nil {
+		return m.Ip
+	}
+	return ""
+}
+
+func (m *Target) GetPort() int64 {
+	if m != nil {
+		return m.Port
+	}
+	return 0
+}
+
+func (m *Target) GetTags() map[string]string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+type TargetsRequest struct {
+	Test    string    `protobuf:"bytes,1,opt,name=test,proto3" json:"test,omitempty"`
+	Targets []*Target `protobuf:"bytes,2,rep,name=targets,proto3" json:"targets,omitempty"`
+}
+
+func (m *TargetsRequest) Reset()         { *m = TargetsRequest{} }
+func (m *TargetsRequest) String() string { return proto.CompactTextString(m) }
+func (*TargetsRequest) ProtoMessage()    {}
+func (*TargetsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb5bd2d5df0b1a5a, []int{7}
+}
+func (m *TargetsRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TargetsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TargetsRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TargetsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TargetsRequest.Merge(m, src)
+}
+func (m *TargetsRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *TargetsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TargetsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TargetsRequest proto.InternalMessageInfo
+
+func (m *TargetsRequest) GetTest() string {
+	if m != nil {
+		return m.Test
+	}
+	return ""
+}
+
+func (m *TargetsRequest) GetTargets() []*Target {
+	if m != nil {
+		return m.Targets
+	}
+	return nil
+}
+
+type Targets struct {
+	Targets []*Target `protobuf:"bytes,1,rep,name=targets,proto3" json:"targets,omitempty"`
+}
+
+func (m *Targets) Reset()         { *m = Targets{} }
+func (m *Targets) String() string { return proto.CompactTextString(m) }
+func (*Targets) ProtoMessage()    {}
+func (*Targets) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bb5bd2d5df0b1a5a, []int{8}
+}
+func (m *Targets) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Targets) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Targets.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Targets) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Targets.Merge(m, src)
+}
+func (m *Targets) XXX_Size() int {
+	return m.Size()
+}
+func (m *Targets) XXX_DiscardUnknown() {
+	xxx_messageInfo_Targets.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Targets proto.InternalMessageInfo
+
+func (m *Targets) GetTargets() []*Target {
+	if m != nil {
+		return m.Targets
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Summary)(nil), "llama.Summary")
+	proto.RegisterMapType((map[string]string)(nil), "llama.Summary.TagsEntry")
+	proto.RegisterType((*SummariesRequest)(nil), "llama.SummariesRequest")
+	proto.RegisterType((*StreamSummariesRequest)(nil), "llama.StreamSummariesRequest")
+	proto.RegisterType((*Summaries)(nil), "llama.Summaries")
+	proto.RegisterType((*StatusRequest)(nil), "llama.StatusRequest")
+	proto.RegisterType((*Status)(nil), "llama.Status")
+	proto.RegisterType((*Target)(nil), "llama.Target")
+	proto.RegisterMapType((map[string]string)(nil), "llama.Target.TagsEntry")
+	proto.RegisterType((*TargetsRequest)(nil), "llama.TargetsRequest")
+	proto.RegisterType((*Targets)(nil), "llama.Targets")
+}
+
+func init() {
+	proto.RegisterFile("github.com/dropbox/llama/proto/collector.proto", fileDescriptor_bb5bd2d5df0b1a5a)
+}
+
+var fileDescriptor_bb5bd2d5df0b1a5a = []byte{
+	// 800 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x55, 0x4b, 0x6b, 0x1b, 0x49,
+	0x10, 0xf6, 0xe8, 0xe9, 0x29, 0x3d, 0xac, 0xed, 0xf5, 0xa3, 0xd7, 0xec, 0xca, 0xb2, 0x76, 0x97,
+	0x15, 0xac, 0x91, 0x8c, 0xbd, 0xb0, 0x49, 0x6e, 0x76, 0x6c, 0x82, 0x21, 0x86, 0x30, 0xf6, 0x29,
+	0x17, 0xd1, 0xd2, 0x34, 0xf2, 0x90, 0xd1, 0x8c, 0xd2, 0x5d, 0x12, 0xd6, 0x35, 0xbf, 0x20, 0xe4,
+	0x57, 0xe5, 0xe8, 0x53, 0xc8, 0x29, 0x04, 0xfb, 0x8f, 0x84, 0x7e, 0xcc, 0x8c, 0xe4, 0x84, 0x04,
+	0x43, 0x4e, 0xaa, 0xfe, 0xea, 0xfb, 0xaa, 0xbe, 0x9a, 0xa9, 0x1e, 0x41, 0x77, 0x14, 0xe0, 0xd5,
+	0x74, 0xd0, 0x1d, 0xc6, 0xe3, 0x9e, 0x2f, 0xe2, 0xc9, 0x20, 0xbe, 0xee, 0x85, 0x21, 0x1b, 0xb3,
+	0xde, 0x44, 0xc4, 0x18, 0xf7, 0x86, 0x71, 0x18, 0xf2, 0x21, 0xc6, 0xa2, 0xab, 0xcf, 0xa4, 0xa8,
+	0x93, 0xed, 0x0f, 0x45, 0x28, 0x5f, 0x4c, 0xc7, 0x63, 0x26, 0xe6, 0x64, 0x03, 0x4a, 0x52, 0x0c,
+	0xfb, 0xc1, 0x84, 0x3a, 0x2d, 0xa7, 0xe3, 0x7a, 0x45, 0x29, 0x86, 0x67, 0x13, 0xf2, 0x1b, 0xac,
+	0x2a, 0x78, 0x12, 0x0b, 0xa4, 0xb9, 0x96, 0xd3, 0xc9, 0x7b, 0x65, 0x29, 0x86, 0x2f, 0x62, 0x81,
+	0x4a, 0xe1, 0x4b, 0x54, 0x8a, 0xbc, 0x51, 0xf8, 0x12, 0x8d, 0x42, 0xc1, 0x5a, 0x51, 0x30, 0x0a,
+	0x5f, 0xa2, 0x56, 0xac, 0x43, 0x51, 0xf7, 0xa7, 0x45, 0x23, 0x30, 0x66, 0xb6, 0xa0, 0x2c, 0x10,
+	0xfb, 0x6c, 0x36, 0xa2, 0xa5, 0x96, 0xd3, 0x71, 0xbc, 0x92, 0x40, 0x3c, 0x9a, 0x8d, 0x92, 0xc4,
+	0x38, 0x88, 0x68, 0x39, 0x4d, 0x9c, 0x07, 0x51, 0x9a, 0x60, 0xd7, 0x74, 0x35, 0x4b, 0xb0, 0x6b,
+	0x42, 0xa0, 0x20, 0x79, 0x84, 0xd4, 0xd5, 0x7d, 0x75, 0xac, 0xb0, 0x30, 0x96, 0x48, 0xc1, 0x60,
+	0x2a, 0xb6, 0x98, 0xa4, 0x15, 0xad, 0xd6, 0x31, 0xf9, 0x13, 0x6a, 0xea, 0xb7, 0xcf, 0x27, 0x81,
+	0x8c, 0x7d, 0x2e, 0x69, 0x55, 0x0b, 0xaa, 0x0a, 0x3c, 0xb5, 0x18, 0xf9, 0x0b, 0xea, 0x9a, 0x34,
+	0x98, 0x0a, 0x69, 0x0c, 0xd4, 0x32, 0xd6, 0xb1, 0x02, 0x95, 0x8d, 0x65, 0x96, 0x1a, 0xac, 0xae,
+	0x1b, 0x65, 0x2c, 0x35, 0xde, 0x26, 0x94, 0xe4, 0x30, 0x16, 0xdc, 0xa7, 0x6b, 0x2d, 0xa7, 0xb3,
+	0xea, 0xd9, 0x13, 0xd9, 0x85, 0xaa, 0x9a, 0x6e, 0xc0, 0x24, 0x0f, 0x83, 0x88, 0xd3, 0x86, 0xd6,
+	0x56, 0x04, 0xe2, 0xb1, 0x85, 0x94, 0x57, 0x45, 0xf1, 0xf9, 0x2c, 0x60, 0x18, 0xc4, 0x11, 0xfd,
+	0xc5, 0xd4, 0x17, 0x88, 0x27, 0x09, 0x96, 0x90, 0x58, 0x14, 0x8f, 0x59, 0x18, 0x4f, 0x25, 0x25,
+	0xba, 0x8d, 0x22, 0x1d, 0x25, 0x58, 0x3a, 0x75, 0xda, 0xed, 0xd7, 0x05, 0xa7, 0x49, 0xbb, 0x5d,
+	0xd0, 0x67, 0x5b, 0x6a, 0x4e, 0xd7, 0x8d, 0x23, 0x85, 0x99, 0x4a, 0x73, 0xf2, 0x37, 0xd4, 0x17,
+	0x28, 0xaa, 0xdb, 0x86, 0xee, 0x56, 0xcb, 0x48, 0xaa, 0xdd, 0x1e, 0x14, 0x90, 0x8d, 0x24, 0xdd,
+	0x6c, 0xe5, 0x3b, 0x95, 0x03, 0xda, 0xd5, 0x7b, 0xd8, 0xb5, 0x3b, 0xd8, 0xbd, 0x64, 0x23, 0x79,
+	0x1a, 0xa1, 0x98, 0x7b, 0x9a, 0xb5, 0xfd, 0x3f, 0xb8, 0x29, 0x44, 0x1a, 0x90, 0x7f, 0xc5, 0xe7,
+	0x76, 0x3b, 0x55, 0xa8, 0xd6, 0x69, 0xc6, 0xc2, 0x29, 0xd7, 0x8b, 0xe9, 0x7a, 0xe6, 0xf0, 0x24,
+	0xf7, 0xc8, 0x69, 0x1f, 0x42, 0xc3, 0xd4, 0x0c, 0xb8, 0xf4, 0xf8, 0xeb, 0x29, 0x97, 0x48, 0x76,
+	0xa0, 0x12, 0x44, 0xc8, 0xc5, 0x8c, 0x85, 0xfd, 0xc0, 0xd7, 0x75, 0xf2, 0x1e, 0x24, 0xd0, 0x99,
+	0xdf, 0xa6, 0xb0, 0x79, 0x81, 0x82, 0xb3, 0xf1, 0x7d, 0x69, 0xfb, 0x8d, 0x03, 0x6e, 0x0a, 0xfe,
+	0xb0, 0x90, 0xf2, 0x25, 0x91, 0xa5, 0x17, 0xc6, 0x1c, 0x94, 0x7f, 0x1e, 0xf9, 0xfa, 0xae, 0xe4,
+	0x3d, 0x15, 0x92, 0x3d, 0x70, 0x65, 0x52, 0x95, 0x16, 0xf4, 0x13, 0xa9, 0x2f, 0x3f, 0x11, 0x2f,
+	0x23, 0xb4, 0xd7, 0xa0, 0x76, 0x81, 0x0c, 0xa7, 0x8b, 0xae, 0x4a, 0x06, 0xd1, 0xab, 0xa4, 0x23,
+	0xfb, 0x78, 0xec, 0x89, 0xec, 0x01, 0x09, 0x19, 0x72, 0x75, 0x4b, 0x17, 0x1c, 0x1b, 0x5b, 0x0d,
+	0x93, 0x39, 0xcb, 0x7c, 0xff, 0x0e, 0x6e, 0x42, 0x93, 0xd6, 0x67, 0x06, 0xe8, 0xcb, 0xcb, 0xf0,
+	0x4a, 0xda, 0x4b, 0x6d, 0x0e, 0xed, 0x77, 0x0e, 0x94, 0x2e, 0x99, 0x18, 0x71, 0x24, 0x75, 0xc8,
+	0xa5, 0x5f, 0x8f, 0x5c, 0x30, 0x51, 0x97, 0x6c, 0xe1, 0xb3, 0xa1, 0x63, 0xf2, 0xaf, 0x7d, 0xff,
+	0x79, 0x3d, 0xed, 0x96, 0x9d, 0xd6, 0x14, 0xf8, 0x79, 0xaf, 0xff, 0x1c, 0xea, 0xa6, 0x64, 0xfa,
+	0xf2, 0x09, 0x14, 0xd4, 0xb0, 0x56, 0xae, 0x63, 0xf2, 0x0f, 0x94, 0xd1, 0xb0, 0x68, 0x4e, 0xdb,
+	0xa9, 0x2d, 0xd9, 0xf1, 0x92, 0x6c, 0xfb, 0x00, 0xca, 0xb6, 0xdc, 0xa2, 0xc6, 0xf9, 0x9e, 0xe6,
+	0xe0, 0x53, 0x0e, 0xdc, 0xa7, 0xc9, 0x57, 0x97, 0x3c, 0x86, 0xea, 0x33, 0x8e, 0xd9, 0x0a, 0x6d,
+	0x2d, 0xbd, 0xe6, 0x6c, 0xd3, 0xb6, 0x1b, 0xf7, 0x13, 0xe4, 0x04, 0xd6, 0xee, 0x6d, 0x25, 0xf9,
+	0x23, 0x21, 0x7d, 0x73, 0x5b, 0xbf, 0xae, 0xb1, 0xef, 0x90, 0x7d, 0x70, 0x95, 0x01, 0xb3, 0x15,
+	0xeb, 0xa9, 0x7e, 0x61, 0x9d, 0xb6, 0x6b, 0x4b, 0x28, 0xf9, 0x0f, 0x2a, 0xcf, 0x03, 0x89, 0xc9,
+	0xe0, 0x1b, 0x4b, 0x73, 0xa6, 0xa2, 0xfa, 0x32, 0x4c, 0x0e, 0x01, 0x8e, 0x7c, 0xff, 0xe1, 0xa2,
+	0x13, 0x1e, 0x3e, 0x4c, 0x74, 0xbc, 0xf3, 0xfe, 0xb6, 0xe9, 0xdc, 0xdc, 0x36, 0x9d, 0xcf, 0xb7,
+	0x4d, 0xe7, 0xed, 0x5d, 0x73, 0xe5, 0xe6, 0xae, 0xb9, 0xf2, 0xf1, 0xae, 0xb9, 0xf2, 0xd2, 0xfc,
+	0xad, 0x0c, 0x4a, 0xfa, 0xe7, 0xf0, 0xcb, 0x00, 0x52, 0x77, 0xbe, 0x31, 0x1c, 0x07, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// CollectorClient is the client API for Collector service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type CollectorClient interface {
+	// GetSummaries provides the summaries for a retained interval, or the
+	// latest if no interval is provided.
+	GetSummaries(ctx context.Context, in *SummariesRequest, opts ...grpc.CallOption) (*Summaries, error)
+	// StreamSummaries provides the summaries for each interval as soon as
+	// it's summarized.
+	StreamSummaries(ctx context.Context, in *StreamSummariesRequest, opts ...grpc.CallOption) (Collector_StreamSummariesClient, error)
+	// GetStatus provides the health of the collector.
+	GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*Status, error)
+	// ListTargets provides the targets of a test.
+	ListTargets(ctx context.Context, in *TargetsRequest, opts ...grpc.CallOption) (*Targets, error)
+	// AddTargets adds targets to a test, or updates the tags of existing ones.
+	AddTargets(ctx context.Context, in *TargetsRequest, opts ...grpc.CallOption) (*Targets, error)
+	// DelTargets removes targets from a test, by IP and port.
+	DelTargets(ctx context.Context, in *TargetsRequest, opts ...grpc.CallOption) (*Targets, error)
+}
+
+type collectorClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewCollectorClient(cc *grpc.ClientConn) CollectorClient {
+	return &collectorClient{cc}
+}
+
+func (c *collectorClient) GetSummaries(ctx context.Context, in *SummariesRequest, opts ...grpc.CallOption) (*Summaries, error) {
+	out := new(Summaries)
+	err := c.cc.Invoke(ctx, "/llama.Collector/GetSummaries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collectorClient) StreamSummaries(ctx context.Context, in *StreamSummariesRequest, opts ...grpc.CallOption) (Collector_StreamSummariesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Collector_serviceDesc.Streams[0], "/llama.Collector/StreamSummaries", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &collectorStreamSummariesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Collector_StreamSummariesClient interface {
+	Recv() (*Summaries, error)
+	grpc.ClientStream
+}
+
+type collectorStreamSummariesClient struct {
+	grpc.ClientStream
+}
+
+func (x *collectorStreamSummariesClient) Recv() (*Summaries, error) {
+	m := new(Summaries)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *collectorClient) GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*Status, error) {
+	out := new(Status)
+	err := c.cc.Invoke(ctx, "/llama.Collector/GetStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collectorClient) ListTargets(ctx context.Context, in *TargetsRequest, opts ...grpc.CallOption) (*Targets, error) {
+	out := new(Targets)
+	err := c.cc.Invoke(ctx, "/llama.Collector/ListTargets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collectorClient) AddTargets(ctx context.Context, in *TargetsRequest, opts ...grpc.CallOption) (*Targets, error) {
+	out := new(Targets)
+	err := c.cc.Invoke(ctx, "/llama.Collector/AddTargets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collectorClient) DelTargets(ctx context.Context, in *TargetsRequest, opts ...grpc.CallOption) (*Targets, error) {
+	out := new(Targets)
+	err := c.cc.Invoke(ctx, "/llama.Collector/DelTargets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CollectorServer is the server API for Collector service.
+type CollectorServer interface {
+	// GetSummaries provides the summaries for a retained interval, or the
+	// latest if no interval is provided.
+	GetSummaries(context.Context, *SummariesRequest) (*Summaries, error)
+	// StreamSummaries provides the summaries for each interval as soon as
+	// it's summarized.
+	StreamSummaries(*StreamSummariesRequest, Collector_StreamSummariesServer) error
+	// GetStatus provides the health of the collector.
+	GetStatus(context.Context, *StatusRequest) (*Status, error)
+	// ListTargets provides the targets of a test.
+	ListTargets(context.Context, *TargetsRequest) (*Targets, error)
+	// AddTargets adds targets to a test, or updates the tags of existing ones.
+	AddTargets(context.Context, *TargetsRequest) (*Targets, error)
+	// DelTargets removes targets from a test, by IP and port.
+	DelTargets(context.Context, *TargetsRequest) (*Targets, error)
+}
+
+func RegisterCollectorServer(s *grpc.Server, srv CollectorServer) {
+	s.RegisterService(&_Collector_serviceDesc, srv)
+}
+
+func _Collector_GetSummaries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SummariesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollectorServer).GetSummaries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/llama.Collector/GetSummaries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollectorServer).GetSummaries(ctx, req.(*SummariesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Collector_StreamSummaries_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamSummariesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CollectorServer).StreamSummaries(m, &collectorStreamSummariesServer{stream})
+}
+
+type Collector_StreamSummariesServer interface {
+	Send(*Summaries) error
+	grpc.ServerStream
+}
+
+type collectorStreamSummariesServer struct {
+	grpc.ServerStream
+}
+
+func (x *collectorStreamSummariesServer) Send(m *Summaries) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Collector_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollectorServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/llama.Collector/GetStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollectorServer).GetStatus(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Collector_ListTargets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TargetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollectorServer).ListTargets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/llama.Collector/ListTargets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollectorServer).ListTargets(ctx, req.(*TargetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Collector_AddTargets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TargetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollectorServer).AddTargets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/llama.Collector/AddTargets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollectorServer).AddTargets(ctx, req.(*TargetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Collector_DelTargets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TargetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollectorServer).DelTargets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/llama.Collector/DelTargets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollectorServer).DelTargets(ctx, req.(*TargetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Collector_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "llama.Collector",
+	HandlerType: (*CollectorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSummaries",
+			Handler:    _Collector_GetSummaries_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Collector_GetStatus_Handler,
+		},
+		{
+			MethodName: "ListTargets",
+			Handler:    _Collector_ListTargets_Handler,
+		},
+		{
+			MethodName: "AddTargets",
+			Handler:    _Collector_AddTargets_Handler,
+		},
+		{
+			MethodName: "DelTargets",
+			Handler:    _Collector_DelTargets_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamSummaries",
+			Handler:       _Collector_StreamSummaries_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "github.com/dropbox/llama/proto/collector.proto",
+}
+
+func (m *Summary) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Summary) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.SrcIp) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(len(m.SrcIp)))
+		i += copy(dAtA[i:], m.SrcIp)
+	}
+	if m.SrcPort != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.SrcPort))
+	}
+	if len(m.DstIp) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(len(m.DstIp)))
+		i += copy(dAtA[i:], m.DstIp)
+	}
+	if m.DstPort != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.DstPort))
+	}
+	if len(m.Proto) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(len(m.Proto)))
+		i += copy(dAtA[i:], m.Proto)
+	}
+	if m.RttAvg != 0 {
+		dAtA[i] = 0x31
+		i++
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.RttAvg))))
+		i += 8
+	}
+	if m.RttMin != 0 {
+		dAtA[i] = 0x39
+		i++
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.RttMin))))
+		i += 8
+	}
+	if m.RttMax != 0 {
+		dAtA[i] = 0x41
+		i++
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.RttMax))))
+		i += 8
+	}
+	if m.Sent != 0 {
+		dAtA[i] = 0x48
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.Sent))
+	}
+	if m.Lost != 0 {
+		dAtA[i] = 0x50
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.Lost))
+	}
+	if m.Loss != 0 {
+		dAtA[i] = 0x59
+		i++
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.Loss))))
+		i += 8
+	}
+	if m.LossEpisodes != 0 {
+		dAtA[i] = 0x60
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.LossEpisodes))
+	}
+	if m.LossBurstMax != 0 {
+		dAtA[i] = 0x68
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.LossBurstMax))
+	}
+	if m.LossBurstAvg != 0 {
+		dAtA[i] = 0x71
+		i++
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.LossBurstAvg))))
+		i += 8
+	}
+	if m.Scored {
+		dAtA[i] = 0x78
+		i++
+		if m.Scored {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.RttBaseline != 0 {
+		dAtA[i] = 0x81
+		i++
+		dAtA[i] = 0x1
+		i++
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.RttBaseline))))
+		i += 8
+	}
+	if m.RttDeviation != 0 {
+		dAtA[i] = 0x89
+		i++
+		dAtA[i] = 0x1
+		i++
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.RttDeviation))))
+		i += 8
+	}
+	if m.RttAnomalous {
+		dAtA[i] = 0x90
+		i++
+		dAtA[i] = 0x1
+		i++
+		if m.RttAnomalous {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.LossBaseline != 0 {
+		dAtA[i] = 0x99
+		i++
+		dAtA[i] = 0x1
+		i++
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.LossBaseline))))
+		i += 8
+	}
+	if m.LossAnomaly != 0 {
+		dAtA[i] = 0xa1
+		i++
+		dAtA[i] = 0x1
+		i++
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.LossAnomaly))))
+		i += 8
+	}
+	if m.LossAnomalous {
+		dAtA[i] = 0xa8
+		i++
+		dAtA[i] = 0x1
+		i++
+		if m.LossAnomalous {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.Tags) > 0 {
+		for k, _ := range m.Tags {
+			dAtA[i] = 0xb2
+			i++
+			dAtA[i] = 0x1
+			i++
+			v := m.Tags[k]
+			mapSize := 1 + len(k) + sovCollector(uint64(len(k))) + 1 + len(v) + sovCollector(uint64(len(v)))
+			i = encodeVarintCollector(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintCollector(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintCollector(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
+	return i, nil
+}
+
+func (m *SummariesRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SummariesRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.IntervalId != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.IntervalId))
+	}
+	return i, nil
+}
+
+func (m *StreamSummariesRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamSummariesRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	return i, nil
+}
+
+func (m *Summaries) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Summaries) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.IntervalId != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.IntervalId))
+	}
+	if m.Start != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.Start))
+	}
+	if m.End != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.End))
+	}
+	if len(m.Summaries) > 0 {
+		for _, msg := range m.Summaries {
+			dAtA[i] = 0x22
+			i++
+			i = encodeVarintCollector(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *StatusRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StatusRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	return i, nil
+}
+
+func (m *Status) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Status) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Status) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(len(m.Status)))
+		i += copy(dAtA[i:], m.Status)
+	}
+	if m.LatestIntervalId != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.LatestIntervalId))
+	}
+	if m.Intervals != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.Intervals))
+	}
+	if m.Paths != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.Paths))
+	}
+	return i, nil
+}
+
+func (m *Target) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Target) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Ip) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(len(m.Ip)))
+		i += copy(dAtA[i:], m.Ip)
+	}
+	if m.Port != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(m.Port))
+	}
+	if len(m.Tags) > 0 {
+		for k, _ := range m.Tags {
+			dAtA[i] = 0x1a
+			i++
+			v := m.Tags[k]
+			mapSize := 1 + len(k) + sovCollector(uint64(len(k))) + 1 + len(v) + sovCollector(uint64(len(v)))
+			i = encodeVarintCollector(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintCollector(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintCollector(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
+	return i, nil
+}
+
+func (m *TargetsRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TargetsRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Test) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintCollector(dAtA, i, uint64(len(m.Test)))
+		i += copy(dAtA[i:], m.Test)
+	}
+	if len(m.Targets) > 0 {
+		for _, msg := range m.Targets {
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintCollector(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *Targets) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Targets) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Targets) > 0 {
+		for _, msg := range m.Targets {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintCollector(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func encodeVarintCollector(dAtA []byte, offset int, v uint64) int {
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return offset + 1
+}
+func (m *Summary) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.SrcIp)
+	if l > 0 {
+		n += 1 + l + sovCollector(uint64(l))
+	}
+	if m.SrcPort != 0 {
+		n += 1 + sovCollector(uint64(m.SrcPort))
+	}
+	l = len(m.DstIp)
+	if l > 0 {
+		n += 1 + l + sovCollector(uint64(l))
+	}
+	if m.DstPort != 0 {
+		n += 1 + sovCollector(uint64(m.DstPort))
+	}
+	l = len(m.Proto)
+	if l > 0 {
+		n += 1 + l + sovCollector(uint64(l))
+	}
+	if m.RttAvg != 0 {
+		n += 9
+	}
+	if m.RttMin != 0 {
+		n += 9
+	}
+	if m.RttMax != 0 {
+		n += 9
+	}
+	if m.Sent != 0 {
+		n += 1 + sovCollector(uint64(m.Sent))
+	}
+	if m.Lost != 0 {
+		n += 1 + sovCollector(uint64(m.Lost))
+	}
+	if m.Loss != 0 {
+		n += 9
+	}
+	if m.LossEpisodes != 0 {
+		n += 1 + sovCollector(uint64(m.LossEpisodes))
+	}
+	if m.LossBurstMax != 0 {
+		n += 1 + sovCollector(uint64(m.LossBurstMax))
+	}
+	if m.LossBurstAvg != 0 {
+		n += 9
+	}
+	if m.Scored {
+		n += 2
+	}
+	if m.RttBaseline != 0 {
+		n += 10
+	}
+	if m.RttDeviation != 0 {
+		n += 10
+	}
+	if m.RttAnomalous {
+		n += 3
+	}
+	if m.LossBaseline != 0 {
+		n += 10
+	}
+	if m.LossAnomaly != 0 {
+		n += 10
+	}
+	if m.LossAnomalous {
+		n += 3
+	}
+	if len(m.Tags) > 0 {
+		for k, v := range m.Tags {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovCollector(uint64(len(k))) + 1 + len(v) + sovCollector(uint64(len(v)))
+			n += mapEntrySize + 2 + sovCollector(uint64(mapEntrySize))
+		}
+	}
+	return n
+}
+
+func (m *SummariesRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.IntervalId != 0 {
+		n += 1 + sovCollector(uint64(m.IntervalId))
+	}
+	return n
+}
+
+func (m *StreamSummariesRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *Summaries) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.IntervalId != 0 {
+		n += 1 + sovCollector(uint64(m.IntervalId))
+	}
+	if m.Start != 0 {
+		n += 1 + sovCollector(uint64(m.Start))
+	}
+	if m.End != 0 {
+		n += 1 + sovCollector(uint64(m.End))
+	}
+	if len(m.Summaries) > 0 {
+		for _, e := range m.Summaries {
+			l = e.Size()
+			n += 1 + l + sovCollector(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *StatusRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *Status) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Status)
+	if l > 0 {
+		n += 1 + l + sovCollector(uint64(l))
+	}
+	if m.LatestIntervalId != 0 {
+		n += 1 + sovCollector(uint64(m.LatestIntervalId))
+	}
+	if m.Intervals != 0 {
+		n += 1 + sovCollector(uint64(m.Intervals))
+	}
+	if m.Paths != 0 {
+		n += 1 + sovCollector(uint64(m.Paths))
+	}
+	return n
+}
+
+func (m *Target) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Ip)
+	if l > 0 {
+		n += 1 + l + sovCollector(uint64(l))
+	}
+	if m.Port != 0 {
+		n += 1 + sovCollector(uint64(m.Port))
+	}
+	if len(m.Tags) > 0 {
+		for k, v := range m.Tags {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovCollector(uint64(len(k))) + 1 + len(v) + sovCollector(uint64(len(v)))
+			n += mapEntrySize + 1 + sovCollector(uint64(mapEntrySize))
+		}
+	}
+	return n
+}
+
+func (m *TargetsRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Test)
+	if l > 0 {
+		n += 1 + l + sovCollector(uint64(l))
+	}
+	if len(m.Targets) > 0 {
+		for _, e := range m.Targets {
+			l = e.Size()
+			n += 1 + l + sovCollector(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *Targets) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Targets) > 0 {
+		for _, e := range m.Targets {
+			l = e.Size()
+			n += 1 + l + sovCollector(uint64(l))
+		}
+	}
+	return n
+}
+
+func sovCollector(x uint64) (n int) {
+	for {
+		n++
+		x >>= 7
+		if x == 0 {
+			break
+		}
+	}
+	return n
+}
+func sozCollector(x uint64) (n int) {
+	return sovCollector(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *Summary) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCollector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Summary: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Summary: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SrcIp", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SrcIp = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SrcPort", wireType)
+			}
+			m.SrcPort = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SrcPort |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DstIp", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DstIp = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DstPort", wireType)
+			}
+			m.DstPort = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.DstPort |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Proto", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Proto = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RttAvg", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.RttAvg = float64(math.Float64frombits(v))
+		case 7:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RttMin", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.RttMin = float64(math.Float64frombits(v))
+		case 8:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RttMax", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.RttMax = float64(math.Float64frombits(v))
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Sent", wireType)
+			}
+			m.Sent = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Sent |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Lost", wireType)
+			}
+			m.Lost = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Lost |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 11:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Loss", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.Loss = float64(math.Float64frombits(v))
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LossEpisodes", wireType)
+			}
+			m.LossEpisodes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LossEpisodes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LossBurstMax", wireType)
+			}
+			m.LossBurstMax = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LossBurstMax |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 14:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LossBurstAvg", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.LossBurstAvg = float64(math.Float64frombits(v))
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Scored", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Scored = bool(v != 0)
+		case 16:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RttBaseline", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.RttBaseline = float64(math.Float64frombits(v))
+		case 17:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RttDeviation", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.RttDeviation = float64(math.Float64frombits(v))
+		case 18:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RttAnomalous", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.RttAnomalous = bool(v != 0)
+		case 19:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LossBaseline", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.LossBaseline = float64(math.Float64frombits(v))
+		case 20:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LossAnomaly", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.LossAnomaly = float64(math.Float64frombits(v))
+		case 21:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LossAnomalous", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.LossAnomalous = bool(v != 0)
+		case 22:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Tags", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Tags == nil {
+				m.Tags = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowCollector
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowCollector
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthCollector
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthCollector
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowCollector
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthCollector
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthCollector
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipCollector(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthCollector
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Tags[mapkey] = mapvalue
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCollector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SummariesRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCollector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SummariesRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SummariesRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IntervalId", wireType)
+			}
+			m.IntervalId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.IntervalId |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCollector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StreamSummariesRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCollector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StreamSummariesRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StreamSummariesRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCollector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Summaries) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCollector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Summaries: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Summaries: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IntervalId", wireType)
+			}
+			m.IntervalId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.IntervalId |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
+			}
+			m.Start = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Start |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
+			}
+			m.End = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.End |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Summaries", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Summaries = append(m.Summaries, &Summary{})
+			if err := m.Summaries[len(m.Summaries)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCollector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StatusRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCollector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StatusRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StatusRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCollector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Status) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCollector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Status: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Status: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Status = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LatestIntervalId", wireType)
+			}
+			m.LatestIntervalId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LatestIntervalId |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Intervals", wireType)
+			}
+			m.Intervals = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Intervals |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Paths", wireType)
+			}
+			m.Paths = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Paths |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCollector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Target) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCollector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Target: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Target: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Ip", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Ip = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Port", wireType)
+			}
+			m.Port = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Port |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Tags", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Tags == nil {
+				m.Tags = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowCollector
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowCollector
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthCollector
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthCollector
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowCollector
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthCollector
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthCollector
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipCollector(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthCollector
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Tags[mapkey] = mapvalue
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCollector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TargetsRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCollector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TargetsRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TargetsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Test", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Test = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Targets", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Targets = append(m.Targets, &Target{})
+			if err := m.Targets[len(m.Targets)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCollector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Targets) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCollector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Targets: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Targets: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Targets", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCollector
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Targets = append(m.Targets, &Target{})
+			if err := m.Targets[len(m.Targets)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCollector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCollector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipCollector(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowCollector
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+			return iNdEx, nil
+		case 1:
+			iNdEx += 8
+			return iNdEx, nil
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowCollector
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthCollector
+			}
+			iNdEx += length
+			if iNdEx < 0 {
+				return 0, ErrInvalidLengthCollector
+			}
+			return iNdEx, nil
+		case 3:
+			for {
+				var innerWire uint64
+				var start int = iNdEx
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return 0, ErrIntOverflowCollector
+					}
+					if iNdEx >= l {
+						return 0, io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					innerWire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				innerWireType := int(innerWire & 0x7)
+				if innerWireType == 4 {
+					break
+				}
+				next, err := skipCollector(dAtA[start:])
+				if err != nil {
+					return 0, err
+				}
+				iNdEx = start + next
+				if iNdEx < 0 {
+					return 0, ErrInvalidLengthCollector
+				}
+			}
+			return iNdEx, nil
+		case 4:
+			return iNdEx, nil
+		case 5:
+			iNdEx += 4
+			return iNdEx, nil
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+	}
+	panic("unreachable")
+}
+
+var (
+	ErrInvalidLengthCollector = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowCollector   = fmt.Errorf("proto: integer overflow")
+)
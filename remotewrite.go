@@ -0,0 +1,318 @@
+// Writer for sending points to Prometheus-compatible stores via remote write.
+package llama
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+// RemoteWriteVersion is the version of the remote write protocol sent
+const RemoteWriteVersion = "0.1.0"
+
+// The following mirror the messages in Prometheus' `prompb` package, which
+// are all that's needed for remote write. They're declared here, with the
+// struct tags used for reflection, instead of pulling in Prometheus itself.
+
+// PromLabel is a single label on a PromTimeSeries
+type PromLabel struct {
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *PromLabel) Reset()         { *m = PromLabel{} }
+func (m *PromLabel) String() string { return proto.CompactTextString(m) }
+func (*PromLabel) ProtoMessage()    {}
+
+// PromSample is a single value of a PromTimeSeries, with the timestamp in
+// milliseconds
+type PromSample struct {
+	Value     float64 `protobuf:"fixed64,1,opt,name=value,proto3"`
+	Timestamp int64   `protobuf:"varint,2,opt,name=timestamp,proto3"`
+}
+
+func (m *PromSample) Reset()         { *m = PromSample{} }
+func (m *PromSample) String() string { return proto.CompactTextString(m) }
+func (*PromSample) ProtoMessage()    {}
+
+// PromTimeSeries is a set of samples for a single metric and set of labels
+type PromTimeSeries struct {
+	Labels  []*PromLabel  `protobuf:"bytes,1,rep,name=labels"`
+	Samples []*PromSample `protobuf:"bytes,2,rep,name=samples"`
+}
+
+func (m *PromTimeSeries) Reset()         { *m = PromTimeSeries{} }
+func (m *PromTimeSeries) String() string { return proto.CompactTextString(m) }
+func (*PromTimeSeries) ProtoMessage()    {}
+
+// PromWriteRequest is the body of a remote write request, before compression
+type PromWriteRequest struct {
+	Timeseries []*PromTimeSeries `protobuf:"bytes,1,rep,name=timeseries"`
+}
+
+func (m *PromWriteRequest) Reset()         { *m = PromWriteRequest{} }
+func (m *PromWriteRequest) String() string { return proto.CompactTextString(m) }
+func (*PromWriteRequest) ProtoMessage()    {}
+
+// RemoteWriter is used for writing datapoints to a Prometheus remote write
+// endpoint.
+//
+// Each field of a DataPoint becomes its own time series, named from the
+// prefix, measurement, and field, and labeled with the DataPoint's tags.
+type RemoteWriter struct {
+	client        *http.Client
+	url           string
+	prefix        string
+	measurement   bool            // Whether to include the measurement in names
+	labels        map[string]bool // If empty, all tags are included
+	renames       map[string]string
+	invalidLabels string
+	headers       map[string]string
+}
+
+// metricName provides the name for a field of the DataPoint.
+func (w *RemoteWriter) metricName(dp *DataPoint, field string) string {
+	name := w.prefix
+	if w.measurement && dp.Measurement != "" {
+		name += dp.Measurement + "_"
+	}
+	return SanitizePromName(name + field)
+}
+
+// promLabels converts the DataPoint's tags to labels, including `__name__`,
+// sorted by name as expected by Prometheus.
+func (w *RemoteWriter) promLabels(name string, tags Tags) []*PromLabel {
+	labels := []*PromLabel{&PromLabel{Name: "__name__", Value: name}}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if len(w.labels) > 0 && !w.labels[k] {
+			continue
+		}
+		label := k
+		if renamed, found := w.renames[k]; found {
+			label = renamed
+		}
+		if !validPromName(label) {
+			if w.invalidLabels == PromLabelsDrop {
+				continue
+			}
+			label = SanitizePromName(label)
+		}
+		// Don't let a renamed or sanitized tag clobber another one
+		if seen[label] || label == "__name__" {
+			continue
+		}
+		seen[label] = true
+		labels = append(labels, &PromLabel{Name: label, Value: tags[k]})
+	}
+	// Renaming may have changed the order, and `__name__` doesn't sort first
+	// against uppercase names or leading digits
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels
+}
+
+// WriteRequest converts the points to a PromWriteRequest.
+//
+// Points without a time are stamped with `now`, since remote write requires
+// a timestamp on every sample.
+func (w *RemoteWriter) WriteRequest(points Points, now time.Time) *PromWriteRequest {
+	req := &PromWriteRequest{}
+	for i := range points {
+		dp := &points[i]
+		ts := dp.Time
+		if ts.IsZero() {
+			ts = now
+		}
+		ms := ts.UnixNano() / int64(time.Millisecond)
+		fields := make([]string, 0, len(dp.Fields))
+		for field := range dp.Fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			value := float64(dp.Fields[field])
+			if math.IsNaN(value) {
+				continue
+			}
+			req.Timeseries = append(req.Timeseries, &PromTimeSeries{
+				Labels:  w.promLabels(w.metricName(dp, field), dp.Tags),
+				Samples: []*PromSample{&PromSample{Value: value, Timestamp: ms}},
+			})
+		}
+	}
+	return req
+}
+
+// BatchWrite will write the points to the remote write endpoint as a single
+// request
+func (w *RemoteWriter) BatchWrite(points Points) error {
+	data, err := proto.Marshal(w.WriteRequest(points, time.Now()))
+	if err != nil {
+		return fmt.Errorf("Failed to encode points: %v", err)
+	}
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(snappy.Encode(nil, data)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", RemoteWriteVersion)
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	start := time.Now()
+	resp, err := w.client.Do(req)
+	elapsed := time.Since(start).Seconds()
+	if err != nil {
+		log.Println("Remote write failed after:", elapsed, "seconds")
+		return fmt.Errorf("Failed to write batch: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		log.Println("Remote write failed after:", elapsed, "seconds")
+		return fmt.Errorf("Failed to write batch: %s (%s)", resp.Status,
+			strings.TrimSpace(string(body)))
+	}
+	log.Println("Remote write completed in:", elapsed, "seconds")
+	return nil
+}
+
+// Close releases any idle connections to the endpoint
+func (w *RemoteWriter) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+// Health always succeeds, as remote write doesn't define a health check
+// and receivers vary.
+func (w *RemoteWriter) Health() error {
+	return nil
+}
+
+// SetLabels limits which tags are included as labels, and renames them.
+//
+// `labels` lists tags to include, with all included if empty. `renames` maps
+// tag names to the label names used instead. `invalid` is PromLabelsReplace
+// or PromLabelsDrop, for tags which aren't valid label names.
+func (w *RemoteWriter) SetLabels(labels []string, renames map[string]string, invalid string) error {
+	if invalid == "" {
+		invalid = PromLabelsReplace
+	}
+	if invalid != PromLabelsReplace && invalid != PromLabelsDrop {
+		return fmt.Errorf("Unknown handling for invalid labels: %s", invalid)
+	}
+	w.labels = make(map[string]bool)
+	for _, label := range labels {
+		w.labels[label] = true
+	}
+	w.renames = renames
+	w.invalidLabels = invalid
+	return nil
+}
+
+// SetHeader sets a header sent with each request, such as for auth.
+func (w *RemoteWriter) SetHeader(key string, value string) {
+	w.headers[key] = value
+}
+
+// NewRemoteWriter provides a client for writing LLAMA datapoints to a
+// Prometheus remote write endpoint.
+//
+// `prefix` is prepended to metric names, and defaults to DefaultPromPrefix.
+// If `measurement` is true, the DataPoint measurement is included in names
+// after the prefix.
+func NewRemoteWriter(url string, prefix string, measurement bool,
+	timeout time.Duration) (*RemoteWriter, error) {
+	if url == "" {
+		return nil, errors.New("A remote write URL is required")
+	}
+	if prefix == "" {
+		prefix = DefaultPromPrefix
+	}
+	if !validPromName(prefix) {
+		return nil, fmt.Errorf("Invalid metric prefix: %s", prefix)
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	log.Println("Creating remote write writer for", url)
+	return &RemoteWriter{
+		client:        &http.Client{Timeout: timeout},
+		url:           url,
+		prefix:        prefix,
+		measurement:   measurement,
+		labels:        make(map[string]bool),
+		renames:       make(map[string]string),
+		invalidLabels: PromLabelsReplace,
+		headers:       make(map[string]string),
+	}, nil
+}
+
+// splitOption splits a comma-separated option, ignoring empty values.
+func splitOption(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// newRemoteWriterFromOptions creates a RemoteWriter from WriterOptions.
+//
+// `labels` is a comma-separated list of tags to include, and `rename` a
+// comma-separated list of `tag:label` pairs.
+func newRemoteWriterFromOptions(opts WriterOptions) (Writer, error) {
+	measurement, err := opts.Bool("measurement", false)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := opts.Duration("timeout", DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewRemoteWriter(opts.String("url", ""), opts.String("prefix", ""),
+		measurement, timeout)
+	if err != nil {
+		return nil, err
+	}
+	renames := make(map[string]string)
+	for _, pair := range splitOption(opts.String("rename", "")) {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid rename: %s", pair)
+		}
+		renames[parts[0]] = parts[1]
+	}
+	err = w.SetLabels(splitOption(opts.String("labels", "")), renames,
+		opts.String("invalid_labels", ""))
+	if err != nil {
+		return nil, err
+	}
+	if token := opts.String("bearer_token", ""); token != "" {
+		w.SetHeader("Authorization", "Bearer "+token)
+	}
+	return w, nil
+}
+
+func init() {
+	RegisterWriter("remote_write", newRemoteWriterFromOptions)
+}
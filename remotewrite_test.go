@@ -0,0 +1,137 @@
+package llama
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+func TestPromSampleEncoding(t *testing.T) {
+	// Make sure the reflection based encoding matches the wire format
+	// Prometheus expects: value as fixed64 field 1, timestamp as varint 2
+	data, err := proto.Marshal(&PromSample{Value: 1.0, Timestamp: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 0x10, 0x02}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("Expected %x, got %x", expected, data)
+	}
+}
+
+func TestRemoteWriterWriteRequest(t *testing.T) {
+	w, err := NewRemoteWriter("http://localhost", "", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.SetLabels([]string{"src_metro", "dst_metro"},
+		map[string]string{"src_metro": "source"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := Points{examplePoints[0]}
+	points[0].Tags = Tags{"src_metro": "abc", "dst_metro": "xyz", "other": "x"}
+	req := w.WriteRequest(points, time.Now())
+	// One series per field
+	if len(req.Timeseries) != 4 {
+		t.Fatal("Expected 4 series, got", len(req.Timeseries))
+	}
+	ts := req.Timeseries[0]
+	expected := []*PromLabel{
+		{Name: "__name__", Value: "llama_loss"},
+		{Name: "dst_metro", Value: "xyz"},
+		{Name: "source", Value: "abc"},
+	}
+	if len(ts.Labels) != len(expected) {
+		t.Fatal("Unexpected labels:", ts.Labels)
+	}
+	for i, label := range expected {
+		if *ts.Labels[i] != *label {
+			t.Error("Expected label", label, "got", ts.Labels[i])
+		}
+	}
+	if ts.Samples[0].Timestamp != 1514922624000 {
+		t.Error("Expected the point's time in ms, got", ts.Samples[0].Timestamp)
+	}
+}
+
+func TestRemoteWriterLabelOrder(t *testing.T) {
+	w, err := NewRemoteWriter("http://localhost", "", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := w.promLabels("llama_loss", Tags{"Region": "west", "dst_ip": "10.0.0.2"})
+	expected := []string{"Region", "__name__", "dst_ip"}
+	if len(labels) != len(expected) {
+		t.Fatal("Unexpected labels:", labels)
+	}
+	for i, name := range expected {
+		if labels[i].Name != name {
+			t.Error("Expected", name, "at", i, "got", labels[i].Name)
+		}
+	}
+}
+
+func TestRemoteWriter(t *testing.T) {
+	var received PromWriteRequest
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, r *http.Request) {
+			headers = r.Header
+			compressed, _ := ioutil.ReadAll(r.Body)
+			data, err := snappy.Decode(nil, compressed)
+			if err != nil {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			err = proto.Unmarshal(data, &received)
+			if err != nil {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			rw.WriteHeader(http.StatusNoContent)
+		}))
+	defer server.Close()
+	w, err := newRemoteWriterFromOptions(WriterOptions{
+		"url":          server.URL,
+		"prefix":       "mesh_",
+		"measurement":  "true",
+		"bearer_token": "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.BatchWrite(examplePoints)
+	if err != nil {
+		t.Fatal("Failed to write:", err)
+	}
+	if headers.Get("Content-Encoding") != "snappy" ||
+		headers.Get("X-Prometheus-Remote-Write-Version") != RemoteWriteVersion ||
+		headers.Get("Authorization") != "Bearer secret" {
+		t.Error("Unexpected headers:", headers)
+	}
+	if len(received.Timeseries) != 8 {
+		t.Fatal("Expected 8 series, got", len(received.Timeseries))
+	}
+	if received.Timeseries[0].Labels[0].Value != "mesh_measurement_loss" {
+		t.Error("Unexpected name:", received.Timeseries[0].Labels[0])
+	}
+	// Bad configs
+	for _, opts := range []WriterOptions{
+		{},
+		{"url": server.URL, "prefix": "bad-prefix"},
+		{"url": server.URL, "rename": "nope"},
+		{"url": server.URL, "invalid_labels": "nope"},
+	} {
+		_, err = newRemoteWriterFromOptions(opts)
+		if err == nil {
+			t.Error("Expected an error for", opts)
+		}
+	}
+}
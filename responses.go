@@ -0,0 +1,326 @@
+// Precomputed responses for the API's interval endpoints, so that large
+// meshes aren't tagged and encoded again for every request, and the encoding
+// happens without holding any locks the Summarizer needs.
+package llama
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	pb "github.com/dropbox/llama/proto"
+)
+
+// Content types the interval endpoints can respond with. Protobuf responses
+// are a `Summaries` message from proto/collector.proto.
+const (
+	JSONContentType  = "application/json"
+	ProtoContentType = "application/x-protobuf"
+)
+
+// TotalPointsHeader provides the number of points in the whole interval when
+// only a page of them is requested.
+const TotalPointsHeader = "X-Llama-Total-Points"
+
+// Bodies the interval endpoints provide
+const (
+	responsePoints   = "points"   // Just the points, for InfluxHandler
+	responseInterval = "interval" // IntervalPoints, for IntervalHandler
+)
+
+// responseFormat describes how an interval is encoded for a request.
+type responseFormat struct {
+	body   string // responsePoints or responseInterval
+	proto  bool
+	gzip   bool
+	offset int
+	limit  int // All points after `offset` if 0
+}
+
+// String identifies the format, for use in ETags.
+func (f responseFormat) String() string {
+	s := f.body
+	if f.proto {
+		s = "proto"
+	}
+	if f.paged() {
+		s += fmt.Sprintf("-%d-%d", f.offset, f.limit)
+	}
+	if f.gzip {
+		s += "-gzip"
+	}
+	return s
+}
+
+// paged determines if only a page of the points is requested.
+func (f responseFormat) paged() bool {
+	return f.offset > 0 || f.limit > 0
+}
+
+// page provides the bounds of the page within `n` points.
+func (f responseFormat) page(n int) (int, int) {
+	start := f.offset
+	if start > n {
+		start = n
+	}
+	end := n
+	if f.limit > 0 && start+f.limit < n {
+		end = start + f.limit
+	}
+	return start, end
+}
+
+// parseResponseFormat determines how to encode the `body` for the request,
+// from its `Accept` and `Accept-Encoding` headers, and the `offset` and
+// `limit` query parameters.
+//
+// Protobuf is only used if it's explicitly accepted, and preferred at least
+// as much as JSON.
+func parseResponseFormat(request *http.Request, body string) (responseFormat, error) {
+	accept := request.Header.Get("Accept")
+	protoQ := acceptedQuality(accept, ProtoContentType)
+	jsonQ := acceptedQuality(accept, JSONContentType, "application/*", "*/*")
+	f := responseFormat{
+		body:  body,
+		proto: protoQ > 0 && protoQ >= jsonQ,
+		gzip:  acceptsGzip(request),
+	}
+	query := request.URL.Query()
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"offset", &f.offset}, {"limit", &f.limit}} {
+		str := query.Get(param.name)
+		if str == "" {
+			continue
+		}
+		value, err := strconv.Atoi(str)
+		if err != nil || value < 0 {
+			return f, fmt.Errorf("Invalid %s: %s", param.name, str)
+		}
+		*param.value = value
+	}
+	return f, nil
+}
+
+// acceptsGzip determines if the request accepts gzipped responses.
+func acceptsGzip(request *http.Request) bool {
+	return acceptedQuality(request.Header.Get("Accept-Encoding"), "gzip", "*") > 0
+}
+
+// acceptedQuality provides the quality (`q`) that a header like `Accept` or
+// `Accept-Encoding` gives the first of `values` it includes, which should be
+// ordered from most to least specific, such as "text/plain", "text/*", "*/*".
+// It's 0 if none are included, meaning they aren't acceptable.
+func acceptedQuality(header string, values ...string) float64 {
+	qualities := make(map[string]float64)
+	for _, entry := range strings.Split(header, ",") {
+		params := strings.Split(entry, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(strings.TrimSpace(kv[0])) == "q" {
+				var err error
+				q, err = strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+				if err != nil || q < 0 || q > 1 {
+					// Ignore what can't be understood
+					q = 0
+				}
+			}
+		}
+		qualities[value] = q
+	}
+	for _, value := range values {
+		if q, found := qualities[value]; found {
+			return q
+		}
+	}
+	return 0
+}
+
+// gzipBytes compresses data with gzip.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(data)
+	if err == nil {
+		err = gz.Close()
+	}
+	return buf.Bytes(), err
+}
+
+// intervalResponse is an interval along with a single version of the tags,
+// and the bodies encoded from it so far. The points and protobuf are only
+// built when first needed, since most clients only want one of them.
+type intervalResponse struct {
+	interval   *Interval
+	version    uint64
+	tags       TagSet // Just those for the interval's destinations
+	pointsOnce sync.Once
+	points     *IntervalPoints
+	protoOnce  sync.Once
+	proto      *pb.Summaries
+	mutex      sync.Mutex
+	bodies     map[string][]byte // By format, excluding pages
+}
+
+// Points provides the interval as IntervalPoints.
+func (r *intervalResponse) Points() *IntervalPoints {
+	r.pointsOnce.Do(func() {
+		r.points = NewIntervalPoints(r.interval, r.tags)
+	})
+	return r.points
+}
+
+// Proto provides the interval as protobuf Summaries.
+func (r *intervalResponse) Proto() *pb.Summaries {
+	r.protoOnce.Do(func() {
+		r.proto = NewProtoSummaries(r.interval, r.tags)
+	})
+	return r.proto
+}
+
+// ETag identifies the body for the format, which only changes if the tags
+// do, since intervals aren't modified after being summarized.
+func (r *intervalResponse) ETag(f responseFormat) string {
+	return fmt.Sprintf(`"%d-%d-%s"`, r.interval.ID, r.version, f)
+}
+
+// Body provides the body in the format, encoding it if it hasn't been
+// already. Pages are encoded for each request, rather than kept.
+func (r *intervalResponse) Body(f responseFormat) ([]byte, error) {
+	if f.paged() {
+		return r.encode(f)
+	}
+	key := f.String()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if body, found := r.bodies[key]; found {
+		return body, nil
+	}
+	body, err := r.encode(f)
+	if err != nil {
+		return nil, err
+	}
+	r.bodies[key] = body
+	return body, nil
+}
+
+// encode encodes the body in the format.
+func (r *intervalResponse) encode(f responseFormat) ([]byte, error) {
+	var data []byte
+	var err error
+	if f.proto {
+		ps := *r.Proto()
+		start, end := f.page(len(ps.Summaries))
+		ps.Summaries = ps.Summaries[start:end]
+		data, err = ps.Marshal()
+	} else {
+		ip := *r.Points()
+		start, end := f.page(len(ip.Points))
+		ip.Points = ip.Points[start:end]
+		if f.body == responsePoints {
+			data, err = json.Marshal(ip.Points)
+		} else {
+			data, err = json.Marshal(&ip)
+		}
+	}
+	if err != nil || !f.gzip {
+		return data, err
+	}
+	return gzipBytes(data)
+}
+
+// newIntervalResponse keeps a copy of the current tags for the interval's
+// destinations, so the interval can be tagged with them later.
+func (s *SharedTagSet) newIntervalResponse(i *Interval) *intervalResponse {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	// Tags are replaced rather than modified, so they can be shared
+	tags := make(TagSet)
+	for _, summary := range i.Summaries {
+		if summary.Pd == nil {
+			continue
+		}
+		dst := summary.Pd.DstIP.String()
+		if t, found := s.ts[dst]; found {
+			tags[dst] = t
+		}
+	}
+	return &intervalResponse{
+		interval: i,
+		version:  s.version,
+		tags:     tags,
+		bodies:   make(map[string][]byte),
+	}
+}
+
+// responseCache keeps the intervalResponses for the most recent intervals
+// requested.
+type responseCache struct {
+	mutex     sync.Mutex
+	size      int
+	responses map[int64]*intervalResponse
+}
+
+// Get provides the intervalResponse for the interval, creating it if there
+// isn't one for the current tags.
+//
+// Concurrent requests for a new interval may both create one, but that's
+// cheap, since nothing is encoded until it's needed.
+func (c *responseCache) Get(i *Interval, ts *SharedTagSet) *intervalResponse {
+	version := ts.Version()
+	c.mutex.Lock()
+	r, found := c.responses[i.ID]
+	c.mutex.Unlock()
+	if found && r.version == version {
+		return r
+	}
+	r = ts.newIntervalResponse(i)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.responses[i.ID] = r
+	// Drop the oldest, which are the least likely to be requested again,
+	// but never the one just added
+	for len(c.responses) > c.size {
+		oldest := i.ID
+		for id := range c.responses {
+			if id != i.ID && (oldest == i.ID || id < oldest) {
+				oldest = id
+			}
+		}
+		delete(c.responses, oldest)
+	}
+	return r
+}
+
+// newResponseCache creates a responseCache for up to `size` intervals, which
+// should be the number the Summarizer retains, so requests for all of them
+// don't keep replacing each other.
+func newResponseCache(size int) *responseCache {
+	if size < 1 {
+		size = DefaultHistorySize
+	}
+	return &responseCache{size: size, responses: make(map[int64]*intervalResponse)}
+}
+
+// matchesETag determines if the request's `If-None-Match` header includes
+// the ETag, meaning the client already has the body.
+func matchesETag(request *http.Request, etag string) bool {
+	for _, value := range strings.Split(request.Header.Get("If-None-Match"), ",") {
+		value = strings.TrimSpace(value)
+		if value == etag || value == "*" {
+			return true
+		}
+	}
+	return false
+}
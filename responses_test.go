@@ -0,0 +1,154 @@
+package llama
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/dropbox/llama/proto"
+)
+
+// newTestResponsesAPI provides an API like newTestAPI, with the latest
+// interval having summaries for three destinations.
+func newTestResponsesAPI() *API {
+	api := newTestAPI()
+	latest, _ := api.summarizer.Latest()
+	for _, dst := range []string{"10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		latest.Summaries = append(latest.Summaries, &Summary{
+			Pd: &PathDist{SrcIP: net.ParseIP("10.0.0.1"), DstIP: net.ParseIP(dst)},
+		})
+	}
+	return api
+}
+
+func TestInfluxHandlerNegotiation(t *testing.T) {
+	api := newTestResponsesAPI()
+	request := httptest.NewRequest("GET", "/influxdata", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	rw := httptest.NewRecorder()
+	api.InfluxHandler(rw, request)
+	if rw.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("Expected a gzipped response, got", rw.Header())
+	}
+	gz, err := gzip.NewReader(rw.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var points Points
+	err = json.NewDecoder(gz).Decode(&points)
+	if err != nil || len(points) != 3 {
+		t.Error("Expected 3 points, got", points, err)
+	}
+
+	// The same response isn't sent again
+	etag := rw.Header().Get("ETag")
+	request.Header.Set("If-None-Match", etag)
+	rw = httptest.NewRecorder()
+	api.InfluxHandler(rw, request)
+	if rw.Code != 304 || rw.Body.Len() != 0 {
+		t.Error("Expected 304 for a matching ETag, got", rw.Code)
+	}
+
+	// Until the tags change
+	api.MergeUpdateTagSet(TagSet{"10.0.0.2": Tags{"dst_name": "two"}})
+	rw = httptest.NewRecorder()
+	api.InfluxHandler(rw, request)
+	if rw.Code != 200 || rw.Header().Get("ETag") == etag {
+		t.Error("Expected a new response after updating tags, got", rw.Code, rw.Header().Get("ETag"))
+	}
+}
+
+func TestIntervalHandlerProtobuf(t *testing.T) {
+	api := newTestResponsesAPI()
+	request := httptest.NewRequest("GET", "/interval?offset=1&limit=1", nil)
+	request.Header.Set("Accept", ProtoContentType)
+	rw := httptest.NewRecorder()
+	api.IntervalHandler(rw, request)
+	if rw.Header().Get("Content-Type") != ProtoContentType {
+		t.Fatal("Expected a protobuf response, got", rw.Header())
+	}
+	if rw.Header().Get(TotalPointsHeader) != "3" {
+		t.Error("Expected 3 points in total, got", rw.Header().Get(TotalPointsHeader))
+	}
+	var ps pb.Summaries
+	err := ps.Unmarshal(rw.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.IntervalId != 101 || len(ps.Summaries) != 1 || ps.Summaries[0].DstIp != "10.0.0.3" {
+		t.Error("Expected the second summary of interval 101, got", ps)
+	}
+
+	// Pages of JSON too, including past the end
+	rw = httptest.NewRecorder()
+	api.IntervalHandler(rw, httptest.NewRequest("GET", "/interval?offset=2", nil))
+	var ip IntervalPoints
+	err = json.Unmarshal(rw.Body.Bytes(), &ip)
+	if err != nil || ip.ID != 101 || len(ip.Points) != 1 {
+		t.Error("Expected the last point of interval 101, got", ip, err)
+	}
+	rw = httptest.NewRecorder()
+	api.IntervalHandler(rw, httptest.NewRequest("GET", "/interval?offset=5", nil))
+	ip = IntervalPoints{}
+	err = json.Unmarshal(rw.Body.Bytes(), &ip)
+	if err != nil || len(ip.Points) != 0 {
+		t.Error("Expected no points past the end, got", ip, err)
+	}
+	rw = httptest.NewRecorder()
+	api.IntervalHandler(rw, httptest.NewRequest("GET", "/interval?limit=-1", nil))
+	if rw.Code != 400 {
+		t.Error("Expected 400 for an invalid limit, got", rw.Code)
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	ts := NewSharedTagSet(nil)
+	c := newResponseCache(2)
+	first := c.Get(&Interval{ID: 1}, ts)
+	if c.Get(&Interval{ID: 1}, ts) != first {
+		t.Error("Expected the response to be kept")
+	}
+	if first.points != nil || first.proto != nil {
+		t.Error("Expected nothing to be built until it's needed")
+	}
+	c.Get(&Interval{ID: 2}, ts)
+	c.Get(&Interval{ID: 3}, ts)
+	if len(c.responses) != 2 || c.responses[1] != nil {
+		t.Error("Expected the oldest response to be dropped, got", c.responses)
+	}
+	// An older interval requested again isn't dropped as soon as it's added
+	c.Get(&Interval{ID: 1}, ts)
+	if len(c.responses) != 2 || c.responses[1] == nil || c.responses[2] != nil {
+		t.Error("Expected the new response to be kept, got", c.responses)
+	}
+	ts.MergeUpdate(TagSet{"10.0.0.2": Tags{"dst_name": "two"}})
+	if r := c.Get(&Interval{ID: 3}, ts); r.version != ts.Version() {
+		t.Error("Expected a new response for the new tags, got version", r.version)
+	}
+}
+
+func TestParseResponseFormat(t *testing.T) {
+	for _, test := range []struct {
+		accept   string
+		encoding string
+		proto    bool
+		gzip     bool
+	}{
+		{"", "", false, false},
+		{ProtoContentType, "gzip", true, true},
+		{ProtoContentType + ";q=0", "gzip;q=0", false, false},
+		{"application/json, " + ProtoContentType + ";q=0.5", "deflate, *", false, true},
+		{"application/json;q=0.5, " + ProtoContentType, "*, gzip;q=0", true, false},
+		{"*/*", "br;q=1.0, gzip;q=0.8", false, true},
+	} {
+		request := httptest.NewRequest("GET", "/interval", nil)
+		request.Header.Set("Accept", test.accept)
+		request.Header.Set("Accept-Encoding", test.encoding)
+		f, err := parseResponseFormat(request, responseInterval)
+		if err != nil || f.proto != test.proto || f.gzip != test.gzip {
+			t.Error("Unexpected format for", test.accept, "and", test.encoding, "-", f, err)
+		}
+	}
+}
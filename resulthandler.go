@@ -9,6 +9,7 @@ import (
 type Result struct {
 	Pd   *PathDist // Characteristics that make this path unique
 	RTT  uint64    // Round trip time in nanoseconds
+	Sent uint64    // When the test was started (was sent by Port) in ns
 	Done uint64    // When the test completed (was received by Port) in ns
 	Lost bool      // If the Probe was lost and never actually completed
 }
@@ -64,6 +65,7 @@ func NewResultHandler(in chan *Probe, out chan *Result) *ResultHandler {
 func Process(probe *Probe) *Result {
 	result := &Result{
 		Pd:   probe.Pd,
+		Sent: probe.CSent,
 		Done: probe.CRcvd,
 	}
 	// Add additional calculations here
@@ -78,6 +78,10 @@ func TestProcess(t *testing.T) {
 	if result.RTT != 100000 {
 		t.Error("RTT was not correctly calculated")
 	}
+	// Make sure the send time carries over for ordering
+	if result.Sent != probe.CSent {
+		t.Error("Sent time doesn't match between Probe and Result")
+	}
 	// This shouldn't be marked as lost
 	if result.Lost == true {
 		t.Error("Result indicates Lost when it shouldn't")
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	influxdb_client "github.com/influxdata/influxdb1-client/v2"
+	"io"
 	"log"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -48,6 +51,28 @@ func (w *InfluxDbWriter) Close() error {
 	return w.client.Close()
 }
 
+// Health checks that the InfluxDB host is reachable
+func (w *InfluxDbWriter) Health() error {
+	_, _, err := w.client.Ping(DefaultTimeout)
+	return err
+}
+
+// newInfluxDbWriterFromOptions creates an InfluxDbWriter from WriterOptions,
+// with the same defaults as the scraper flags.
+func newInfluxDbWriterFromOptions(opts WriterOptions) (Writer, error) {
+	return NewInfluxDbWriter(
+		opts.String("host", "127.0.0.1"),
+		opts.String("port", "5086"),
+		opts.String("user", ""),
+		opts.String("pass", ""),
+		opts.String("db", "llama"),
+	)
+}
+
+func init() {
+	RegisterWriter("influxdb", newInfluxDbWriterFromOptions)
+}
+
 // Write will commit the batched points to the database
 func (w *InfluxDbWriter) Write(batch influxdb_client.BatchPoints) error {
 	// Write to the DB
@@ -60,7 +85,7 @@ func (w *InfluxDbWriter) Write(batch influxdb_client.BatchPoints) error {
 	}
 	// Only track write delay for successes
 	log.Println("DB write completed in:", elapsed, "seconds")
-	// TODO(dmar): Log rate of `db_write_delay`
+	// Write delay is tracked by the Scraper, for all types of Writer
 	return nil
 }
 
@@ -85,6 +110,9 @@ func (w *InfluxDbWriter) Batch(points Points) (influxdb_client.BatchPoints, erro
 		for key, value := range dp.Fields {
 			newFields[key] = float64(value)
 		}
+		// Collectors stamp points with the start of their interval, which
+		// keeps rewrites of the same interval idempotent. Older collectors
+		// leave this as the zero value, which lets the DB use its own time.
 		pt, err := influxdb_client.NewPoint(
 			dp.Measurement,
 			dp.Tags,
@@ -114,71 +142,560 @@ func (w *InfluxDbWriter) BatchWrite(points Points) error {
 
 // Scraper pulls stats from collectors and writes them to a backend
 type Scraper struct {
-	writer     *InfluxDbWriter
-	collectors []Client
-	port       string
+	writer      Writer
+	mutex       sync.Mutex
+	collectors  []Client
+	tags        map[string]Tags // Extra tags for points, by collector host:port
+	metrics     map[string]*collectorMetrics
+	intervals   map[string][]int64 // Last interval written by each writer, by collector host:port
+	port        string
+	timeout     time.Duration // For new clients, if > 0
+	retries     int
+	retryDelay  time.Duration
+	compress    bool
+	grpc        bool  // Use the gRPC API of new collectors
+	concurrency int   // Max collectors pulled from at once, if > 0
+	running     int32 // Set while a cycle is running
+	cycles      *Counter
+	skipped     *Counter
+	duration    *Gauge
+	lastCycle   time.Time
+}
+
+// collectorMetrics tracks the success and latency of pulling from a
+// collector.
+type collectorMetrics struct {
+	collections   *Counter
+	failures      *Counter
+	points        *Counter
+	written       *Counter
+	duration      *Gauge
+	writeDuration *Gauge
+	lastSuccess   *Gauge
+	duplicates    *Counter
+	missed        *Counter
+	recovered     *Counter
+	mutex         sync.Mutex
+	lastError     string
+	lastFailure   time.Time
+	lastSucceeded time.Time
+}
+
+// success records a successful collection.
+func (m *collectorMetrics) success(now time.Time) {
+	m.lastSuccess.Set(float64(now.UnixNano()) / float64(time.Second))
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastSucceeded = now
+}
+
+// failure records a failed collection, and why.
+func (m *collectorMetrics) failure(now time.Time, err error) {
+	m.failures.Inc()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastError = err.Error()
+	m.lastFailure = now
+}
+
+// Names of the per-collector metrics, so they can be unregistered
+var collectorMetricNames = []string{
+	"llama_scraper_collections_total",
+	"llama_scraper_collection_failures_total",
+	"llama_scraper_points_pulled_total",
+	"llama_scraper_collection_duration_seconds",
+	"llama_scraper_duplicate_intervals_total",
+	"llama_scraper_missed_intervals_total",
+	"llama_scraper_recovered_intervals_total",
+	"llama_scraper_points_written_total",
+	"llama_scraper_write_duration_seconds",
+	"llama_scraper_last_success_timestamp_seconds",
+}
+
+// newCollectorMetrics registers the metrics for the collector at `addr`.
+func newCollectorMetrics(addr string) *collectorMetrics {
+	labels := Tags{"collector": addr}
+	return &collectorMetrics{
+		collections: DefaultMetrics.Counter(collectorMetricNames[0],
+			"Attempts to pull from and write points for the collector.", labels),
+		failures: DefaultMetrics.Counter(collectorMetricNames[1],
+			"Failures pulling from or writing points for the collector.", labels),
+		points: DefaultMetrics.Counter(collectorMetricNames[2],
+			"Points pulled from the collector.", labels),
+		duration: DefaultMetrics.Gauge(collectorMetricNames[3],
+			"Time taken by the last pull from the collector, including retries.", labels),
+		duplicates: DefaultMetrics.Counter(collectorMetricNames[4],
+			"Pulls skipped because the collector's interval was already written.", labels),
+		missed: DefaultMetrics.Counter(collectorMetricNames[5],
+			"Intervals from the collector that were never written.", labels),
+		recovered: DefaultMetrics.Counter(collectorMetricNames[6],
+			"Intervals from the collector that were missed, but written after catching up.", labels),
+		written: DefaultMetrics.Counter(collectorMetricNames[7],
+			"Points from the collector successfully written.", labels),
+		writeDuration: DefaultMetrics.Gauge(collectorMetricNames[8],
+			"Time taken by the last write of points from the collector.", labels),
+		lastSuccess: DefaultMetrics.Gauge(collectorMetricNames[9],
+			"When points from the collector were last successfully written, in Unix time.", labels),
+	}
+}
+
+// NewScraper creates and initializes a means of collecting stats and writing
+// them to the provided Writer, which may be a MultiWriter for writing to
+// several backends.
+func NewScraper(collectors []string, cPort string, writer Writer) *Scraper {
+	s := &Scraper{
+		writer:     writer,
+		port:       cPort,
+		timeout:    DefaultClientTimeout,
+		retries:    DefaultClientRetries,
+		retryDelay: DefaultClientRetryDelay,
+		compress:   true,
+		cycles: DefaultMetrics.Counter("llama_scraper_cycles_total",
+			"Collection cycles completed.", nil),
+		skipped: DefaultMetrics.Counter("llama_scraper_cycles_skipped_total",
+			"Collection cycles skipped because the previous one was still running.", nil),
+		duration: DefaultMetrics.Gauge("llama_scraper_cycle_duration_seconds",
+			"Time taken by the last collection cycle.", nil),
+	}
+	s.SetCollectors(NewStaticDiscoverer(collectors, cPort).targets)
+	return s
+}
+
+// SetClientOptions sets the request timeout, retries, and whether to ask for
+// gzipped responses, for all collectors. Retries wait `retryDelay` first,
+// and double it each time after.
+func (s *Scraper) SetClientOptions(timeout time.Duration, retries int,
+	retryDelay time.Duration, compress bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.timeout = timeout
+	s.retries = retries
+	s.retryDelay = retryDelay
+	s.compress = compress
+	for _, c := range s.collectors {
+		s.configureClient(c)
+	}
+}
+
+// configurableClient is a Client with the options SetClientOptions applies.
+type configurableClient interface {
+	SetTimeout(timeout time.Duration, compress bool)
+	SetRetries(retries int, delay time.Duration)
 }
 
-// NewScraper creates and initializes a means of collecting stats and writing them to a database
-func NewScraper(collectors []string, cPort string, dbHost string, dbPort string, dbUser string, dbPass string, dbName string) (*Scraper, error) {
+// configureClient applies the client options, if the Client supports them.
+// The mutex must be held.
+func (s *Scraper) configureClient(c Client) {
+	cl, ok := c.(configurableClient)
+	if !ok || s.timeout <= 0 {
+		return
+	}
+	cl.SetTimeout(s.timeout, s.compress)
+	cl.SetRetries(s.retries, s.retryDelay)
+}
+
+// SetGRPC sets whether to pull from the gRPC API of collectors, rather than
+// their JSON HTTP API. Their port must then be the one the gRPC API is on.
+//
+// This must be done before setting collectors.
+func (s *Scraper) SetGRPC(enabled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.grpc = enabled
+}
+
+// newClient creates a client for the collector at host and port, using the
+// API chosen with SetGRPC. The mutex must be held.
+func (s *Scraper) newClient(host string, port string) (Client, error) {
+	if s.grpc {
+		return NewGRPCClient(host, port)
+	}
+	return NewClient(host, port), nil
+}
+
+// SetConcurrency limits how many collectors are pulled from at once. There's
+// no limit if < 1.
+func (s *Scraper) SetConcurrency(concurrency int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.concurrency = concurrency
+}
+
+// SetCollectors replaces the collectors to pull from, such as when they're
+// discovered. Collectors are identified by host:port, so existing ones are
+// kept as is, other than their tags.
+func (s *Scraper) SetCollectors(targets []CollectorTarget) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	existing := make(map[string]Client)
+	for _, c := range s.collectors {
+		existing[net.JoinHostPort(c.Hostname(), c.Port())] = c
+	}
 	var clients []Client
-	for _, collector := range collectors {
-		c := NewClient(collector, cPort)
+	tags := make(map[string]Tags)
+	for _, target := range targets {
+		addr := target.Addr()
+		if _, found := tags[addr]; found {
+			continue
+		}
+		c, found := existing[addr]
+		if found {
+			delete(existing, addr)
+		} else {
+			log.Println("Adding collector", addr)
+			var err error
+			c, err = s.newClient(target.Host, target.Port)
+			if err != nil {
+				HandleMinorError(err)
+				continue
+			}
+			s.configureClient(c)
+		}
 		clients = append(clients, c)
+		tags[addr] = target.Tags
 	}
-	w, err := NewInfluxDbWriter(dbHost, dbPort, dbUser, dbPass, dbName)
-	if err != nil {
-		return &Scraper{}, err
+	for addr, c := range existing {
+		log.Println("Removing collector", addr)
+		if closer, ok := c.(io.Closer); ok {
+			HandleMinorError(closer.Close())
+		}
+		for _, name := range collectorMetricNames {
+			DefaultMetrics.Unregister(name, Tags{"collector": addr})
+		}
+		delete(s.metrics, addr)
+		delete(s.intervals, addr)
 	}
-	s := &Scraper{
-		writer:     w,
-		collectors: clients,
-		port:       cPort,
+	s.collectors = clients
+	s.tags = tags
+}
+
+// collectorMetrics provides the metrics for the collector, registering them
+// if needed.
+func (s *Scraper) collectorMetrics(collector Client) *collectorMetrics {
+	addr := net.JoinHostPort(collector.Hostname(), collector.Port())
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.metrics == nil {
+		s.metrics = make(map[string]*collectorMetrics)
 	}
-	return s, nil
+	m, found := s.metrics[addr]
+	if !found {
+		m = newCollectorMetrics(addr)
+		s.metrics[addr] = m
+	}
+	return m
+}
+
+// Collectors provides the current collectors.
+func (s *Scraper) Collectors() []Client {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.collectors
 }
 
-// Run performs collections for all assocated collectors
+// addTags adds the extra tags for the collector to the points, without
+// replacing tags the collector already provided.
+func (s *Scraper) addTags(collector Client, points Points) {
+	s.mutex.Lock()
+	extra := s.tags[net.JoinHostPort(collector.Hostname(), collector.Port())]
+	s.mutex.Unlock()
+	if len(extra) == 0 {
+		return
+	}
+	for i := range points {
+		tags := make(Tags, len(points[i].Tags)+len(extra))
+		for k, v := range extra {
+			tags[k] = v
+		}
+		for k, v := range points[i].Tags {
+			tags[k] = v
+		}
+		points[i].Tags = tags
+	}
+}
+
+// Close releases the resources held by the Scraper's Writer
+func (s *Scraper) Close() error {
+	return s.writer.Close()
+}
+
+// Run performs collections for all assocated collectors. If the previous
+// cycle is still running, such as due to slow collectors, this cycle is
+// skipped rather than overlapping with it.
 func (s *Scraper) Run() {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		log.Println("Previous collection cycle still running, skipping this one")
+		s.skipped.Inc()
+		return
+	}
+	defer atomic.StoreInt32(&s.running, 0)
 	log.Println("Collection cycle starting")
-	// Make sure we don't leave DB connections hanging open
-	defer s.writer.Close()
+	start := time.Now()
+	s.mutex.Lock()
+	concurrency := s.concurrency
+	s.mutex.Unlock()
+	var limit chan bool
+	if concurrency > 0 {
+		limit = make(chan bool, concurrency)
+	}
 	var wg sync.WaitGroup
 	// For each collector
-	for _, collector := range s.collectors {
+	for _, collector := range s.Collectors() {
 		wg.Add(1)
+		if limit != nil {
+			limit <- true
+		}
 		go func(c Client) {
 			defer wg.Done()
+			if limit != nil {
+				defer func() { <-limit }()
+			}
 			err := s.run(c)
 			HandleMinorError(err)
 		}(collector)
 	}
 	wg.Wait()
+	s.duration.Set(time.Since(start).Seconds())
+	s.cycles.Inc()
+	s.mutex.Lock()
+	s.lastCycle = start
+	s.mutex.Unlock()
 	log.Println("Collection cycle complete")
 }
 
 func (s *Scraper) run(collector Client) error {
 	log.Println(collector.Hostname(), "- Collection cycle started")
+	metrics := s.collectorMetrics(collector)
+	metrics.collections.Inc()
 	// Pull stats
-	points, err := collector.GetPoints()
+	start := time.Now()
+	points, id, duplicate, err := s.pull(collector, metrics)
+	metrics.duration.Set(time.Since(start).Seconds())
 	numPoints := float64(len(points))
 	if err != nil {
 		log.Println(collector.Hostname(), "- Collection failed:", err)
-		// TODO(dmar): Log rate of `failed_collections`
+		metrics.failure(time.Now(), err)
 		return err
 	}
+	if duplicate {
+		// Nothing needed writing, which is still a success
+		metrics.success(time.Now())
+		return nil
+	}
 	log.Println(collector.Hostname(), "- Pulled datapoints:", numPoints)
-	// TODO(dmar): Log rate of `pulled_points`
+	metrics.points.Add(uint64(len(points)))
+	s.addTags(collector, points)
 	// Write them to the client
-	err = s.writer.BatchWrite(points)
+	start = time.Now()
+	err = s.write(collector, points, id)
+	metrics.writeDuration.Set(time.Since(start).Seconds())
 	if err != nil {
 		log.Println(collector.Hostname(), "- Collection failed:", err)
-		// TODO(dmar): Log rate of `failed_collections`
+		metrics.failure(time.Now(), err)
 		return err
 	}
 	log.Println(collector.Hostname(), "- Wrote datapoints")
-	// TODO(dmar): Log rate of `written_points`
+	metrics.written.Add(uint64(len(points)))
+	metrics.success(time.Now())
 	log.Println(collector.Hostname(), "- Collection cycle completed")
-	// TODO(dmar): Log rate of `successful_collections`
 	return nil
 }
+
+// pull fetches the points to write from the collector, and the ID of the
+// latest interval they include, or 0 if the collector doesn't provide IDs.
+//
+// If the collector provides interval IDs, an interval that's already been
+// written is skipped, indicated by `duplicate`. If intervals were missed since
+// the last one written, they're fetched as well, if the collector still has
+// them.
+func (s *Scraper) pull(collector Client, metrics *collectorMetrics) (points Points,
+	id int64, duplicate bool, err error) {
+	ic, ok := collector.(IntervalClient)
+	if !ok {
+		points, err = collector.GetPoints()
+		return points, 0, false, err
+	}
+	points, id, err = ic.GetIntervalPoints()
+	if err != nil || id == 0 {
+		return points, 0, false, err
+	}
+	last := s.lastInterval(collector)
+	if id <= last {
+		log.Println(collector.Hostname(), "- Skipping interval", id, "which was already written")
+		metrics.duplicates.Inc()
+		return nil, id, true, nil
+	}
+	if last == 0 || id == last+1 {
+		return points, id, false, nil
+	}
+	// Catch up on what was missed, oldest first
+	missed := id - last - 1
+	log.Println(collector.Hostname(), "- Missed", missed, "intervals after", last, "- catching up")
+	intervals, err := ic.GetIntervalsSince(last)
+	if err != nil {
+		log.Println(collector.Hostname(), "- Failed to catch up:", err)
+		intervals = nil
+	}
+	var caughtUp Points
+	var recovered int64
+	for _, interval := range intervals {
+		if interval.ID > last && interval.ID < id {
+			caughtUp = append(caughtUp, PointsFrom(interval.Points)...)
+			recovered++
+		}
+	}
+	metrics.recovered.Add(uint64(recovered))
+	if recovered < missed {
+		log.Println(collector.Hostname(), "- Gap in intervals,", missed-recovered,
+			"between", last, "and", id, "are no longer available")
+		metrics.missed.Add(uint64(missed - recovered))
+	}
+	return append(caughtUp, points...), id, false, nil
+}
+
+// write writes the points pulled from the collector, up to interval `id`,
+// and records the last interval written.
+//
+// With a MultiWriter, the last interval is recorded for each of its Writers,
+// and each is only sent the intervals it hasn't written yet. That way, when
+// one backend fails, the next cycle doesn't write the same intervals to the
+// others again.
+func (s *Scraper) write(collector Client, points Points, id int64) error {
+	if id == 0 {
+		return s.writer.BatchWrite(points)
+	}
+	mw, ok := s.writer.(*MultiWriter)
+	if !ok {
+		err := s.writer.BatchWrite(points)
+		if err == nil {
+			s.setLastIntervals(collector, []int64{id})
+		}
+		return err
+	}
+	last := s.lastIntervals(collector, len(mw.writers))
+	errs := mw.batchWriteEach(func(i int) (Points, bool) {
+		if last[i] >= id {
+			return nil, false
+		}
+		var pending Points
+		for _, point := range points {
+			// Points without an ID are from the latest interval
+			if point.IntervalID > last[i] || point.IntervalID == 0 {
+				pending = append(pending, point)
+			}
+		}
+		return pending, true
+	})
+	for i, err := range errs {
+		if err == nil && last[i] < id {
+			last[i] = id
+		}
+	}
+	s.setLastIntervals(collector, last)
+	return combineErrors("write", errs)
+}
+
+// lastInterval provides the ID of the last interval written for the
+// collector, or 0 if there isn't one. With several writers, it's the last
+// one written by all of them.
+func (s *Scraper) lastInterval(collector Client) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var last int64
+	for i, id := range s.intervals[net.JoinHostPort(collector.Hostname(), collector.Port())] {
+		if i == 0 || id < last {
+			last = id
+		}
+	}
+	return last
+}
+
+// lastIntervals provides the ID of the last interval written for the
+// collector by each of `n` writers, with 0 for those without one.
+func (s *Scraper) lastIntervals(collector Client, n int) []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	last := make([]int64, n)
+	copy(last, s.intervals[net.JoinHostPort(collector.Hostname(), collector.Port())])
+	return last
+}
+
+// setLastIntervals records the ID of the last interval written for the
+// collector by each writer.
+func (s *Scraper) setLastIntervals(collector Client, ids []int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.intervals == nil {
+		s.intervals = make(map[string][]int64)
+	}
+	s.intervals[net.JoinHostPort(collector.Hostname(), collector.Port())] = ids
+}
+
+// CollectorStatus describes how pulling from a collector has been going.
+type CollectorStatus struct {
+	Collector          string    `json:"collector"`
+	Collections        uint64    `json:"collections"`
+	Failures           uint64    `json:"failures"`
+	PulledPoints       uint64    `json:"pulled_points"`
+	WrittenPoints      uint64    `json:"written_points"`
+	PullSeconds        float64   `json:"pull_seconds"`  // For the last pull
+	WriteSeconds       float64   `json:"write_seconds"` // For the last write
+	LastSuccess        time.Time `json:"last_success"`
+	LastFailure        time.Time `json:"last_failure"`
+	LastError          string    `json:"last_error,omitempty"`
+	LastInterval       int64     `json:"last_interval,omitempty"`
+	DuplicateIntervals uint64    `json:"duplicate_intervals"`
+	MissedIntervals    uint64    `json:"missed_intervals"`
+	RecoveredIntervals uint64    `json:"recovered_intervals"`
+}
+
+// ScraperStatus describes the overall state of a Scraper.
+type ScraperStatus struct {
+	Cycles           uint64            `json:"cycles"`
+	SkippedCycles    uint64            `json:"skipped_cycles"`
+	LastCycle        time.Time         `json:"last_cycle"`
+	LastCycleSeconds float64           `json:"last_cycle_seconds"`
+	WriterError      string            `json:"writer_error,omitempty"`
+	Collectors       []CollectorStatus `json:"collectors"`
+}
+
+// Status provides the current state of the Scraper and its collectors,
+// including checking the health of the Writer.
+func (s *Scraper) Status() *ScraperStatus {
+	status := &ScraperStatus{
+		Cycles:           s.cycles.Value(),
+		SkippedCycles:    s.skipped.Value(),
+		LastCycleSeconds: s.duration.Value(),
+		Collectors:       make([]CollectorStatus, 0),
+	}
+	if err := s.writer.Health(); err != nil {
+		status.WriterError = err.Error()
+	}
+	for _, collector := range s.Collectors() {
+		addr := net.JoinHostPort(collector.Hostname(), collector.Port())
+		m := s.collectorMetrics(collector)
+		cs := CollectorStatus{
+			Collector:          addr,
+			Collections:        m.collections.Value(),
+			Failures:           m.failures.Value(),
+			PulledPoints:       m.points.Value(),
+			WrittenPoints:      m.written.Value(),
+			PullSeconds:        m.duration.Value(),
+			WriteSeconds:       m.writeDuration.Value(),
+			LastInterval:       s.lastInterval(collector),
+			DuplicateIntervals: m.duplicates.Value(),
+			MissedIntervals:    m.missed.Value(),
+			RecoveredIntervals: m.recovered.Value(),
+		}
+		m.mutex.Lock()
+		cs.LastSuccess = m.lastSucceeded
+		cs.LastError = m.lastError
+		cs.LastFailure = m.lastFailure
+		m.mutex.Unlock()
+		status.Collectors = append(status.Collectors, cs)
+	}
+	s.mutex.Lock()
+	status.LastCycle = s.lastCycle
+	s.mutex.Unlock()
+	return status
+}
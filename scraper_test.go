@@ -1,8 +1,10 @@
 package llama
 
 import (
+	"errors"
 	influxdb_client "github.com/influxdata/influxdb1-client/v2"
 	gocheck "gopkg.in/check.v1"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -123,6 +125,10 @@ func (s *ScraperSuite) TestInfluxDbWriter_Batch(c *gocheck.C) {
 	batch, err := s.writer.Batch(examplePoints)
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(len(batch.Points()), gocheck.Equals, 2)
+	// Points should keep the timestamps provided by the collector
+	for _, pt := range batch.Points() {
+		c.Assert(pt.Time(), gocheck.Equals, examplePoints[0].Time)
+	}
 }
 
 func (s *ScraperSuite) TestInfluxDbWriter_BatchWrite(c *gocheck.C) {
@@ -131,12 +137,19 @@ func (s *ScraperSuite) TestInfluxDbWriter_BatchWrite(c *gocheck.C) {
 }
 
 func (s *ScraperSuite) TestNewScraper(c *gocheck.C) {
-	newS, err := NewScraper([]string{"localhost", "127.0.0.1"}, "5000", "localhost", "5086", "user", "pass", "dbname")
-	c.Assert(err, gocheck.IsNil)
+	newS := NewScraper([]string{"localhost", "127.0.0.1"}, "5000", s.writer)
 	c.Assert(newS, gocheck.FitsTypeOf, &Scraper{})
-	newS, err = NewScraper([]string{"1.2.3.4", "127.0.0.1"}, "5000", "127.0.0.1", "5086", "", "", "dbname")
+	c.Assert(len(newS.collectors), gocheck.Equals, 2)
+}
+
+func (s *ScraperSuite) TestInfluxDbWriterFromOptions(c *gocheck.C) {
+	writer, err := NewWriter(WriterConfig{
+		Type:    "influxdb",
+		Options: WriterOptions{"host": "localhost", "db": "dbname"},
+	})
 	c.Assert(err, gocheck.IsNil)
-	c.Assert(newS, gocheck.FitsTypeOf, &Scraper{})
+	c.Assert(writer, gocheck.FitsTypeOf, &InfluxDbWriter{})
+	c.Assert(writer.(*InfluxDbWriter).db, gocheck.Equals, "dbname")
 }
 
 func (s *ScraperSuite) TestScraper_run(c *gocheck.C) {
@@ -145,3 +158,205 @@ func (s *ScraperSuite) TestScraper_run(c *gocheck.C) {
 		c.Assert(err, gocheck.IsNil)
 	}
 }
+
+// blockingClient is a Client that waits for `release` before providing
+// points, tracking how many are waiting at once.
+type blockingClient struct {
+	MockClient
+	name    string
+	active  *int32
+	peak    *int32
+	release chan bool
+}
+
+func (b *blockingClient) Hostname() string {
+	return b.name
+}
+
+func (b *blockingClient) GetPoints() (Points, error) {
+	n := atomic.AddInt32(b.active, 1)
+	for {
+		peak := atomic.LoadInt32(b.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(b.peak, peak, n) {
+			break
+		}
+	}
+	<-b.release
+	atomic.AddInt32(b.active, -1)
+	return Points{}, nil
+}
+
+func TestScraperConcurrency(t *testing.T) {
+	var active, peak int32
+	release := make(chan bool)
+	s := NewScraper(nil, "5000", &MockWriter{})
+	var clients []Client
+	for _, name := range []string{"a", "b", "c", "d"} {
+		clients = append(clients, &blockingClient{name: name, active: &active,
+			peak: &peak, release: release})
+	}
+	s.collectors = clients
+	s.SetConcurrency(2)
+	done := make(chan bool)
+	go func() {
+		s.Run()
+		close(done)
+	}()
+	// Wait for the first cycle to be blocked on collectors, so the next
+	// overlaps with it
+	for atomic.LoadInt32(&active) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	before := DefaultMetrics.Snapshot()["llama_scraper_cycles_skipped_total"][""]
+	s.Run()
+	after := DefaultMetrics.Snapshot()["llama_scraper_cycles_skipped_total"][""]
+	if after != before+1 {
+		t.Error("Expected the overlapping cycle to be skipped")
+	}
+	for range clients {
+		release <- true
+	}
+	<-done
+	if peak != 2 {
+		t.Error("Expected at most 2 collectors at once, got", peak)
+	}
+	collections := DefaultMetrics.Snapshot()["llama_scraper_collections_total"]
+	if collections[`collector="a:"`] < 1 {
+		t.Error("Expected a collection recorded for the collector, got", collections)
+	}
+}
+
+// intervalClient is an IntervalClient providing `id` with `points` for the
+// latest interval, and `retained` for earlier ones.
+type intervalClient struct {
+	MockClient
+	id       int64
+	points   Points
+	retained []*IntervalPoints
+}
+
+func (ic *intervalClient) GetIntervalPoints() (Points, int64, error) {
+	return ic.points, ic.id, nil
+}
+
+func (ic *intervalClient) GetIntervalsSince(id int64) ([]*IntervalPoints, error) {
+	var intervals []*IntervalPoints
+	for _, interval := range ic.retained {
+		if interval.ID > id {
+			intervals = append(intervals, interval)
+		}
+	}
+	return intervals, nil
+}
+
+func TestScraperIntervals(t *testing.T) {
+	writer := &MockWriter{}
+	s := NewScraper(nil, "5000", writer)
+	ic := &intervalClient{
+		MockClient: MockClient{hostname: "dedup", port: "5000"},
+		id:         10,
+		points:     Points{DataPoint{IntervalID: 10}},
+	}
+	s.collectors = []Client{ic}
+	HandleMinorError(s.run(ic))
+	if len(writer.points) != 1 || s.lastInterval(ic) != 10 {
+		t.Fatal("Expected the first interval to be written, got", writer.points)
+	}
+
+	// The same interval again is skipped
+	HandleMinorError(s.run(ic))
+	if len(writer.points) != 1 {
+		t.Error("Expected the repeated interval to be skipped, got", writer.points)
+	}
+	metrics := s.collectorMetrics(ic)
+	if metrics.duplicates.Value() != 1 {
+		t.Error("Expected a duplicate to be counted, got", metrics.duplicates.Value())
+	}
+
+	// Gaps are filled from what the collector retained, oldest first
+	ic.id = 13
+	ic.points = Points{DataPoint{IntervalID: 13}}
+	ic.retained = []*IntervalPoints{
+		{ID: 10, Points: []*DataPoint{{IntervalID: 10}}},
+		{ID: 11, Points: []*DataPoint{{IntervalID: 11}}},
+		{ID: 13, Points: []*DataPoint{{IntervalID: 13}}},
+	}
+	HandleMinorError(s.run(ic))
+	if len(writer.points) != 3 || writer.points[1].IntervalID != 11 || writer.points[2].IntervalID != 13 {
+		t.Error("Expected the missed interval to be written first, got", writer.points)
+	}
+	if metrics.recovered.Value() != 1 || metrics.missed.Value() != 1 {
+		t.Error("Expected 1 recovered and 1 missed, got", metrics.recovered.Value(),
+			metrics.missed.Value())
+	}
+	if s.lastInterval(ic) != 13 {
+		t.Error("Expected the last interval to be 13, got", s.lastInterval(ic))
+	}
+
+	// Failed writes aren't recorded, so they're caught up on next time
+	writer.err = errors.New("down")
+	ic.id = 14
+	HandleMinorError(s.run(ic))
+	if s.lastInterval(ic) != 13 {
+		t.Error("Expected the failed interval not to be recorded, got", s.lastInterval(ic))
+	}
+}
+
+func TestScraperIntervalsMultiWriter(t *testing.T) {
+	good := &MockWriter{}
+	bad := &MockWriter{}
+	s := NewScraper(nil, "5000", NewMultiWriter(good, bad))
+	ic := &intervalClient{
+		MockClient: MockClient{hostname: "multi", port: "5000"},
+		id:         10,
+		points:     Points{DataPoint{IntervalID: 10}},
+	}
+	s.collectors = []Client{ic}
+	HandleMinorError(s.run(ic))
+	bad.err = errors.New("down")
+	ic.id = 11
+	ic.points = Points{DataPoint{IntervalID: 11}}
+	if err := s.run(ic); err == nil {
+		t.Error("Expected an error for the failed writer")
+	}
+	if s.lastInterval(ic) != 10 {
+		t.Error("Expected the last interval written by every writer to be 10, got", s.lastInterval(ic))
+	}
+
+	// Once it recovers, only the failed writer gets the missed interval
+	bad.err = nil
+	ic.id = 12
+	ic.points = Points{DataPoint{IntervalID: 12}}
+	ic.retained = []*IntervalPoints{
+		{ID: 11, Points: []*DataPoint{{IntervalID: 11}}},
+		{ID: 12, Points: []*DataPoint{{IntervalID: 12}}},
+	}
+	HandleMinorError(s.run(ic))
+	if len(good.points) != 3 || good.points[1].IntervalID != 11 || good.points[2].IntervalID != 12 {
+		t.Error("Expected each interval written once to the good writer, got", good.points)
+	}
+	if len(bad.points) != 3 || bad.points[1].IntervalID != 11 || bad.points[2].IntervalID != 12 {
+		t.Error("Expected the missed interval written to the recovered writer, got", bad.points)
+	}
+	if s.lastInterval(ic) != 12 {
+		t.Error("Expected the last interval to be 12, got", s.lastInterval(ic))
+	}
+}
+
+func TestScraperGRPC(t *testing.T) {
+	s := NewScraper(nil, "5000", &MockWriter{})
+	s.SetGRPC(true)
+	s.SetClientOptions(time.Second, 0, time.Second, false)
+	s.SetCollectors([]CollectorTarget{{Host: "127.0.0.1", Port: "5001"}})
+	c, ok := s.Collectors()[0].(*grpcClient)
+	if !ok {
+		t.Fatal("Expected a gRPC client, got", s.Collectors()[0])
+	}
+	if c.timeout != time.Second || c.compress {
+		t.Error("Expected the client options to be applied, got", c.timeout, c.compress)
+	}
+	s.SetCollectors(nil)
+	if len(s.Collectors()) != 0 {
+		t.Error("Expected the collector to be removed, got", s.Collectors())
+	}
+}
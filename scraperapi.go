@@ -0,0 +1,78 @@
+package llama
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+)
+
+// DefaultScraperAPIAddr is where the ScraperAPI listens, unless configured
+// otherwise.
+const DefaultScraperAPIAddr = ":5001"
+
+// ScraperAPI represents the HTTP server answering queries about the health of
+// a Scraper.
+type ScraperAPI struct {
+	scraper *Scraper
+	server  *http.Server
+	handler *http.ServeMux
+}
+
+// StatusHandler handles requests for the status of the scraper and each of
+// its collectors, as JSON.
+func (api *ScraperAPI) StatusHandler(rw http.ResponseWriter, request *http.Request) {
+	writeJSON(rw, api.scraper.Status())
+}
+
+// MetricsHandler handles requests for metrics on the health of the scraper,
+// in the Prometheus text format.
+func (api *ScraperAPI) MetricsHandler(rw http.ResponseWriter, request *http.Request) {
+	var buf bytes.Buffer
+	DefaultMetrics.Write(&buf)
+	rw.Header().Set("Content-Type", PromContentType)
+	_, err := rw.Write(buf.Bytes())
+	HandleMinorError(err)
+}
+
+// Stop will close down the server and cause Run to exit.
+func (api *ScraperAPI) Stop() {
+	err := api.server.Close()
+	if err != nil {
+		log.Println("Error stopping scraper API:", err)
+	}
+	log.Println("Scraper API Stopped")
+}
+
+// Run calls RunForever in a separate goroutine for non-blocking behavior.
+func (api *ScraperAPI) Run() {
+	go api.RunForever()
+}
+
+// RunForever sets up the handlers above and then listens for requests until
+// stopped or a fatal error occurs.
+//
+// Calling this will block until stopped/crashed.
+func (api *ScraperAPI) RunForever() {
+	api.setupHandlers()
+	err := api.server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+// setupHandlers attaches the handlers above to the http server mux.
+func (api *ScraperAPI) setupHandlers() {
+	api.handler.HandleFunc("/status", api.StatusHandler)
+	api.handler.HandleFunc("/metrics", api.MetricsHandler)
+}
+
+// NewScraperAPI returns an initialized ScraperAPI for `s`, to listen on
+// `addr`.
+func NewScraperAPI(s *Scraper, addr string) *ScraperAPI {
+	handler := http.NewServeMux()
+	server := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+	return &ScraperAPI{scraper: s, handler: handler, server: server}
+}
@@ -0,0 +1,62 @@
+package llama
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScraperAPIStatusHandler(t *testing.T) {
+	writer := &MockWriter{}
+	s := NewScraper(nil, "5000", writer)
+	good := &MockClient{hostname: "good", port: "5000", NextPoints: Points{DataPoint{}}}
+	bad := &MockClient{hostname: "bad", port: "5000", NextErr: errors.New("timeout")}
+	s.collectors = []Client{good, bad}
+	s.Run()
+	api := NewScraperAPI(s, "127.0.0.1:0")
+	rw := httptest.NewRecorder()
+	api.StatusHandler(rw, httptest.NewRequest("GET", "/status", nil))
+	if rw.Code != 200 {
+		t.Fatal("Expected 200, got", rw.Code)
+	}
+	status := &ScraperStatus{}
+	err := json.Unmarshal(rw.Body.Bytes(), status)
+	if err != nil {
+		t.Fatal("Failed to parse response:", err)
+	}
+	if len(status.Collectors) != 2 || status.Cycles < 1 {
+		t.Fatal("Unexpected status:", rw.Body.String())
+	}
+	g, b := status.Collectors[0], status.Collectors[1]
+	if g.Collector != "good:5000" || g.LastSuccess.IsZero() || g.WrittenPoints < 1 {
+		t.Error("Unexpected status for the good collector:", g)
+	}
+	if b.Collector != "bad:5000" || b.Failures < 1 || b.LastError != "timeout" || !b.LastSuccess.IsZero() {
+		t.Error("Unexpected status for the bad collector:", b)
+	}
+
+	// Unhealthy writers are reported too
+	writer.err = errors.New("down")
+	rw = httptest.NewRecorder()
+	api.StatusHandler(rw, httptest.NewRequest("GET", "/status", nil))
+	if !strings.Contains(rw.Body.String(), `"writer_error":"down"`) {
+		t.Error("Expected the writer error, got", rw.Body.String())
+	}
+}
+
+func TestScraperAPIMetricsHandler(t *testing.T) {
+	s := NewScraper(nil, "5000", &MockWriter{})
+	s.collectors = []Client{&MockClient{hostname: "metrics", port: "5000"}}
+	s.Run()
+	api := NewScraperAPI(s, "127.0.0.1:0")
+	rw := httptest.NewRecorder()
+	api.MetricsHandler(rw, httptest.NewRequest("GET", "/metrics", nil))
+	if rw.Header().Get("Content-Type") != PromContentType {
+		t.Error("Unexpected content type:", rw.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rw.Body.String(), `llama_scraper_last_success_timestamp_seconds{collector="metrics:5000"}`) {
+		t.Error("Expected per-collector metrics, got", rw.Body.String())
+	}
+}
@@ -0,0 +1,406 @@
+// SpoolWriter saves batches that fail to write to disk, and replays them
+// once the backend recovers.
+package llama
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for SpoolWriter
+const (
+	DefaultSpoolMaxBytes   = 1024 * 1024 * 1024 // 1 GiB
+	DefaultSpoolMinBackoff = time.Second
+	DefaultSpoolMaxBackoff = 5 * time.Minute
+)
+
+// Suffixes for the files holding spooled batches, and those being written
+const (
+	spoolSuffix    = ".spool.json"
+	spoolTmpSuffix = ".tmp"
+)
+
+// spoolEntry is a single batch saved to disk.
+type spoolEntry struct {
+	seq  uint64
+	path string
+	size int64
+}
+
+// spoolMetrics tracks the state of a spool.
+type spoolMetrics struct {
+	batches  *Gauge
+	bytes    *Gauge
+	spooled  *Counter
+	replayed *Counter
+	dropped  *Counter
+}
+
+// SpoolWriter wraps another Writer, saving batches that fail to write to a
+// bounded on-disk spool. Spooled batches are replayed in order, with
+// exponential backoff between failed attempts, until the spool is empty.
+//
+// While anything is spooled, new batches are spooled too, so they're written
+// in order. When the spool is full, the oldest batches are dropped.
+type SpoolWriter struct {
+	writer     Writer
+	dir        string
+	maxBytes   int64
+	maxBatches int // No limit if < 1
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	mutex      sync.Mutex
+	entries    []spoolEntry // Oldest first
+	bytes      int64
+	seq        uint64 // Sequence for the next entry
+	backoff    time.Duration
+	wake       chan bool // Signals that there's something new to replay
+	running    bool
+	stop       chan bool
+	done       chan bool
+	metrics    spoolMetrics
+}
+
+// BatchWrite writes the points to the underlying Writer, or spools them if
+// that fails or earlier batches are still spooled. An error is only
+// returned if the points couldn't be written or spooled.
+func (s *SpoolWriter) BatchWrite(points Points) error {
+	s.mutex.Lock()
+	pending := len(s.entries)
+	s.mutex.Unlock()
+	if pending == 0 {
+		err := s.writer.BatchWrite(points)
+		if err == nil {
+			return nil
+		}
+		log.Println("Write failed, spooling batch:", err)
+	}
+	return s.spool(points)
+}
+
+// spool saves the points to disk, dropping the oldest batches if needed to
+// make room.
+func (s *SpoolWriter) spool(points Points) error {
+	data, err := json.Marshal(points)
+	if err != nil {
+		return fmt.Errorf("Failed to encode batch for spool: %v", err)
+	}
+	size := int64(len(data))
+	if size > s.maxBytes {
+		s.metrics.dropped.Inc()
+		return fmt.Errorf("Batch of %d bytes is larger than the spool", size)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for len(s.entries) > 0 && (s.bytes+size > s.maxBytes ||
+		(s.maxBatches > 0 && len(s.entries) >= s.maxBatches)) {
+		log.Println("Spool full, dropping oldest batch", s.entries[0].path)
+		s.remove()
+		s.metrics.dropped.Inc()
+	}
+	entry := spoolEntry{
+		seq:  s.seq,
+		path: filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.seq, spoolSuffix)),
+		size: size,
+	}
+	// Write to a temp file first, so a partial batch is never replayed
+	tmp := entry.path + spoolTmpSuffix
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err == nil {
+		err = os.Rename(tmp, entry.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("Failed to spool batch: %v", err)
+	}
+	s.seq++
+	s.entries = append(s.entries, entry)
+	s.bytes += size
+	s.metrics.spooled.Inc()
+	s.updateGauges()
+	// Let the replay loop know, without blocking if it already does
+	select {
+	case s.wake <- true:
+	default:
+	}
+	return nil
+}
+
+// remove deletes the oldest entry. The mutex must be held.
+func (s *SpoolWriter) remove() {
+	entry := s.entries[0]
+	err := os.Remove(entry.path)
+	if err != nil && !os.IsNotExist(err) {
+		HandleMinorError(err)
+	}
+	s.entries = s.entries[1:]
+	s.bytes -= entry.size
+	s.updateGauges()
+}
+
+// updateGauges updates the spool depth metrics. The mutex must be held.
+func (s *SpoolWriter) updateGauges() {
+	s.metrics.batches.Set(float64(len(s.entries)))
+	s.metrics.bytes.Set(float64(s.bytes))
+}
+
+// Depth provides the number of batches and bytes currently spooled.
+func (s *SpoolWriter) Depth() (int, int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.entries), s.bytes
+}
+
+// Run starts replaying spooled batches in the background.
+func (s *SpoolWriter) Run() {
+	s.mutex.Lock()
+	s.running = true
+	s.mutex.Unlock()
+	go s.run()
+}
+
+func (s *SpoolWriter) run() {
+	defer close(s.done)
+	for {
+		// Only wait for the backoff after failures
+		var delay <-chan time.Time
+		if s.backoff > 0 {
+			delay = time.After(s.backoff)
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+			if s.backoff > 0 {
+				// Still backing off, but there's something to do after
+				select {
+				case <-s.stop:
+					return
+				case <-delay:
+				}
+			}
+		case <-delay:
+		}
+		s.replay()
+	}
+}
+
+// replay writes spooled batches, oldest first, until the spool is empty or
+// a write fails. Failures increase the backoff, and success resets it.
+func (s *SpoolWriter) replay() {
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		s.mutex.Lock()
+		if len(s.entries) == 0 {
+			s.mutex.Unlock()
+			s.backoff = 0
+			return
+		}
+		entry := s.entries[0]
+		s.mutex.Unlock()
+		var points Points
+		data, err := ioutil.ReadFile(entry.path)
+		if err == nil {
+			err = json.Unmarshal(data, &points)
+			if err != nil {
+				// Retrying won't help this one
+				log.Println("Dropping unreadable spooled batch", entry.path, "-", err)
+				s.removeEntry(entry.seq)
+				s.metrics.dropped.Inc()
+				continue
+			}
+			err = s.writer.BatchWrite(points)
+		}
+		if err != nil {
+			s.increaseBackoff()
+			log.Println("Replaying spooled batch failed, retrying in", s.backoff, "-", err)
+			return
+		}
+		s.removeEntry(entry.seq)
+		s.metrics.replayed.Inc()
+		s.backoff = 0
+	}
+}
+
+// removeEntry removes the oldest entry if it's still the one with `seq`,
+// since it may have been dropped while being replayed.
+func (s *SpoolWriter) removeEntry(seq uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.entries) > 0 && s.entries[0].seq == seq {
+		s.remove()
+	}
+}
+
+// increaseBackoff doubles the backoff, within the configured bounds.
+func (s *SpoolWriter) increaseBackoff() {
+	s.backoff *= 2
+	if s.backoff < s.minBackoff {
+		s.backoff = s.minBackoff
+	}
+	if s.backoff > s.maxBackoff {
+		s.backoff = s.maxBackoff
+	}
+}
+
+// Close stops replaying and closes the underlying Writer. Anything still
+// spooled is kept on disk, and replayed by the next SpoolWriter using the
+// same directory.
+func (s *SpoolWriter) Close() error {
+	s.mutex.Lock()
+	running := s.running
+	s.running = false
+	s.mutex.Unlock()
+	if running {
+		close(s.stop)
+		// Let any write in progress finish, so it isn't replayed twice
+		<-s.done
+	}
+	return s.writer.Close()
+}
+
+// Health checks the underlying Writer.
+func (s *SpoolWriter) Health() error {
+	return s.writer.Health()
+}
+
+// load finds batches spooled previously in the directory, and removes any
+// left partially written.
+func (s *SpoolWriter) load() error {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if !info.IsDir() && strings.HasSuffix(name, spoolSuffix+spoolTmpSuffix) {
+			log.Println("Removing partially spooled batch", name)
+			HandleMinorError(os.Remove(filepath.Join(s.dir, name)))
+			continue
+		}
+		if info.IsDir() || !strings.HasSuffix(name, spoolSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		s.entries = append(s.entries, spoolEntry{
+			seq:  seq,
+			path: filepath.Join(s.dir, name),
+			size: info.Size(),
+		})
+		s.bytes += info.Size()
+	}
+	sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].seq < s.entries[j].seq })
+	if len(s.entries) > 0 {
+		s.seq = s.entries[len(s.entries)-1].seq + 1
+		log.Println("Found", len(s.entries), "spooled batches in", s.dir)
+	}
+	s.updateGauges()
+	return nil
+}
+
+// NewSpoolWriter creates a SpoolWriter wrapping w, using `dir` for the spool,
+// which is created if needed. Anything already spooled there is replayed
+// once running.
+//
+// The spool is limited to `maxBytes` (DefaultSpoolMaxBytes if < 1) and, if
+// > 0, `maxBatches`. Backoff starts at `minBackoff` and doubles up to
+// `maxBackoff`, using the defaults if either is < 1.
+func NewSpoolWriter(w Writer, dir string, maxBytes int64, maxBatches int,
+	minBackoff time.Duration, maxBackoff time.Duration) (*SpoolWriter, error) {
+	if dir == "" {
+		return nil, errors.New("A spool directory is required")
+	}
+	if maxBytes < 1 {
+		maxBytes = DefaultSpoolMaxBytes
+	}
+	if minBackoff < 1 {
+		minBackoff = DefaultSpoolMinBackoff
+	}
+	if maxBackoff < 1 {
+		maxBackoff = DefaultSpoolMaxBackoff
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	labels := Tags{"spool": dir}
+	s := &SpoolWriter{
+		writer:     w,
+		dir:        dir,
+		maxBytes:   maxBytes,
+		maxBatches: maxBatches,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		wake:       make(chan bool, 1),
+		stop:       make(chan bool),
+		done:       make(chan bool),
+		metrics: spoolMetrics{
+			batches: DefaultMetrics.Gauge("llama_spool_batches",
+				"Batches waiting in the spool to be written.", labels),
+			bytes: DefaultMetrics.Gauge("llama_spool_bytes",
+				"Size of the batches waiting in the spool.", labels),
+			spooled: DefaultMetrics.Counter("llama_spool_spooled_batches_total",
+				"Batches saved to the spool.", labels),
+			replayed: DefaultMetrics.Counter("llama_spool_replayed_batches_total",
+				"Spooled batches successfully written.", labels),
+			dropped: DefaultMetrics.Counter("llama_spool_dropped_batches_total",
+				"Batches dropped because the spool was full or they were unreadable.", labels),
+		},
+	}
+	err = s.load()
+	if err != nil {
+		return nil, err
+	}
+	if len(s.entries) > 0 {
+		s.wake <- true
+	}
+	return s, nil
+}
+
+// newSpoolWriterFromOptions wraps w in a SpoolWriter if a `spool_dir` is
+// included in the options, and starts it running.
+func newSpoolWriterFromOptions(w Writer, opts WriterOptions) (Writer, error) {
+	dir := opts.String("spool_dir", "")
+	if dir == "" {
+		return w, nil
+	}
+	maxBytes, err := opts.Int("spool_max_bytes", DefaultSpoolMaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	maxBatches, err := opts.Int("spool_max_batches", 0)
+	if err != nil {
+		return nil, err
+	}
+	minBackoff, err := opts.Duration("spool_min_backoff", DefaultSpoolMinBackoff)
+	if err != nil {
+		return nil, err
+	}
+	maxBackoff, err := opts.Duration("spool_max_backoff", DefaultSpoolMaxBackoff)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewSpoolWriter(w, dir, maxBytes, int(maxBatches), minBackoff, maxBackoff)
+	if err != nil {
+		return nil, err
+	}
+	s.Run()
+	return s, nil
+}
@@ -0,0 +1,188 @@
+package llama
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestSpoolWriter(t *testing.T, w Writer, maxBytes int64, maxBatches int) (*SpoolWriter, string) {
+	dir, err := ioutil.TempDir("", "llama-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSpoolWriter(w, dir, maxBytes, maxBatches, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+func spoolTestPoints(id int64) Points {
+	return Points{DataPoint{
+		Fields:      map[string]IDBFloat64{"loss": 0.5},
+		Tags:        Tags{"src_ip": "10.0.0.1"},
+		Measurement: "raw",
+		IntervalID:  id,
+	}}
+}
+
+func TestSpoolWriterReplay(t *testing.T) {
+	mock := &MockWriter{err: errors.New("down")}
+	s, dir := newTestSpoolWriter(t, mock, 0, 0)
+	defer os.RemoveAll(dir)
+	for id := int64(1); id <= 3; id++ {
+		err := s.BatchWrite(spoolTestPoints(id))
+		if err != nil {
+			t.Fatal("Expected the batch to be spooled, got", err)
+		}
+	}
+	if batches, _ := s.Depth(); batches != 3 {
+		t.Fatal("Expected 3 spooled batches, got", batches)
+	}
+	// Still failing, so nothing is replayed and the backoff starts
+	s.replay()
+	if batches, _ := s.Depth(); batches != 3 || s.backoff != DefaultSpoolMinBackoff {
+		t.Error("Expected nothing replayed and the minimum backoff, got", batches, s.backoff)
+	}
+	s.replay()
+	if s.backoff != 2*DefaultSpoolMinBackoff {
+		t.Error("Expected the backoff to double, got", s.backoff)
+	}
+	// Once recovered, batches are replayed in order and the backoff reset
+	mock.err = nil
+	s.replay()
+	if batches, size := s.Depth(); batches != 0 || size != 0 {
+		t.Error("Expected the spool to be empty, got", batches, size)
+	}
+	if s.backoff != 0 {
+		t.Error("Expected the backoff to reset, got", s.backoff)
+	}
+	if len(mock.points) != 3 {
+		t.Fatal("Expected 3 points replayed, got", len(mock.points))
+	}
+	for i, dp := range mock.points {
+		if dp.IntervalID != int64(i+1) {
+			t.Error("Replayed out of order:", mock.points)
+		}
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 0 {
+		t.Error("Expected spool files to be removed, found", len(files))
+	}
+	// With nothing spooled, writes go straight through
+	err := s.BatchWrite(spoolTestPoints(4))
+	if err != nil || len(mock.points) != 4 {
+		t.Error("Expected a direct write, got", len(mock.points), err)
+	}
+	HandleMinorError(s.Close())
+	if !mock.closed {
+		t.Error("Expected the underlying writer to be closed")
+	}
+}
+
+func TestSpoolWriterDropOldest(t *testing.T) {
+	mock := &MockWriter{err: errors.New("down")}
+	s, dir := newTestSpoolWriter(t, mock, 0, 2)
+	defer os.RemoveAll(dir)
+	for id := int64(1); id <= 3; id++ {
+		HandleMinorError(s.BatchWrite(spoolTestPoints(id)))
+	}
+	if batches, _ := s.Depth(); batches != 2 {
+		t.Fatal("Expected the spool to be limited to 2 batches, got", batches)
+	}
+	mock.err = nil
+	s.replay()
+	if len(mock.points) != 2 || mock.points[0].IntervalID != 2 {
+		t.Error("Expected the oldest batch to be dropped, got", mock.points)
+	}
+
+	// Limited by size, a single batch too large is refused
+	small, smallDir := newTestSpoolWriter(t, &MockWriter{err: errors.New("down")}, 10, 0)
+	defer os.RemoveAll(smallDir)
+	err := small.BatchWrite(spoolTestPoints(1))
+	if err == nil {
+		t.Error("Expected an error for a batch larger than the spool")
+	}
+}
+
+func TestSpoolWriterLoad(t *testing.T) {
+	mock := &MockWriter{err: errors.New("down")}
+	s, dir := newTestSpoolWriter(t, mock, 0, 0)
+	defer os.RemoveAll(dir)
+	HandleMinorError(s.BatchWrite(spoolTestPoints(1)))
+	HandleMinorError(s.BatchWrite(spoolTestPoints(2)))
+	HandleMinorError(s.Close())
+	// Leftovers that aren't batches are ignored
+	err := ioutil.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// And batches that were never completely written are removed
+	tmp := filepath.Join(dir, "00000000000000000005"+spoolSuffix+spoolTmpSuffix)
+	err = ioutil.WriteFile(tmp, []byte("[{"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock = &MockWriter{}
+	s, err = NewSpoolWriter(mock, dir, 0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batches, _ := s.Depth(); batches != 2 {
+		t.Fatal("Expected 2 batches loaded from disk, got", batches)
+	}
+	if fileExists(tmp) {
+		t.Error("Expected the partial batch to be removed")
+	}
+	HandleMinorError(s.BatchWrite(spoolTestPoints(3)))
+	s.replay()
+	if len(mock.points) != 3 || mock.points[0].IntervalID != 1 || mock.points[2].IntervalID != 3 {
+		t.Error("Expected loaded batches replayed before new ones, got", mock.points)
+	}
+}
+
+func TestNewWriterSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llama-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := NewWriter(WriterConfig{Type: "file", Options: WriterOptions{
+		"path":      filepath.Join(dir, "points"),
+		"spool_dir": filepath.Join(dir, "spool"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, ok := w.(*SpoolWriter); !ok {
+		t.Errorf("Expected a SpoolWriter, got %T", w)
+	}
+	_, err = NewWriter(WriterConfig{Type: "file", Options: WriterOptions{
+		"path":            filepath.Join(dir, "points"),
+		"spool_dir":       filepath.Join(dir, "spool2"),
+		"spool_max_bytes": "lots",
+	}})
+	if err == nil {
+		t.Error("Expected an error for an invalid spool option")
+	}
+}
+
+func TestNewWritersSharedSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llama-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	_, err = NewWriters([]WriterConfig{
+		{Type: "influxdb", Options: WriterOptions{"spool_dir": dir}},
+		{Type: "http", Options: WriterOptions{"url": "http://localhost", "spool_dir": dir + "/"}},
+	})
+	if err == nil {
+		t.Error("Expected an error for writers sharing a spool_dir")
+	}
+}
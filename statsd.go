@@ -0,0 +1,113 @@
+// Writer for StatsD, sending each field as a gauge.
+package llama
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultStatsdPacketSize keeps packets within a typical Ethernet MTU, once
+// IP and UDP headers are included.
+const DefaultStatsdPacketSize = 1432
+
+// statsdGauges encodes the values as StatsD gauges, split into packets no
+// larger than `size` (unless a single gauge is larger).
+//
+// StatsD treats gauges with a leading sign as relative changes, so negative
+// values are sent as a reset to 0 followed by the change.
+func statsdGauges(values []metricValue, size int) [][]byte {
+	var packets [][]byte
+	var buf bytes.Buffer
+	for _, v := range values {
+		var line string
+		value := strconv.FormatFloat(v.value, 'f', -1, 64)
+		if v.value < 0 {
+			line = fmt.Sprintf("%s:0|g\n%s:%s|g\n", v.path, v.path, value)
+		} else {
+			line = fmt.Sprintf("%s:%s|g\n", v.path, value)
+		}
+		if buf.Len() > 0 && buf.Len()+len(line) > size {
+			packets = append(packets, append([]byte(nil), buf.Bytes()...))
+			buf.Reset()
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		packets = append(packets, buf.Bytes())
+	}
+	return packets
+}
+
+// StatsdWriter is used for writing datapoints to StatsD as gauges over UDP
+type StatsdWriter struct {
+	addr       string
+	template   *MetricTemplate
+	packetSize int
+}
+
+// BatchWrite will send the points to StatsD in as few packets as possible
+//
+// Since this is UDP, success only means the packets were sent.
+func (w *StatsdWriter) BatchWrite(points Points) error {
+	values := metricValues(w.template, points, time.Now())
+	conn, err := net.Dial("udp", w.addr)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to StatsD: %v", err)
+	}
+	defer conn.Close()
+	for _, packet := range statsdGauges(values, w.packetSize) {
+		_, err = conn.Write(packet)
+		if err != nil {
+			return fmt.Errorf("Failed to write batch: %v", err)
+		}
+	}
+	return nil
+}
+
+// Close does nothing, since connections are only held during writes
+func (w *StatsdWriter) Close() error {
+	return nil
+}
+
+// Health checks that the StatsD address can be resolved. There's no way to
+// tell if anything is listening over UDP.
+func (w *StatsdWriter) Health() error {
+	_, err := net.ResolveUDPAddr("udp", w.addr)
+	return err
+}
+
+// NewStatsdWriter provides a client for writing LLAMA datapoints to StatsD
+// at `addr` (host:port). `packetSize` defaults to DefaultStatsdPacketSize.
+func NewStatsdWriter(addr string, template string, packetSize int) (*StatsdWriter, error) {
+	if addr == "" {
+		return nil, errors.New("A StatsD address is required")
+	}
+	t, err := NewMetricTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	if packetSize <= 0 {
+		packetSize = DefaultStatsdPacketSize
+	}
+	log.Println("Creating StatsD writer for", addr)
+	return &StatsdWriter{addr: addr, template: t, packetSize: packetSize}, nil
+}
+
+// newStatsdWriterFromOptions creates a StatsdWriter from WriterOptions.
+func newStatsdWriterFromOptions(opts WriterOptions) (Writer, error) {
+	size, err := opts.Int("packet_size", DefaultStatsdPacketSize)
+	if err != nil {
+		return nil, err
+	}
+	return NewStatsdWriter(opts.String("address", "127.0.0.1:8125"),
+		opts.String("template", ""), int(size))
+}
+
+func init() {
+	RegisterWriter("statsd", newStatsdWriterFromOptions)
+}
@@ -0,0 +1,285 @@
+// Native JSON summaries, with filtering for querying the collector directly.
+package llama
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TaggedSummary is the full Summary for a path, along with its tags.
+type TaggedSummary struct {
+	SrcIP         string    `json:"src_ip"`
+	SrcPort       int       `json:"src_port"`
+	DstIP         string    `json:"dst_ip"`
+	DstPort       int       `json:"dst_port"`
+	Proto         string    `json:"proto"`
+	RTTAvg        float64   `json:"rtt_avg"`
+	RTTMin        float64   `json:"rtt_min"`
+	RTTMax        float64   `json:"rtt_max"`
+	Sent          int       `json:"sent"`
+	Lost          int       `json:"lost"`
+	Loss          float64   `json:"loss"`
+	LossEpisodes  int       `json:"loss_episodes"`
+	LossBurstMax  int       `json:"loss_burst_max"`
+	LossBurstAvg  float64   `json:"loss_burst_avg"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	IntervalID    int64     `json:"interval_id"`
+	Scored        bool      `json:"scored"`
+	RTTBaseline   float64   `json:"rtt_baseline,omitempty"`
+	RTTDeviation  float64   `json:"rtt_deviation,omitempty"`
+	RTTAnomalous  bool      `json:"rtt_anomalous,omitempty"`
+	LossBaseline  float64   `json:"loss_baseline,omitempty"`
+	LossAnomaly   float64   `json:"loss_anomaly,omitempty"`
+	LossAnomalous bool      `json:"loss_anomalous,omitempty"`
+	Tags          Tags      `json:"tags"`
+	srcIP         net.IP
+	dstIP         net.IP
+}
+
+// NewTaggedSummary provides a TaggedSummary for s, with the tags for its
+// destination from t.
+func NewTaggedSummary(s *Summary, t TagSet) *TaggedSummary {
+	ts := &TaggedSummary{
+		RTTAvg:        s.RTTAvg,
+		RTTMin:        s.RTTMin,
+		RTTMax:        s.RTTMax,
+		Sent:          s.Sent,
+		Lost:          s.Lost,
+		Loss:          s.Loss,
+		LossEpisodes:  s.LossEpisodes,
+		LossBurstMax:  s.LossBurstMax,
+		LossBurstAvg:  s.LossBurstAvg,
+		Start:         s.TS,
+		End:           s.End,
+		IntervalID:    s.IntervalID,
+		Scored:        s.Scored,
+		RTTBaseline:   s.RTTBaseline,
+		RTTDeviation:  s.RTTDeviation,
+		RTTAnomalous:  s.RTTAnomalous,
+		LossBaseline:  s.LossBaseline,
+		LossAnomaly:   s.LossAnomaly,
+		LossAnomalous: s.LossAnomalous,
+		//nolint:gosimple
+		Tags: make(Tags, 0), // To avoid JSON issues with nil
+	}
+	if s.Pd != nil {
+		ts.SrcIP = s.Pd.SrcIP.String()
+		ts.SrcPort = s.Pd.SrcPort
+		ts.DstIP = s.Pd.DstIP.String()
+		ts.DstPort = s.Pd.DstPort
+		ts.Proto = s.Pd.Proto
+		ts.srcIP = s.Pd.SrcIP
+		ts.dstIP = s.Pd.DstIP
+		for k, v := range t[ts.DstIP] {
+			ts.Tags[k] = v
+		}
+	}
+	return ts
+}
+
+// TaggedSummaries converts the summaries to TaggedSummaries with the current
+// tags.
+func (s *SharedTagSet) TaggedSummaries(summaries []*Summary) []*TaggedSummary {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	//nolint:gosimple
+	tagged := make([]*TaggedSummary, 0) // To avoid JSON issues with nil
+	for _, summary := range summaries {
+		tagged = append(tagged, NewTaggedSummary(summary, s.ts))
+	}
+	return tagged
+}
+
+// IntervalSummaries are the TaggedSummaries for an interval, as streamed by
+// the API.
+type IntervalSummaries struct {
+	ID        int64            `json:"id"`
+	Start     time.Time        `json:"start"`
+	End       time.Time        `json:"end"`
+	Summaries []*TaggedSummary `json:"summaries"`
+}
+
+// summarySortKeys are the values TaggedSummaries can be sorted by, and how
+// to compare them.
+var summarySortKeys = map[string]func(a, b *TaggedSummary) bool{
+	"rtt_avg":        func(a, b *TaggedSummary) bool { return a.RTTAvg < b.RTTAvg },
+	"rtt_min":        func(a, b *TaggedSummary) bool { return a.RTTMin < b.RTTMin },
+	"rtt_max":        func(a, b *TaggedSummary) bool { return a.RTTMax < b.RTTMax },
+	"loss":           func(a, b *TaggedSummary) bool { return a.Loss < b.Loss },
+	"lost":           func(a, b *TaggedSummary) bool { return a.Lost < b.Lost },
+	"sent":           func(a, b *TaggedSummary) bool { return a.Sent < b.Sent },
+	"loss_burst_max": func(a, b *TaggedSummary) bool { return a.LossBurstMax < b.LossBurstMax },
+	"src_ip":         func(a, b *TaggedSummary) bool { return a.SrcIP < b.SrcIP },
+	"dst_ip":         func(a, b *TaggedSummary) bool { return a.DstIP < b.DstIP },
+}
+
+// ipFilter matches an IP exactly, or within a CIDR.
+type ipFilter struct {
+	ip  net.IP
+	net *net.IPNet
+}
+
+func (f *ipFilter) matches(ip net.IP) bool {
+	if f.net != nil {
+		return f.net.Contains(ip)
+	}
+	return f.ip.Equal(ip)
+}
+
+// parseIPFilter parses an IP or CIDR.
+func parseIPFilter(value string) (*ipFilter, error) {
+	if strings.Contains(value, "/") {
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		return &ipFilter{net: ipNet}, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("Invalid IP: %s", value)
+	}
+	return &ipFilter{ip: ip}, nil
+}
+
+// SummaryQuery filters, sorts, and limits TaggedSummaries.
+type SummaryQuery struct {
+	Interval   int64 // Latest if 0
+	srcIP      *ipFilter
+	dstIP      *ipFilter
+	minLoss    float64
+	tags       map[string][]string // Any of the values for each tag
+	sortKey    string
+	descending bool
+	limit      int // No limit if 0
+}
+
+// Reserved query parameters, with everything else being a tag
+const (
+	summaryQueryInterval = "interval"
+	summaryQuerySrcIP    = "src_ip"
+	summaryQueryDstIP    = "dst_ip"
+	summaryQueryMinLoss  = "min_loss"
+	summaryQuerySort     = "sort"
+	summaryQueryLimit    = "limit"
+)
+
+// ParseSummaryQuery parses query parameters into a SummaryQuery:
+//
+//   - `interval`: The interval ID, instead of the latest
+//   - `src_ip`, `dst_ip`: An IP or CIDR the path's IPs must match
+//   - `min_loss`: The minimum loss, in percent
+//   - `sort`: A field to sort by, descending if prefixed with `-`
+//   - `limit`: The max summaries to provide
+//
+// Any other parameter is a tag which must match. If a tag is provided more
+// than once, any of the values match.
+func ParseSummaryQuery(values url.Values) (*SummaryQuery, error) {
+	q := &SummaryQuery{tags: make(map[string][]string)}
+	var err error
+	for key, vals := range values {
+		value := vals[len(vals)-1]
+		switch key {
+		case summaryQueryInterval:
+			q.Interval, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid interval id: %s", value)
+			}
+		case summaryQuerySrcIP:
+			q.srcIP, err = parseIPFilter(value)
+		case summaryQueryDstIP:
+			q.dstIP, err = parseIPFilter(value)
+		case summaryQueryMinLoss:
+			q.minLoss, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid min_loss: %s", value)
+			}
+		case summaryQuerySort:
+			q.sortKey = strings.TrimPrefix(value, "-")
+			q.descending = strings.HasPrefix(value, "-")
+			if _, found := summarySortKeys[q.sortKey]; !found {
+				return nil, fmt.Errorf("Can't sort by: %s", q.sortKey)
+			}
+		case summaryQueryLimit:
+			q.limit, err = strconv.Atoi(value)
+			if err != nil || q.limit < 0 {
+				return nil, fmt.Errorf("Invalid limit: %s", value)
+			}
+		default:
+			q.tags[key] = vals
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// matches determines if the summary passes all of the filters.
+func (q *SummaryQuery) matches(ts *TaggedSummary) bool {
+	if q.srcIP != nil && !q.srcIP.matches(ts.srcIP) {
+		return false
+	}
+	if q.dstIP != nil && !q.dstIP.matches(ts.dstIP) {
+		return false
+	}
+	if ts.Loss < q.minLoss {
+		return false
+	}
+	for tag, values := range q.tags {
+		value, found := ts.Tags[tag]
+		if !found || !containsString(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString determines if s is in values.
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply filters the summaries, and then sorts and limits them. They're
+// ordered by source and then destination IP before sorting, so responses are
+// consistent.
+func (q *SummaryQuery) Apply(summaries []*TaggedSummary) []*TaggedSummary {
+	//nolint:gosimple
+	matched := make([]*TaggedSummary, 0) // To avoid JSON issues with nil
+	for _, ts := range summaries {
+		if q.matches(ts) {
+			matched = append(matched, ts)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.SrcIP != b.SrcIP {
+			return a.SrcIP < b.SrcIP
+		}
+		return a.DstIP < b.DstIP
+	})
+	// Stable, so ties stay in the default order
+	if less, found := summarySortKeys[q.sortKey]; found {
+		sort.SliceStable(matched, func(i, j int) bool {
+			if q.descending {
+				return less(matched[j], matched[i])
+			}
+			return less(matched[i], matched[j])
+		})
+	}
+	if q.limit > 0 && len(matched) > q.limit {
+		matched = matched[:q.limit]
+	}
+	return matched
+}
@@ -0,0 +1,105 @@
+package llama
+
+import (
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// testTaggedSummaries provides summaries for three paths, with the west
+// one being the lossiest.
+func testTaggedSummaries() []*TaggedSummary {
+	ts := NewSharedTagSet(TagSet{
+		"10.0.1.1": Tags{"dst_region": "east"},
+		"10.0.2.1": Tags{"dst_region": "west"},
+		"10.0.3.1": Tags{"dst_region": "north"},
+	})
+	summary := func(dst string, loss float64, rtt float64) *Summary {
+		return &Summary{
+			Pd:     &PathDist{SrcIP: net.ParseIP("10.0.0.1"), DstIP: net.ParseIP(dst), Proto: "udp"},
+			Loss:   loss,
+			RTTAvg: rtt,
+			RTTMin: rtt / 2,
+			RTTMax: rtt * 2,
+		}
+	}
+	return ts.TaggedSummaries([]*Summary{
+		summary("10.0.2.1", 5, 30),
+		summary("10.0.1.1", 0, 10),
+		summary("10.0.3.1", 1, 20),
+	})
+}
+
+func TestSummaryQuery(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected []string // Destination IPs, in order
+	}{
+		{"", []string{"10.0.1.1", "10.0.2.1", "10.0.3.1"}},
+		{"dst_region=west", []string{"10.0.2.1"}},
+		{"dst_region=west&dst_region=east", []string{"10.0.1.1", "10.0.2.1"}},
+		{"dst_region=south", []string{}},
+		{"dst_ip=10.0.3.1", []string{"10.0.3.1"}},
+		{"dst_ip=10.0.0.0/23", []string{"10.0.1.1"}},
+		{"src_ip=10.0.0.2", []string{}},
+		{"min_loss=1", []string{"10.0.2.1", "10.0.3.1"}},
+		{"sort=-loss", []string{"10.0.2.1", "10.0.3.1", "10.0.1.1"}},
+		{"sort=rtt_avg&limit=2", []string{"10.0.1.1", "10.0.3.1"}},
+	}
+	for _, c := range cases {
+		values, _ := url.ParseQuery(c.query)
+		q, err := ParseSummaryQuery(values)
+		if err != nil {
+			t.Error("Failed to parse", c.query, "-", err)
+			continue
+		}
+		var dsts []string
+		for _, ts := range q.Apply(testTaggedSummaries()) {
+			dsts = append(dsts, ts.DstIP)
+		}
+		if len(dsts) != len(c.expected) {
+			t.Error("Expected", c.expected, "for", c.query, "got", dsts)
+			continue
+		}
+		for i := range dsts {
+			if dsts[i] != c.expected[i] {
+				t.Error("Expected", c.expected, "for", c.query, "got", dsts)
+				break
+			}
+		}
+	}
+	for _, bad := range []string{"min_loss=x", "sort=nope", "limit=-1", "dst_ip=nope", "interval=x"} {
+		values, _ := url.ParseQuery(bad)
+		_, err := ParseSummaryQuery(values)
+		if err == nil {
+			t.Error("Expected an error for", bad)
+		}
+	}
+}
+
+func TestSummariesHandler(t *testing.T) {
+	api := newTestAPI()
+	rw := httptest.NewRecorder()
+	api.SummariesHandler(rw, httptest.NewRequest("GET", "/summaries", nil))
+	if rw.Code != 200 {
+		t.Fatal("Expected 200, got", rw.Code)
+	}
+	var summaries []*TaggedSummary
+	err := json.Unmarshal(rw.Body.Bytes(), &summaries)
+	if err != nil {
+		t.Fatal("Failed to parse response:", err)
+	}
+	if summaries == nil {
+		t.Error("Expected an empty list, not null:", rw.Body.String())
+	}
+
+	for query, code := range map[string]int{"interval=100": 200, "interval=1": 404, "limit=x": 400} {
+		rw = httptest.NewRecorder()
+		api.SummariesHandler(rw, httptest.NewRequest("GET", "/summaries?"+query, nil))
+		if rw.Code != code {
+			t.Error("Expected", code, "for", query, "got", rw.Code)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"sync"
 	"time"
 )
@@ -17,7 +18,38 @@ type Summary struct {
 	Sent   int
 	Lost   int
 	Loss   float64
-	TS     time.Time // No longer used, but keeping for posterity
+	// Loss run-length statistics, based on the order probes were sent
+	LossEpisodes int       // Number of separate runs of consecutive losses
+	LossBurstMax int       // Longest run of consecutive losses
+	LossBurstAvg float64   // Mean length of a run of consecutive losses
+	TS           time.Time // Aligned start of the interval summarized
+	End          time.Time // Aligned end of the interval summarized
+	IntervalID   int64     // ID of the interval summarized
+	// Comparison against the path's baseline, only if Scored
+	Scored        bool    // If the baseline was established enough to compare
+	RTTBaseline   float64 // Typical RTTAvg for the path
+	RTTDeviation  float64 // Ratio of RTTAvg to RTTBaseline
+	RTTAnomalous  bool    // If RTTDeviation exceeded the threshold
+	LossBaseline  float64 // Typical Loss for the path
+	LossAnomaly   float64 // Ratio of Loss to LossBaseline, with a floor
+	LossAnomalous bool    // If LossAnomaly exceeded the threshold
+}
+
+// DefaultHistorySize is the number of summarized intervals retained when a
+// size isn't otherwise provided.
+const DefaultHistorySize = 10
+
+// DefaultSummarizerMargin is added on top of the probe timeouts when deciding
+// how long to hold an interval open for outstanding results.
+const DefaultSummarizerMargin = time.Second
+
+// Interval represents a single summarization period and the summaries
+// that were produced for it.
+type Interval struct {
+	ID        int64     // Number of whole intervals since the Unix epoch
+	Start     time.Time // Aligned start of the interval
+	End       time.Time // Aligned end of the interval
+	Summaries []*Summary
 }
 
 // Summarizer stores results and summarizes them at intervals.
@@ -25,12 +57,22 @@ type Summarizer struct {
 	// NOTE(dmar): For posterity, use value references for mutexes, not pointers
 	CMutex   sync.RWMutex
 	Cache    []*Summary
+	History  []*Interval // Oldest first, and guarded by CMutex as well
 	in       chan *Result
 	stop     chan bool
 	mutex    sync.RWMutex
-	results  map[string][]*Result
-	interval time.Duration // Keep this, or just pass to `Run`?
+	results  map[int64]map[string][]*Result // Keyed by interval ID, then path
+	last     int64                          // Latest interval ID summarized
+	late     int64                          // Results discarded for arriving late
+	interval time.Duration                  // Keep this, or just pass to `Run`?
+	delay    time.Duration                  // How long to hold intervals open
+	history  int                            // How many intervals to keep in History
 	ticker   *time.Ticker
+	baseline *Baseline // Optional, for scoring summaries against the norm
+	// Channels which are provided each newly summarized Interval
+	subscribers []chan *Interval
+	duration    *Gauge   // How long the last summarization took
+	lateTotal   *Counter // All results discarded for arriving late
 }
 
 // Run causes the summarizer to infinitely wait for new results, store them,
@@ -50,18 +92,19 @@ func (s *Summarizer) Run() {
 // waitToSummarize will wait until the next full even interval has passed
 // and then summarize the stored results into a cache.
 //
-// The sumarization will happen a even intervals. It also waits until at least
-// a full interval has passed before summarize. So the first set of summaries
-// will likely emcompass more results than normal.
+// The sumarization will happen a even intervals, offset by the delay. That
+// gives probes sent at the end of an interval time to complete or expire
+// before the interval is summarized.
 func (s *Summarizer) waitToSummarize() {
-	// Delay initially so it starts on an even interval
+	// Delay initially so it starts on an even interval, plus the delay
 	i := int64(s.interval)
+	offset := int64(s.delay) % i
 	// Sleep until the first interval
-	time.Sleep(time.Duration(i - (time.Now().UnixNano() % i)))
+	time.Sleep(time.Duration(i - ((time.Now().UnixNano() - offset) % i)))
 	// This just starts the ticker, but doesn't actually start a summary cycle
 	// immediately. This allows at least a full cycle of results to populate
-	// before the first summarization. So the first summarization will likely
-	// cover more results than subsequent ones.
+	// before the first summarization. The first summarization will likely
+	// cover a partial interval, since it started part way through.
 	log.Printf("Starting ticker for Summarizer at %v intervals\n", s.interval)
 	s.ticker = time.NewTicker(s.interval)
 	// Now loop infinitely waiting for ticks
@@ -69,35 +112,216 @@ func (s *Summarizer) waitToSummarize() {
 		select {
 		case <-s.stop:
 			return
-		case <-s.ticker.C:
+		case tick := <-s.ticker.C:
 			log.Println("Summarizing results")
-			s.summarize()
+			s.summarize(tick)
 			log.Println("Summarization complete")
 		}
 	}
 }
 
-// summarize pull out the current results, resetting the Summarizer's results,
-// and performing summarizations of all the extracted results.
-func (s *Summarizer) summarize() {
-	// TODO(dmar): May want to time this in the future, and keep track of it
+// summarize pulls out the results for every interval that has been held open
+// for long enough, and performs summarizations of all the extracted results.
+//
+// `now` is when the summarization was triggered. Intervals that ended at
+// least the Summarizer's delay before then are summarized, with the latest of
+// them replacing the Cache. Results for those intervals which arrive later
+// are discarded.
+func (s *Summarizer) summarize(now time.Time) {
+	start := time.Now()
+	defer func() { s.duration.Set(time.Since(start).Seconds()) }()
+	// The ticker may fire slightly after the boundary, which truncating
+	// here accounts for.
+	lastID := s.intervalID(now.Add(-s.delay).UnixNano()) - 1
 	s.mutex.Lock()
-	// Extract the results and reset the map
-	results := s.results
-	log.Println("Found", len(results), "results to summarize")
-	s.results = make(map[string][]*Result)
+	// Extract the results for intervals that are ready
+	ready := make(map[int64]map[string][]*Result)
+	for id, results := range s.results {
+		if id <= lastID {
+			ready[id] = results
+			delete(s.results, id)
+		}
+	}
+	if lastID > s.last {
+		s.last = lastID
+	}
+	late := s.late
+	s.late = 0
 	s.mutex.Unlock()
+	if late > 0 {
+		log.Println("Discarded", late, "results for intervals already summarized")
+		s.lateTotal.Add(uint64(late))
+	}
+	// Make sure the latest interval is always summarized, even when empty
+	if _, found := ready[lastID]; !found {
+		ready[lastID] = make(map[string][]*Result)
+	}
+	// Summarize oldest first, so they're added to the history in order
+	ids := make([]int64, 0, len(ready))
+	for id := range ready {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	intervals := make([]*Interval, 0, len(ids))
+	for _, id := range ids {
+		log.Println("Found", len(ready[id]), "paths to summarize for interval", id)
+		intervals = append(intervals, s.summarizeInterval(id, ready[id]))
+	}
+	// Lock and swap the existing cache out for the new summaries
+	s.CMutex.Lock()
+	for _, interval := range intervals {
+		s.addInterval(interval)
+	}
+	s.Cache = intervals[len(intervals)-1].Summaries
+	subscribers := s.subscribers
+	s.CMutex.Unlock()
+	// Let anything else interested know about the new intervals
+	for _, interval := range intervals {
+		publishInterval(interval, subscribers)
+	}
+}
+
+// publishInterval provides the Interval to each of the subscribers.
+//
+// To avoid a slow subscriber holding up summarization, the Interval is
+// dropped for any subscriber that isn't ready to receive it.
+func publishInterval(interval *Interval, subscribers []chan *Interval) {
+	for _, sub := range subscribers {
+		select {
+		case sub <- interval:
+		default:
+			log.Println("Subscriber not keeping up, dropped interval", interval.ID)
+		}
+	}
+}
+
+// SetBaseline provides a Baseline which summaries are scored against.
+//
+// This must be done before running.
+func (s *Summarizer) SetBaseline(b *Baseline) {
+	s.baseline = b
+}
+
+// Subscribe provides a channel which receives each Interval once it has been
+// summarized.
+func (s *Summarizer) Subscribe() chan *Interval {
+	sub := make(chan *Interval, DEFAULT_CHANNEL_SIZE)
+	s.CMutex.Lock()
+	s.subscribers = append(s.subscribers, sub)
+	s.CMutex.Unlock()
+	return sub
+}
+
+// Unsubscribe stops providing Intervals to a channel from Subscribe.
+//
+// The channel isn't closed, since an Interval may still be in the process of
+// being provided to it.
+func (s *Summarizer) Unsubscribe(sub chan *Interval) {
+	s.CMutex.Lock()
+	defer s.CMutex.Unlock()
+	// Copied, since the existing slice may be in use for publishing
+	subscribers := make([]chan *Interval, 0, len(s.subscribers))
+	for _, existing := range s.subscribers {
+		if existing != sub {
+			subscribers = append(subscribers, existing)
+		}
+	}
+	s.subscribers = subscribers
+}
+
+// summarizeInterval creates an Interval for the provided ID, containing
+// summaries for each set of results.
+func (s *Summarizer) summarizeInterval(id int64, results map[string][]*Result) *Interval {
+	interval := s.newInterval(id)
 	// Create a new cache for this batch of results
 	var newCache []*Summary
 	// Perform summaries and save to new cache
 	for _, results := range results {
 		summary := s.summarizeSet(results)
+		// Stamp with the interval bounds, so consumers have consistent
+		// timestamps regardless of when they retrieve the summary.
+		summary.TS = interval.Start
+		summary.End = interval.End
+		summary.IntervalID = interval.ID
 		newCache = append(newCache, summary)
 	}
-	// Lock and swap the existing cache out for the new summaries
-	s.CMutex.Lock()
-	s.Cache = newCache
-	s.CMutex.Unlock()
+	// Intervals are summarized in order, so baselines are as well
+	if s.baseline != nil {
+		s.baseline.Apply(interval.ID, newCache)
+	}
+	interval.Summaries = newCache
+	return interval
+}
+
+// intervalID provides the ID of the interval containing the provided time,
+// in nanoseconds since the Unix epoch.
+func (s *Summarizer) intervalID(ns int64) int64 {
+	return ns / int64(s.interval)
+}
+
+// newInterval creates an empty Interval for the provided ID, with bounds
+// aligned to the Summarizer's interval.
+func (s *Summarizer) newInterval(id int64) *Interval {
+	start := time.Unix(0, id*int64(s.interval))
+	return &Interval{
+		ID:    id,
+		Start: start,
+		End:   start.Add(s.interval),
+	}
+}
+
+// addInterval appends an Interval to the History, dropping the oldest
+// entries once there are more than the Summarizer is set to keep.
+//
+// CMutex must be held for writing when calling this.
+func (s *Summarizer) addInterval(interval *Interval) {
+	history := append(s.History, interval)
+	if len(history) > s.history {
+		// Copy so the dropped intervals can actually be released
+		history = append([]*Interval(nil), history[len(history)-s.history:]...)
+	}
+	s.History = history
+}
+
+// Interval provides the retained Interval matching the provided ID, and
+// whether or not it was found.
+func (s *Summarizer) Interval(id int64) (*Interval, bool) {
+	s.CMutex.RLock()
+	defer s.CMutex.RUnlock()
+	for _, interval := range s.History {
+		if interval.ID == id {
+			return interval, true
+		}
+	}
+	return nil, false
+}
+
+// Latest provides the most recently summarized Interval, and whether or not
+// there is one yet.
+func (s *Summarizer) Latest() (*Interval, bool) {
+	s.CMutex.RLock()
+	defer s.CMutex.RUnlock()
+	if len(s.History) == 0 {
+		return nil, false
+	}
+	return s.History[len(s.History)-1], true
+}
+
+// IntervalsSince provides all retained Intervals with an ID greater than the
+// one provided, oldest first.
+//
+// Intervals are not modified after being summarized, so they are safe to use
+// after being returned.
+func (s *Summarizer) IntervalsSince(id int64) []*Interval {
+	s.CMutex.RLock()
+	defer s.CMutex.RUnlock()
+	intervals := make([]*Interval, 0)
+	for _, interval := range s.History {
+		if interval.ID > id {
+			intervals = append(intervals, interval)
+		}
+	}
+	return intervals
 }
 
 // summarizeSet will return a Summary for a single set of Results, all of
@@ -112,12 +336,13 @@ func (s *Summarizer) summarizeSet(results []*Result) *Summary {
 	// This would fail if the results were empty, but then there shouldn't
 	// be any.
 	pd := results[0].Pd
-	// NOTE(dmar): If we need timestamps again, this is the place to add them.
-	// summary := &Summary{Pd: pd, TS: time.Now()}
+	// NOTE(dmar): Timestamps are based on the interval, and are applied in
+	//      `summarize` after this returns.
 	summary := &Summary{Pd: pd}
 	// Perform the calculations
 	CalcCounts(results, summary)
 	CalcLoss(summary)
+	CalcLossBursts(results, summary)
 	CalcRTT(results, summary)
 	return summary
 }
@@ -153,13 +378,32 @@ func (s *Summarizer) addResult(result *Result) {
 	// For now, just keying this on the src/dst IPs to avoid extra points.
 	// TODO(dmar): In the future, based on how the above todo turns out,
 	//      perhaps customize what fields are used/ignored.
-	key := fmt.Sprintf("src_%v->dst_%v", result.Pd.SrcIP, result.Pd.DstIP)
+	key := PathKey(result.Pd)
+	// Results belong to the interval they were sent in, not the one they
+	// happen to complete or expire in.
+	id := s.intervalID(int64(result.Sent))
 	s.mutex.Lock()
-	s.results[key] = append(s.results[key], result)
+	if id <= s.last {
+		// That interval was already summarized, so this is too late
+		s.late++
+		s.mutex.Unlock()
+		return
+	}
+	results, found := s.results[id]
+	if !found {
+		results = make(map[string][]*Result)
+		s.results[id] = results
+	}
+	results[key] = append(results[key], result)
 	// This is simple and frequent, so avoiding the defer overhead
 	s.mutex.Unlock()
 }
 
+// PathKey provides the key used to group results for the same path.
+func PathKey(pd *PathDist) string {
+	return fmt.Sprintf("src_%v->dst_%v", pd.SrcIP, pd.DstIP)
+}
+
 // Stop will stop the summarizer from receiving results or summarizing them.
 func (s *Summarizer) Stop() {
 	select {
@@ -172,14 +416,29 @@ func (s *Summarizer) Stop() {
 }
 
 // New returns a new Summarizer, based on the provided parameters.
-func NewSummarizer(in chan *Result, interval time.Duration) *Summarizer {
+//
+// `delay` is how long after an interval ends to wait before summarizing it,
+// and should be at least as long as it takes for probes to time out.
+// `history` is the number of summarized intervals to retain, and will use
+// DefaultHistorySize if less than 1.
+func NewSummarizer(in chan *Result, interval time.Duration,
+	delay time.Duration, history int) *Summarizer {
 	stop := make(chan bool)
-	results := make(map[string][]*Result)
+	results := make(map[int64]map[string][]*Result)
+	if history < 1 {
+		history = DefaultHistorySize
+	}
 	summarizer := &Summarizer{
 		in:       in,
 		stop:     stop,
 		results:  results,
 		interval: interval,
+		delay:    delay,
+		history:  history,
+		duration: DefaultMetrics.Gauge("llama_summarizer_duration_seconds",
+			"Time taken by the last summarization.", nil),
+		lateTotal: DefaultMetrics.Counter("llama_summarizer_late_results_total",
+			"Results discarded for arriving after their interval was summarized.", nil),
 	}
 	return summarizer
 }
@@ -276,6 +535,50 @@ func CalcLoss(summary *Summary) {
 	summary.Loss = (float64(summary.Lost) / float64(summary.Sent)) * 100.0
 }
 
+// CalcLossBursts will calculate the loss run-length statistics on the
+// provided summary, based on the provided results.
+//
+// Results are ordered by when they were sent, so that a run of consecutive
+// losses represents a period where nothing on the path made it through. Many
+// short runs point towards congestion, while a few long runs point towards
+// something like a reconvergence event.
+func CalcLossBursts(results []*Result, summary *Summary) {
+	// Results arrive in the order they completed or expired, which isn't the
+	// order they were sent. So sort a copy to avoid reordering the original.
+	ordered := make([]*Result, len(results))
+	copy(ordered, results)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Sent < ordered[j].Sent
+	})
+	episodes := 0
+	longest := 0
+	lost := 0
+	current := 0
+	for _, r := range ordered {
+		if !r.Lost {
+			current = 0
+			continue
+		}
+		// A loss following a success starts a new episode
+		if current == 0 {
+			episodes++
+		}
+		current++
+		lost++
+		if current > longest {
+			longest = current
+		}
+	}
+	summary.LossEpisodes = episodes
+	summary.LossBurstMax = longest
+	// If nothing was lost, there are no bursts to average
+	if episodes == 0 {
+		summary.LossBurstAvg = 0
+		return
+	}
+	summary.LossBurstAvg = float64(lost) / float64(episodes)
+}
+
 // NsToMs takes ns (nanoseconds) and converts it to milliseconds.
 func NsToMs(ns float64) float64 {
 	return ns / 1000000.0
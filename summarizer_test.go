@@ -26,26 +26,67 @@ func TestSummarize(t *testing.T) {
 	// With mocking, we could test this more completed, but for now, avoid
 	// also covering the other summarize steps
 	// Setup
-	s := Summarizer{}
-	results := make(map[string][]*Result)
+	s := Summarizer{interval: time.Second, history: 1}
+	results := make(map[int64]map[string][]*Result)
 	s.results = results
 	// Make sure the results got replaced after summarize
-	s.summarize()
+	s.summarize(time.Now())
 	if &s.results == &results {
 		t.Error("Results on summarizer not reset between runs")
 	}
+	// And that the interval was recorded
+	if len(s.History) != 1 {
+		t.Error("Expected 1 interval in history, got", len(s.History))
+	}
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	s := Summarizer{interval: time.Second, history: 2}
+	s.results = make(map[int64]map[string][]*Result)
+	// Slightly after the boundary, like the ticker would be
+	end := time.Unix(100, int64(5*time.Millisecond))
+	for i := 0; i < 3; i++ {
+		s.summarize(end.Add(time.Duration(i) * time.Second))
+	}
+	if len(s.History) != 2 {
+		t.Fatal("Expected 2 intervals in history, got", len(s.History))
+	}
+	// The oldest should have been dropped
+	first := s.History[0]
+	if first.ID != 100 {
+		t.Error("Expected oldest interval ID to be 100, got", first.ID)
+	}
+	if !first.Start.Equal(time.Unix(100, 0)) || !first.End.Equal(time.Unix(101, 0)) {
+		t.Error("Interval bounds not aligned. Got", first.Start, first.End)
+	}
+	// Lookups
+	if _, found := s.Interval(99); found {
+		t.Error("Interval 99 should have been dropped from history")
+	}
+	if interval, found := s.Interval(101); !found || interval.ID != 101 {
+		t.Error("Interval 101 should be in history")
+	}
+	if latest, found := s.Latest(); !found || latest.ID != 101 {
+		t.Error("Latest interval should be 101, got", latest)
+	}
+	since := s.IntervalsSince(100)
+	if len(since) != 1 || since[0].ID != 101 {
+		t.Error("Expected only interval 101 since 100, got", since)
+	}
+	if len(s.IntervalsSince(-1)) != 2 {
+		t.Error("Expected all intervals since -1")
+	}
 }
 
 func TestSummarizeSet(t *testing.T) {
 	s := Summarizer{}
 	// Create some fake results
-	key := "test"
-	s.results = make(map[string][]*Result)
-	s.results[key] = append(s.results[key], &Result{RTT: 1000000})
-	s.results[key] = append(s.results[key], &Result{Lost: true})
-	s.results[key] = append(s.results[key], &Result{RTT: 3000000})
+	var results []*Result
+	results = append(results, &Result{RTT: 1000000, Sent: 1})
+	results = append(results, &Result{Lost: true, Sent: 2})
+	results = append(results, &Result{RTT: 3000000, Sent: 3})
 	// Summarize
-	summary := s.summarizeSet(s.results[key])
+	summary := s.summarizeSet(results)
 	// Validate results
 	if summary.RTTAvg != 2.0 {
 		t.Error("RTTAvg bad. Got", summary.RTTAvg, "expected", 2.0)
@@ -66,12 +107,55 @@ func TestSummarizeSet(t *testing.T) {
 	if summary.Loss != expectedLoss {
 		t.Error("Loss bad. Got", summary.Loss, "expected", expectedLoss)
 	}
-	// NOTE(dmar): Keeping, because this code is still there but commented out.
-	//      However, we aren't setting this anymore, and explicitly leaving it
-	//      as zero.
-	// if summary.TS.IsZero() {
-	// 	t.Error("Summary TS was not initialized")
-	// }
+	if summary.LossEpisodes != 1 || summary.LossBurstMax != 1 {
+		t.Error("Loss bursts bad. Got", summary.LossEpisodes,
+			summary.LossBurstMax, "expected", 1, 1)
+	}
+	// Timestamps are applied by `summarize` based on the interval
+	if !summary.TS.IsZero() {
+		t.Error("Summary TS should be left for summarize to set")
+	}
+}
+
+func TestSummarizeTimestamps(t *testing.T) {
+	s := Summarizer{interval: 30 * time.Second, history: 1}
+	s.results = make(map[int64]map[string][]*Result)
+	s.addResult(&Result{Pd: &PathDist{}, Sent: uint64(45 * time.Second)})
+	// Ticks may arrive a little late
+	s.summarize(time.Unix(60, int64(time.Millisecond)))
+	if len(s.Cache) != 1 {
+		t.Fatal("Expected 1 summary, got", len(s.Cache))
+	}
+	summary := s.Cache[0]
+	if !summary.TS.Equal(time.Unix(30, 0)) {
+		t.Error("Expected TS to be the interval start, got", summary.TS)
+	}
+	if !summary.End.Equal(time.Unix(60, 0)) {
+		t.Error("Expected End to be the interval end, got", summary.End)
+	}
+	if summary.IntervalID != 1 {
+		t.Error("Expected IntervalID of 1, got", summary.IntervalID)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s := Summarizer{interval: time.Second, history: 1}
+	s.results = make(map[int64]map[string][]*Result)
+	sub := s.Subscribe()
+	s.summarize(time.Unix(10, 0))
+	select {
+	case interval := <-sub:
+		if interval.ID != 9 {
+			t.Error("Expected interval 9, got", interval.ID)
+		}
+	default:
+		t.Error("Subscriber wasn't provided the interval")
+	}
+	s.Unsubscribe(sub)
+	s.summarize(time.Unix(11, 0))
+	if len(sub) != 0 {
+		t.Error("Unsubscribed, but was still provided an interval")
+	}
 }
 
 func TestStore(t *testing.T) {
@@ -80,21 +164,76 @@ func TestStore(t *testing.T) {
 
 func TestAddResult(t *testing.T) {
 	// Mock
-	s := Summarizer{}
-	s.results = make(map[string][]*Result)
+	s := Summarizer{interval: time.Second}
+	s.results = make(map[int64]map[string][]*Result)
 	// Add a result
 	result := &Result{
-		Pd: &PathDist{},
+		Pd:   &PathDist{},
+		Sent: uint64(10500 * time.Millisecond),
 	}
 	s.addResult(result)
-	// Make sure the result exists
+	// Make sure the result exists, under the interval it was sent in
 	key := fmt.Sprintf("src_%v->dst_%v", result.Pd.SrcIP, result.Pd.DstIP)
-	if len(s.results[key]) != 1 {
-		t.Error("Results should contain one entry, but has", len(s.results[key]))
+	if len(s.results[10][key]) != 1 {
+		t.Error("Results should contain one entry, but has", len(s.results[10][key]))
 	}
-	if s.results[key][0] != result {
+	if s.results[10][key][0] != result {
 		t.Error("The entry in results doesn't match what was provided")
 	}
+	// Results for intervals already summarized should be discarded
+	s.last = 10
+	s.addResult(&Result{Pd: &PathDist{}, Sent: uint64(10 * time.Second)})
+	if len(s.results[10][key]) != 1 {
+		t.Error("Late result should have been discarded")
+	}
+	if s.late != 1 {
+		t.Error("Expected 1 late result, got", s.late)
+	}
+}
+
+func TestSummarizeBySendTime(t *testing.T) {
+	s := Summarizer{interval: 10 * time.Second, delay: 3 * time.Second, history: 5}
+	s.results = make(map[int64]map[string][]*Result)
+	// Sent at the end of interval 1, and lost, so it's only seen later
+	s.addResult(&Result{Pd: &PathDist{}, Sent: uint64(19 * time.Second), Lost: true})
+	// Sent in interval 2
+	s.addResult(&Result{Pd: &PathDist{}, Sent: uint64(21 * time.Second)})
+	// At the boundary, interval 1 is still being held open
+	s.summarize(time.Unix(20, 0))
+	if latest, _ := s.Latest(); latest.ID != 0 || len(latest.Summaries) != 0 {
+		t.Error("Interval 1 was summarized before the delay passed")
+	}
+	// After the delay, interval 1 gets its lost probe
+	s.summarize(time.Unix(23, 0))
+	latest, _ := s.Latest()
+	if latest.ID != 1 || len(latest.Summaries) != 1 {
+		t.Fatal("Expected interval 1 with 1 summary, got", latest.ID, latest.Summaries)
+	}
+	if latest.Summaries[0].Lost != 1 || latest.Summaries[0].Sent != 1 {
+		t.Error("Lost probe not attributed to the interval it was sent in")
+	}
+	// Interval 2 should still be waiting
+	if len(s.results[2]) != 1 {
+		t.Error("Results for interval 2 should still be held")
+	}
+	if len(s.Cache) != 1 {
+		t.Error("Cache should contain the summaries for interval 1")
+	}
+}
+
+func TestSummarizeCatchUp(t *testing.T) {
+	s := Summarizer{interval: 10 * time.Second, history: 5}
+	s.results = make(map[int64]map[string][]*Result)
+	s.addResult(&Result{Pd: &PathDist{}, Sent: uint64(15 * time.Second)})
+	s.addResult(&Result{Pd: &PathDist{}, Sent: uint64(25 * time.Second)})
+	// Both intervals are ready, so both should be summarized separately
+	s.summarize(time.Unix(30, 0))
+	if len(s.History) != 2 {
+		t.Fatal("Expected 2 intervals in history, got", len(s.History))
+	}
+	if s.History[0].ID != 1 || s.History[1].ID != 2 {
+		t.Error("Intervals not added in order:", s.History[0].ID, s.History[1].ID)
+	}
 }
 
 func TestSummarizerStop(t *testing.T) {
@@ -118,10 +257,16 @@ func TestNewSummarizer(t *testing.T) {
 	summarizer := NewSummarizer(
 		make(chan *Result),
 		time.Second,
+		time.Second,
+		0,
 	)
 	if summarizer == nil {
 		t.Error("Was unable to create a Summarizer")
 	}
+	// A history of 0 should fall back to the default
+	if summarizer.history != DefaultHistorySize {
+		t.Error("Expected default history size, got", summarizer.history)
+	}
 }
 
 func TestCalcRTT(t *testing.T) {
@@ -202,6 +347,63 @@ func TestCalcCounts(t *testing.T) {
 	}
 }
 
+func TestCalcLossBursts(t *testing.T) {
+	// No results, or no loss, should leave everything at zero
+	summary := &Summary{}
+	var results []*Result
+	CalcLossBursts(results, summary)
+	if summary.LossEpisodes != 0 || summary.LossBurstMax != 0 || summary.LossBurstAvg != 0 {
+		t.Error("Expected zero values for an empty set. Got",
+			summary.LossEpisodes, summary.LossBurstMax, summary.LossBurstAvg)
+	}
+	// Scattered drops are provided out of send order, as they would be
+	// when lost probes expire after later ones have completed.
+	summary = &Summary{}
+	results = []*Result{
+		&Result{Sent: 5, Lost: true},
+		&Result{Sent: 1, Lost: true},
+		&Result{Sent: 2},
+		&Result{Sent: 3, Lost: true},
+		&Result{Sent: 4},
+		&Result{Sent: 6},
+	}
+	CalcLossBursts(results, summary)
+	if summary.LossEpisodes != 3 {
+		t.Error("Expected 3 loss episodes, got", summary.LossEpisodes)
+	}
+	if summary.LossBurstMax != 1 {
+		t.Error("Expected longest burst of 1, got", summary.LossBurstMax)
+	}
+	if summary.LossBurstAvg != 1.0 {
+		t.Error("Expected mean burst of 1.0, got", summary.LossBurstAvg)
+	}
+	// The original ordering should be left alone
+	if results[0].Sent != 5 {
+		t.Error("Results were reordered in place")
+	}
+	// A single blackout plus a stray drop
+	summary = &Summary{}
+	results = []*Result{
+		&Result{Sent: 1},
+		&Result{Sent: 2, Lost: true},
+		&Result{Sent: 3, Lost: true},
+		&Result{Sent: 4, Lost: true},
+		&Result{Sent: 5, Lost: true},
+		&Result{Sent: 6},
+		&Result{Sent: 7, Lost: true},
+	}
+	CalcLossBursts(results, summary)
+	if summary.LossEpisodes != 2 {
+		t.Error("Expected 2 loss episodes, got", summary.LossEpisodes)
+	}
+	if summary.LossBurstMax != 4 {
+		t.Error("Expected longest burst of 4, got", summary.LossBurstMax)
+	}
+	if summary.LossBurstAvg != 2.5 {
+		t.Error("Expected mean burst of 2.5, got", summary.LossBurstAvg)
+	}
+}
+
 func TestCalcLoss(t *testing.T) {
 	// These are generally handled under TestSummarizeSet, so add more specific
 	// tests and corner cases here.
@@ -6,6 +6,10 @@
 // Tags["1.2.3.4"]["dst_cluster"] = "mycluster"
 package llama
 
+import (
+	"sync"
+)
+
 // TODO(dmar): This is cool and all, but as is, it just plays weird. You need
 //       to make it like a map, but that only does the outside. Try to do a
 //       layer deep and it panics. And even those it's just a string map, it
@@ -13,3 +17,47 @@ package llama
 
 type Tags map[string]string
 type TagSet map[string]Tags
+
+// SharedTagSet wraps a TagSet so it can be safely read and updated from
+// multiple goroutines. This allows the API and other consumers of summaries
+// to share the same tags, and all see updates on reload.
+type SharedTagSet struct {
+	mutex   sync.RWMutex
+	ts      TagSet
+	version uint64 // Incremented on each update
+}
+
+// MergeUpdate combines a provided TagSet with the existing one.
+//
+// Existing entries are retained, updated where needed, and new ones added.
+func (s *SharedTagSet) MergeUpdate(t TagSet) {
+	s.mutex.Lock()
+	for k, v := range t {
+		s.ts[k] = v
+	}
+	s.version++
+	s.mutex.Unlock()
+}
+
+// Version identifies the current tags, and changes whenever they're updated.
+// This lets anything derived from the tags tell when it's out of date.
+func (s *SharedTagSet) Version() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.version
+}
+
+// DataPoints converts the summaries to DataPoints with the current tags.
+func (s *SharedTagSet) DataPoints(summaries []*Summary) []*DataPoint {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return NewDataPointsFromSummaries(summaries, s.ts)
+}
+
+// NewSharedTagSet creates a SharedTagSet starting with the provided TagSet.
+func NewSharedTagSet(t TagSet) *SharedTagSet {
+	if t == nil {
+		t = make(TagSet)
+	}
+	return &SharedTagSet{ts: t}
+}
@@ -0,0 +1,245 @@
+// Runtime management of the targets that tests send probes to.
+package llama
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+)
+
+// ErrTestNotFound is provided when changing the targets of a test which
+// isn't in the config.
+var ErrTestNotFound = errors.New("Test not found")
+
+// TargetManager lists and changes the targets of named tests while they're
+// running.
+type TargetManager interface {
+	// Targets provides the targets for the test.
+	Targets(test string) (TargetSet, error)
+	// AddTargets adds targets to the test, or updates the tags for any that
+	// already exist.
+	AddTargets(test string, targets TargetSet) error
+	// DelTargets removes targets, matched by IP and port, from the test.
+	DelTargets(test string, targets TargetSet) error
+}
+
+// ValidateTarget checks that the target can be probed and have its tags
+// applied, which requires an IP instead of a hostname.
+func ValidateTarget(target TargetConfig) error {
+	if net.ParseIP(target.IP) == nil {
+		return fmt.Errorf("Invalid target IP: %s", target.IP)
+	}
+	if target.Port < 1 || target.Port > 65535 {
+		return fmt.Errorf("Invalid target port: %d", target.Port)
+	}
+	return nil
+}
+
+// targetSetName provides the name of the TargetSet used by the test.
+//
+// This must be called with the mutex held.
+func (c *Collector) targetSetName(test string) (string, error) {
+	for _, t := range c.cfg.Tests {
+		if t.ID() == test {
+			return t.Targets, nil
+		}
+	}
+	return "", ErrTestNotFound
+}
+
+// runnersFor provides the TestRunners for every test using the named
+// TargetSet, since they all share the same targets.
+//
+// This must be called with the mutex held.
+func (c *Collector) runnersFor(name string) []*TestRunner {
+	var runners []*TestRunner
+	for i, t := range c.cfg.Tests {
+		if t.Targets == name && i < len(c.runners) {
+			runners = append(runners, c.runners[i])
+		}
+	}
+	return runners
+}
+
+// Targets provides a copy of the targets for the test.
+func (c *Collector) Targets(test string) (TargetSet, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	name, err := c.targetSetName(test)
+	if err != nil {
+		return nil, err
+	}
+	//nolint:gosimple
+	targets := make(TargetSet, 0) // To avoid JSON issues with nil
+	return append(targets, c.cfg.Targets[name]...), nil
+}
+
+// AddTargets adds the targets to the test, and to any other tests sharing its
+// TargetSet. Targets which already exist only have their tags updated.
+//
+// Tags are merged into the shared TagSet, so they're applied to summaries
+// immediately.
+func (c *Collector) AddTargets(test string, targets TargetSet) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	name, err := c.targetSetName(test)
+	if err != nil {
+		return err
+	}
+	// Resolve everything first, so nothing is changed if one is invalid
+	addrs := make(map[string]*net.UDPAddr)
+	for _, target := range targets {
+		addr, err := target.ResolveUDPAddr()
+		if err != nil {
+			return err
+		}
+		addrs[target.AddrString()] = addr
+	}
+	if c.cfg.Targets == nil {
+		c.cfg.Targets = make(TargetsConfig)
+	}
+	// Change a copy, so nothing is changed if it can't be saved
+	set := append(TargetSet(nil), c.cfg.Targets[name]...)
+	var added []*net.UDPAddr
+	for _, target := range targets {
+		key := target.AddrString()
+		found := false
+		for i := range set {
+			if set[i].AddrString() == key {
+				set[i].Tags = target.Tags
+				found = true
+			}
+		}
+		if !found {
+			set = append(set, target)
+			added = append(added, addrs[key])
+			// Only add each once, even if provided multiple times
+			delete(addrs, key)
+		}
+	}
+	err = c.saveTargets(name, set)
+	if err != nil {
+		return err
+	}
+	c.cfg.Targets[name] = set
+	for _, runner := range c.runnersFor(name) {
+		runner.Add(added...)
+	}
+	c.tags.MergeUpdate(targets.TagSet())
+	log.Println("Added", len(added), "targets to test", test)
+	return nil
+}
+
+// DelTargets removes the targets from the test, and from any other tests
+// sharing its TargetSet.
+//
+// Tags are left in the shared TagSet, since results may still be outstanding
+// for the removed targets.
+func (c *Collector) DelTargets(test string, targets TargetSet) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	name, err := c.targetSetName(test)
+	if err != nil {
+		return err
+	}
+	var addrs []*net.UDPAddr
+	for _, target := range targets {
+		addr, err := target.ResolveUDPAddr()
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+	}
+	remove := make(map[string]bool)
+	for _, target := range targets {
+		remove[target.AddrString()] = true
+	}
+	var set TargetSet
+	for _, target := range c.cfg.Targets[name] {
+		if !remove[target.AddrString()] {
+			set = append(set, target)
+		}
+	}
+	err = c.saveTargets(name, set)
+	if err != nil {
+		return err
+	}
+	c.cfg.Targets[name] = set
+	for _, runner := range c.runnersFor(name) {
+		for _, addr := range addrs {
+			runner.Del(addr)
+		}
+	}
+	log.Println("Removed", len(addrs), "targets from test", test)
+	return nil
+}
+
+// saveTargets writes the targets, with the named TargetSet replaced by
+// `set`, to the targets file if there is one, so that changes survive reloads
+// and restarts.
+//
+// The file only holds targets, so the config file itself is never rewritten.
+//
+// This must be called with the mutex held.
+func (c *Collector) saveTargets(name string, set TargetSet) error {
+	path := c.cfg.API.Targets.File
+	if path == "" {
+		return nil
+	}
+	targets := make(TargetsConfig)
+	for n, s := range c.cfg.Targets {
+		targets[n] = s
+	}
+	targets[name] = set
+	data, err := yaml.Marshal(targets)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, mode)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("Failed to save targets: %s", err)
+	}
+	return nil
+}
+
+// loadTargetsFile replaces the TargetSets in cfg with any of the same name
+// in its targets file, if there is one and it exists.
+func loadTargetsFile(cfg *CollectorConfig) error {
+	path := cfg.API.Targets.File
+	if path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var targets TargetsConfig
+	err = yaml.Unmarshal(data, &targets)
+	if err != nil {
+		return fmt.Errorf("Failed to parse targets file %s: %s", path, err)
+	}
+	if cfg.Targets == nil {
+		cfg.Targets = make(TargetsConfig)
+	}
+	for name, set := range targets {
+		cfg.Targets[name] = set
+	}
+	log.Println("Loaded", len(targets), "target sets from", path)
+	return nil
+}
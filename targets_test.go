@@ -0,0 +1,160 @@
+package llama
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var targetsTestConfigYAML = `
+api:
+    targets:
+        token:      secret
+ports:
+    default:
+        ip:         127.0.0.1
+        port:       0
+        timeout:    1000
+port_groups:
+    default:
+        - port:     default
+          count:    1
+rate_limits:
+    default:
+        cps:    1.0
+tests:
+    - name:         first
+      targets:      shared
+      port_group:   default
+      rate_limit:   default
+    - name:         second
+      targets:      shared
+      port_group:   default
+      rate_limit:   default
+targets:
+    shared:
+        - ip:       127.0.0.1
+          port:     8100
+          tags:
+            dst_name: one
+`
+
+// newTestCollector provides a Collector with its test runners setup, but not
+// running, for the config in data.
+func newTestCollector(t *testing.T, data string) *Collector {
+	cfg, err := NewCollectorConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Collector{cfg: cfg}
+	c.SetupTagSet()
+	c.SetupTestRunners()
+	return c
+}
+
+func TestValidateTarget(t *testing.T) {
+	if ValidateTarget(TargetConfig{IP: "10.0.0.1", Port: 8100}) != nil {
+		t.Error("Expected a valid target")
+	}
+	if ValidateTarget(TargetConfig{IP: "localhost", Port: 8100}) == nil {
+		t.Error("Expected an error for a hostname")
+	}
+	if ValidateTarget(TargetConfig{IP: "10.0.0.1"}) == nil {
+		t.Error("Expected an error without a port")
+	}
+}
+
+func TestCollectorAddDelTargets(t *testing.T) {
+	c := newTestCollector(t, targetsTestConfigYAML)
+	_, err := c.Targets("missing")
+	if err != ErrTestNotFound {
+		t.Error("Expected ErrTestNotFound, got", err)
+	}
+
+	err = c.AddTargets("first", TargetSet{
+		{IP: "127.0.0.1", Port: 8100, Tags: Tags{"dst_name": "updated"}},
+		{IP: "127.0.0.2", Port: 8100, Tags: Tags{"dst_name": "two"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := c.Targets("second")
+	if err != nil || len(targets) != 2 || targets[0].Tags["dst_name"] != "updated" {
+		t.Error("Expected the shared targets to be updated, got", targets, err)
+	}
+	// Both tests share the targets, and the existing one isn't added again
+	for _, runner := range c.runners {
+		if len(runner.Targets()) != 2 {
+			t.Error("Expected 2 targets on the runner, got", runner.Targets())
+		}
+	}
+	if c.tags.ts["127.0.0.2"]["dst_name"] != "two" {
+		t.Error("Expected tags to be updated, got", c.tags.ts)
+	}
+
+	err = c.DelTargets("second", TargetSet{{IP: "127.0.0.1", Port: 8100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, _ = c.Targets("first")
+	if len(targets) != 1 || targets[0].IP != "127.0.0.2" {
+		t.Error("Expected the target to be removed, got", targets)
+	}
+	for _, runner := range c.runners {
+		remaining := runner.Targets()
+		if len(remaining) != 1 || !remaining[0].IP.Equal(net.ParseIP("127.0.0.2")) {
+			t.Error("Expected the target to be removed from the runner, got", remaining)
+		}
+	}
+}
+
+func TestCollectorPersistTargets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llama-targets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "targets.yaml")
+	err = ioutil.WriteFile(path, []byte("{}"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestCollector(t, targetsTestConfigYAML)
+	c.cfg.API.Targets.File = path
+	err = c.AddTargets("first", TargetSet{{IP: "127.0.0.2", Port: 8100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Error("Expected the file mode to be kept, got", info, err)
+	}
+
+	// The saved targets replace those in the config when it's loaded
+	c = &Collector{}
+	data := strings.Replace(targetsTestConfigYAML, "token:      secret",
+		"token:      secret\n        file:       "+path, 1)
+	err = c.loadConfigFromData([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.cfg.Targets["shared"]) != 2 || len(c.cfg.Tests) != 2 {
+		t.Error("Expected the saved targets to be loaded, got", c.cfg.Targets)
+	}
+
+	// Nothing is changed if the targets can't be saved
+	c.SetupTagSet()
+	c.SetupTestRunners()
+	c.cfg.API.Targets.File = filepath.Join(dir, "missing", "targets.yaml")
+	err = c.DelTargets("first", TargetSet{{IP: "127.0.0.2", Port: 8100}})
+	if err == nil {
+		t.Error("Expected an error when the targets can't be saved")
+	}
+	if len(c.cfg.Targets["shared"]) != 2 || len(c.runners[0].Targets()) != 2 {
+		t.Error("Expected the targets to be unchanged, got", c.cfg.Targets)
+	}
+}
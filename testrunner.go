@@ -18,6 +18,9 @@ type TestRunner struct {
 	stop    chan bool
 	mutex   sync.RWMutex
 	targets []*net.UDPAddr
+	name    string
+	cycles  *Counter      // Completed cycles, to compare with the rate limit
+	blocked *FloatCounter // Time spent waiting on the PortGroup in cycles
 }
 
 // Run starts the TestRunner and begins cycling through targets.
@@ -51,10 +54,6 @@ func (tr *TestRunner) cycleTargets() {
 	// Acquire the lock for `tr.targets`
 	tr.mutex.RLock()
 	defer tr.mutex.RUnlock()
-	// TODO(dmar): Track how long it takes for cycles to complete.
-	//      The tough part here is that there can be multiple TestRunners
-	//      with a varying number of targets, so they aren't all treated
-	//      equally.
 	for _, target := range tr.targets {
 		// TODO(dmar): It's probably cleaner to just provide access to this
 		//      on `tr.pg` and call that, as opposed to keeping track of
@@ -63,8 +62,16 @@ func (tr *TestRunner) cycleTargets() {
 		//      down can't keep up. Leaving it that way for now, however
 		//      it may be desirable to allow some kind of "out" in the
 		//      future.
-		tr.tosend <- target
+		select {
+		case tr.tosend <- target:
+		default:
+			start := time.Now()
+			tr.tosend <- target
+			tr.blocked.AddDuration(time.Since(start))
+		}
 	}
+	// If this falls behind the rate limit, the collector can't keep up
+	tr.cycles.Inc()
 	// Cycle is complete here.
 	// TODO(dmar): This really is just referring to the ability to pass
 	//      off the targets. Doesn't actually mean everything below
@@ -112,19 +119,33 @@ func (tr *TestRunner) Add(addrs ...*net.UDPAddr) {
 func (tr *TestRunner) Del(addr *net.UDPAddr) {
 	tr.mutex.Lock()
 	defer tr.mutex.Unlock()
-	// Find the element
-	for i, v := range tr.targets {
-		if v == addr {
+	// Find the element, comparing by value since the addr may have been
+	// resolved separately from the one being stored.
+	for i := 0; i < len(tr.targets); {
+		v := tr.targets[i]
+		if v.IP.Equal(addr.IP) && v.Port == addr.Port && v.Zone == addr.Zone {
 			// Delete the element
 			// This doesn't preserve order because it shouldn't matter.
 			// Also it's WAY more efficient, especially at scale.
 			tr.targets[i] = tr.targets[len(tr.targets)-1]
 			tr.targets[len(tr.targets)-1] = nil
 			tr.targets = tr.targets[:len(tr.targets)-1]
+			// The last element was moved here, so check it as well
+			continue
 		}
+		i++
 	}
 }
 
+// Targets provides a copy of the current slice of targets.
+func (tr *TestRunner) Targets() []*net.UDPAddr {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+	targets := make([]*net.UDPAddr, len(tr.targets))
+	copy(targets, tr.targets)
+	return targets
+}
+
 // Set will replace the current slice of targets with the provided one.
 //
 // NOTE: This will block during cycles. It is generally advised to use `Set`
@@ -136,6 +157,23 @@ func (tr *TestRunner) Set(targets []*net.UDPAddr) {
 	defer tr.mutex.Unlock()
 }
 
+// SetName sets the name of the test the TestRunner is for, and registers its
+// metrics with that name.
+//
+// This must NOT be used after running.
+func (tr *TestRunner) SetName(name string) {
+	tr.name = name
+	labels := Tags{"test": name}
+	tr.cycles = DefaultMetrics.Counter("llama_testrunner_cycles_total",
+		"Cycles through all targets completed by the test.", labels)
+	tr.blocked = DefaultMetrics.FloatCounter("llama_testrunner_blocked_seconds_total",
+		"Time spent waiting to pass targets to the test's ports.", labels)
+	DefaultMetrics.Gauge("llama_testrunner_cycles_per_second_limit",
+		"Cycles per second the test is configured for.", labels).Set(float64(tr.rl.Limit()))
+	tr.pg.blocked = DefaultMetrics.FloatCounter("llama_portgroup_mux_blocked_seconds_total",
+		"Time spent waiting for the test's ports to accept targets.", labels)
+}
+
 // AddNewPort will add a new Port to the TestRunner's PortGroup.
 //
 // See PortGroup.AddNew for more details on these arguments.
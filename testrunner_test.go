@@ -44,6 +44,22 @@ func TestAddAndDel(t *testing.T) {
 	}
 }
 
+func TestDelByValue(t *testing.T) {
+	tr := NewTestRunner(exampleCallbackChan, rate.NewLimiter(rate.Inf, 0))
+	first, err := net.ResolveUDPAddr("udp", DefaultAddrStr)
+	HandleError(err)
+	second, err := net.ResolveUDPAddr("udp", DefaultAddrStr)
+	HandleError(err)
+	other, err := net.ResolveUDPAddr("udp", "127.0.0.2:8100")
+	HandleError(err)
+	tr.Set([]*net.UDPAddr{first, first, other})
+	// A separately resolved addr should still match every occurrence
+	tr.Del(second)
+	if len(tr.Targets()) != 1 || tr.Targets()[0] != other {
+		t.Error("Expected only the other target to remain, got", tr.Targets())
+	}
+}
+
 func TestSet(t *testing.T) {
 	tr := NewTestRunner(exampleCallbackChan, rate.NewLimiter(rate.Inf, 0))
 	target, err := net.ResolveUDPAddr("udp", DefaultAddrStr)
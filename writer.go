@@ -0,0 +1,341 @@
+// Writers are the backends the scraper, and optionally the collector, send
+// points to.
+package llama
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Writer is implemented by backends that points can be written to.
+type Writer interface {
+	// BatchWrite writes all of the points, as a single batch if possible.
+	BatchWrite(points Points) error
+	// Close releases any resources held by the Writer.
+	Close() error
+	// Health checks whether the backend is able to accept writes.
+	Health() error
+}
+
+// WriterOptions are the backend specific settings for a Writer.
+type WriterOptions map[string]string
+
+// String provides the value for key, or `def` if not set.
+func (o WriterOptions) String(key string, def string) string {
+	value, found := o[key]
+	if !found || value == "" {
+		return def
+	}
+	return value
+}
+
+// Int provides the value for key as an integer, or `def` if not set.
+func (o WriterOptions) Int(key string, def int64) (int64, error) {
+	value, found := o[key]
+	if !found || value == "" {
+		return def, nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid integer for %s: %s", key, value)
+	}
+	return i, nil
+}
+
+// Bool provides the value for key as a boolean, or `def` if not set.
+func (o WriterOptions) Bool(key string, def bool) (bool, error) {
+	value, found := o[key]
+	if !found || value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Invalid boolean for %s: %s", key, value)
+	}
+	return b, nil
+}
+
+// Duration provides the value for key as a duration (ex. "5s"), or `def` if
+// not set.
+func (o WriterOptions) Duration(key string, def time.Duration) (time.Duration, error) {
+	value, found := o[key]
+	if !found || value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid duration for %s: %s", key, value)
+	}
+	return d, nil
+}
+
+// WriterFactory creates a Writer based on the provided options.
+type WriterFactory func(opts WriterOptions) (Writer, error)
+
+var (
+	writerFactoriesMutex sync.RWMutex
+	writerFactories      = make(map[string]WriterFactory)
+)
+
+// RegisterWriter makes a type of Writer available by name, for use in
+// scraper flags and configs.
+//
+// This is intended to be called from `init`, and panics if the name is
+// already registered, like `http.Handle`.
+func RegisterWriter(name string, factory WriterFactory) {
+	writerFactoriesMutex.Lock()
+	defer writerFactoriesMutex.Unlock()
+	if _, found := writerFactories[name]; found {
+		panic("Writer already registered: " + name)
+	}
+	writerFactories[name] = factory
+}
+
+// WriterTypes provides the names of all registered types of Writer.
+func WriterTypes() []string {
+	writerFactoriesMutex.RLock()
+	defer writerFactoriesMutex.RUnlock()
+	names := make([]string, 0, len(writerFactories))
+	for name := range writerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewWriter creates a Writer of the named type. If the `spool_dir` option
+// is set, it's wrapped in a running SpoolWriter.
+func NewWriter(cfg WriterConfig) (Writer, error) {
+	writerFactoriesMutex.RLock()
+	factory, found := writerFactories[cfg.Type]
+	writerFactoriesMutex.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("Unknown writer type: %s (available: %s)",
+			cfg.Type, strings.Join(WriterTypes(), ", "))
+	}
+	opts := cfg.Options
+	if opts == nil {
+		opts = make(WriterOptions)
+	}
+	w, err := factory(opts)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create %s writer: %v", cfg.Type, err)
+	}
+	// Any type of Writer can have failed batches spooled
+	spooled, err := newSpoolWriterFromOptions(w, opts)
+	if err != nil {
+		HandleMinorError(w.Close())
+		return nil, fmt.Errorf("Failed to create spool for %s writer: %v", cfg.Type, err)
+	}
+	return spooled, nil
+}
+
+// NewWriters creates a Writer for each config. If there is more than one,
+// they're combined in a MultiWriter.
+//
+// Each spooled Writer needs its own `spool_dir`, since a spool replays and
+// removes everything in its directory.
+func NewWriters(cfgs []WriterConfig) (Writer, error) {
+	if len(cfgs) == 0 {
+		return nil, errors.New("No writers provided")
+	}
+	spoolDirs := make(map[string]string)
+	for _, cfg := range cfgs {
+		dir := cfg.Options.String("spool_dir", "")
+		if dir == "" {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		if other, found := spoolDirs[dir]; found {
+			return nil, fmt.Errorf("The %s and %s writers can't share spool_dir %s",
+				other, cfg.Type, dir)
+		}
+		spoolDirs[dir] = cfg.Type
+	}
+	var writers []Writer
+	for _, cfg := range cfgs {
+		w, err := NewWriter(cfg)
+		if err != nil {
+			// Don't leave the ones already created hanging around
+			for _, created := range writers {
+				HandleMinorError(created.Close())
+			}
+			return nil, err
+		}
+		writers = append(writers, w)
+	}
+	if len(writers) == 1 {
+		return writers[0], nil
+	}
+	return NewMultiWriter(writers...), nil
+}
+
+// ParseWriterFlag parses a writer provided on the command line, in the form
+// `<type>:<key>=<value>,<key>=<value>` with options being optional.
+//
+// Ex. `influxdb:host=10.0.0.1,db=llama`
+func ParseWriterFlag(value string) (WriterConfig, error) {
+	cfg := WriterConfig{Options: make(WriterOptions)}
+	parts := strings.SplitN(value, ":", 2)
+	cfg.Type = strings.TrimSpace(parts[0])
+	if cfg.Type == "" {
+		return cfg, fmt.Errorf("No writer type in: %s", value)
+	}
+	if len(parts) == 1 || parts[1] == "" {
+		return cfg, nil
+	}
+	for _, opt := range strings.Split(parts[1], ",") {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return cfg, fmt.Errorf("Invalid writer option %q in: %s", opt, value)
+		}
+		cfg.Options[strings.TrimSpace(kv[0])] = kv[1]
+	}
+	return cfg, nil
+}
+
+// MultiWriter writes points to several Writers, such as when migrating
+// between backends.
+type MultiWriter struct {
+	writers []Writer
+}
+
+// BatchWrite writes the points to all of the Writers in parallel, so a slow
+// backend doesn't hold up the others. All Writers are attempted, even if
+// some fail.
+func (m *MultiWriter) BatchWrite(points Points) error {
+	errs := m.batchWriteEach(func(i int) (Points, bool) { return points, true })
+	return combineErrors("write", errs)
+}
+
+// batchWriteEach writes to all of the Writers in parallel, like BatchWrite,
+// with the points for each provided by `points`, which also indicates if
+// there's anything to write to it. There's an error for each Writer.
+func (m *MultiWriter) batchWriteEach(points func(i int) (Points, bool)) []error {
+	errs := make([]error, len(m.writers))
+	var wg sync.WaitGroup
+	for i, w := range m.writers {
+		batch, write := points(i)
+		if !write {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, w Writer, batch Points) {
+			defer wg.Done()
+			errs[i] = w.BatchWrite(batch)
+		}(i, w, batch)
+	}
+	wg.Wait()
+	return errs
+}
+
+// Close closes all of the Writers.
+func (m *MultiWriter) Close() error {
+	errs := make([]error, len(m.writers))
+	for i, w := range m.writers {
+		errs[i] = w.Close()
+	}
+	return combineErrors("close", errs)
+}
+
+// Health checks all of the Writers, and fails if any of them do.
+func (m *MultiWriter) Health() error {
+	errs := make([]error, len(m.writers))
+	for i, w := range m.writers {
+		errs[i] = w.Health()
+	}
+	return combineErrors("health check", errs)
+}
+
+// combineErrors merges any non-nil errors, from the Writer at the same index,
+// into a single error.
+func combineErrors(action string, errs []error) error {
+	var msgs []string
+	for i, err := range errs {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("writer %d: %v", i, err))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Failed %s for %d of %d writers: %s", action, len(msgs),
+		len(errs), strings.Join(msgs, "; "))
+}
+
+// NewMultiWriter creates a MultiWriter for the provided Writers.
+func NewMultiWriter(writers ...Writer) *MultiWriter {
+	return &MultiWriter{writers: writers}
+}
+
+// PointsFrom converts DataPoints, as provided for summaries, to Points.
+func PointsFrom(dps []*DataPoint) Points {
+	points := make(Points, 0, len(dps))
+	for _, dp := range dps {
+		points = append(points, *dp)
+	}
+	return points
+}
+
+// IntervalWriter writes the summaries of each Interval from a Summarizer to
+// a Writer, so the collector can use the same outputs as the scraper.
+type IntervalWriter struct {
+	in       chan *Interval
+	tags     *SharedTagSet
+	writer   Writer
+	stop     chan bool
+	written  *Counter
+	failures *Counter
+}
+
+// Run starts writing Intervals as they're received.
+func (iw *IntervalWriter) Run() {
+	go iw.run()
+}
+
+func (iw *IntervalWriter) run() {
+	// Make sure anything buffered by the Writer is flushed on the way out
+	defer func() { HandleMinorError(iw.writer.Close()) }()
+	for {
+		select {
+		case <-iw.stop:
+			log.Println("Stopping IntervalWriter")
+			return
+		case interval := <-iw.in:
+			err := iw.writer.BatchWrite(PointsFrom(iw.tags.DataPoints(interval.Summaries)))
+			if err != nil {
+				log.Println("Failed to write interval", interval.ID, "-", err)
+				iw.failures.Inc()
+				continue
+			}
+			iw.written.Inc()
+		}
+	}
+}
+
+// Stop will stop writing, and close the Writer.
+func (iw *IntervalWriter) Stop() {
+	close(iw.stop)
+}
+
+// NewIntervalWriter creates an IntervalWriter for Intervals received on
+// `in`, such as from Summarizer.Subscribe, using `tags` to tag them.
+func NewIntervalWriter(in chan *Interval, tags *SharedTagSet, w Writer) *IntervalWriter {
+	return &IntervalWriter{
+		in:     in,
+		tags:   tags,
+		writer: w,
+		stop:   make(chan bool),
+		written: DefaultMetrics.Counter("llama_outputs_intervals_written_total",
+			"Summarized intervals written to (or spooled for) the outputs.", nil),
+		failures: DefaultMetrics.Counter("llama_outputs_write_failures_total",
+			"Summarized intervals that couldn't be written to (or spooled for) the outputs.", nil),
+	}
+}
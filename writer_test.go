@@ -0,0 +1,108 @@
+package llama
+
+import (
+	"errors"
+	"testing"
+)
+
+// MockWriter records the points written to it, and fails if `err` is set.
+type MockWriter struct {
+	points Points
+	closed bool
+	err    error
+}
+
+func (m *MockWriter) BatchWrite(points Points) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.points = append(m.points, points...)
+	return nil
+}
+
+func (m *MockWriter) Close() error {
+	m.closed = true
+	return nil
+}
+
+func (m *MockWriter) Health() error {
+	return m.err
+}
+
+func TestParseWriterFlag(t *testing.T) {
+	cfg, err := ParseWriterFlag("influxdb:host=10.0.0.1,db=llama")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Type != "influxdb" || cfg.Options["host"] != "10.0.0.1" || cfg.Options["db"] != "llama" {
+		t.Error("Parsed incorrectly:", cfg)
+	}
+	cfg, err = ParseWriterFlag("influxdb")
+	if err != nil || cfg.Type != "influxdb" || len(cfg.Options) != 0 {
+		t.Error("Expected a writer without options, got", cfg, err)
+	}
+	for _, bad := range []string{"", ":host=a", "influxdb:host", "influxdb:=a"} {
+		_, err = ParseWriterFlag(bad)
+		if err == nil {
+			t.Error("Expected an error parsing", bad)
+		}
+	}
+}
+
+func TestWriterOptions(t *testing.T) {
+	opts := WriterOptions{"count": "3", "gzip": "true", "timeout": "2s", "bad": "x"}
+	if opts.String("missing", "def") != "def" {
+		t.Error("Default not used for a missing string")
+	}
+	if i, err := opts.Int("count", 1); i != 3 || err != nil {
+		t.Error("Expected 3, got", i, err)
+	}
+	if b, err := opts.Bool("gzip", false); !b || err != nil {
+		t.Error("Expected true, got", b, err)
+	}
+	if d, err := opts.Duration("timeout", 0); d.Seconds() != 2 || err != nil {
+		t.Error("Expected 2s, got", d, err)
+	}
+	if _, err := opts.Int("bad", 1); err == nil {
+		t.Error("Expected an error for an invalid integer")
+	}
+}
+
+func TestNewWriters(t *testing.T) {
+	_, err := NewWriters(nil)
+	if err == nil {
+		t.Error("Expected an error without writers")
+	}
+	_, err = NewWriters([]WriterConfig{{Type: "nope"}})
+	if err == nil {
+		t.Error("Expected an error for an unknown type")
+	}
+	w, err := NewWriters([]WriterConfig{{Type: "influxdb"}, {Type: "influxdb"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.(*MultiWriter); !ok {
+		t.Error("Expected a MultiWriter for several writers")
+	}
+}
+
+func TestMultiWriter(t *testing.T) {
+	good := &MockWriter{}
+	bad := &MockWriter{err: errors.New("down")}
+	m := NewMultiWriter(good, bad)
+	err := m.BatchWrite(examplePoints)
+	if err == nil {
+		t.Error("Expected an error from the failing writer")
+	}
+	// The failure shouldn't prevent writing to the others
+	if len(good.points) != len(examplePoints) {
+		t.Error("Expected points to be written, got", len(good.points))
+	}
+	if m.Health() == nil {
+		t.Error("Expected the health check to fail")
+	}
+	err = m.Close()
+	if err != nil || !good.closed || !bad.closed {
+		t.Error("Expected all writers to be closed")
+	}
+}